@@ -17,6 +17,10 @@ resource "aws_ecs_task_definition" "blue_report_intake" {
       image     = "242201310196.dkr.ecr.us-west-2.amazonaws.com/blue-report:${local.intake_version}"
       essential = true
       command   = ["/intake"]
+
+      # Allow time for buffered events to be flushed after SIGTERM (maximum on Fargate)
+      stopTimeout = 120
+
       environment = [
         {
           name  = "VALKEY_ADDRESS"
@@ -38,6 +42,10 @@ resource "aws_ecs_task_definition" "blue_report_intake" {
           name  = "SQS_NORMALIZATION_QUEUE_NAME"
           value = aws_sqs_queue.blue_report.name
         },
+        {
+          name  = "INTAKE_WORKERS"
+          value = "4"
+        },
       ]
       cpu    = 512
       memory = 1024
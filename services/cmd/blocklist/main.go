@@ -0,0 +1,138 @@
+// Blocklist lists, adds, and removes the rules excluding URLs from the report.
+//
+//	blocklist list
+//	blocklist add -type host -value example.com -reason "spam" [-for 72h | -expires 2025-03-03]
+//	blocklist remove -id <id>
+//
+// Rule types are 'host', 'host_suffix', 'path' (a regular expression matched against the URL's path), and 'url'.
+// Running services pick up changes the next time they refresh the blocklist.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/moderation"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+var timeFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func main() {
+	if os.Getenv("DEBUG") == "true" {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+
+	switch os.Args[1] {
+	case "list":
+		flags.Parse(os.Args[2:])
+
+		rules, err := moderation.LoadRules(newStorage())
+		if err != nil {
+			exit(err)
+		}
+		now := time.Now()
+		for _, rule := range rules {
+			expires := "never"
+			if !rule.ExpiresAt.IsZero() {
+				expires = rule.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+			if moderation.Expired(rule, now) {
+				expires += " (expired)"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\texpires: %s\n", rule.ID, rule.Type, rule.Value, rule.Reason, expires)
+		}
+	case "add":
+		ruleType := flags.String("type", moderation.HostRule, "type of rule, either 'host', 'host_suffix', 'path', or 'url'")
+		value := flags.String("value", "", "host, host suffix, path pattern, or URL to block")
+		reason := flags.String("reason", "", "reason for blocking, recorded alongside removed links")
+		duration := flags.Duration("for", 0, "expire the rule after this duration")
+		expires := flags.String("expires", "", "expire the rule at this time")
+		flags.Parse(os.Args[2:])
+		if *value == "" || *reason == "" {
+			usage()
+		}
+
+		var expiresAt time.Time
+		if *duration > 0 {
+			expiresAt = time.Now().UTC().Add(*duration)
+		}
+		if *expires != "" {
+			expiresAt = parseTime(*expires)
+		}
+
+		rule, err := moderation.NewRule(*ruleType, *value, *reason, expiresAt)
+		if err != nil {
+			exit(err)
+		}
+		if err := moderation.AddRule(newStorage(), rule); err != nil {
+			exit(err)
+		}
+		slog.Info("added rule", "id", rule.ID, "type", rule.Type, "value", rule.Value)
+	case "remove":
+		id := flags.String("id", "", "ID of the rule to remove")
+		flags.Parse(os.Args[2:])
+		if *id == "" {
+			usage()
+		}
+
+		if err := moderation.RemoveRule(newStorage(), *id); err != nil {
+			exit(err)
+		}
+		slog.Info("removed rule", "id", *id)
+	default:
+		usage()
+	}
+}
+
+// Only storage is needed, so the rest of the app (i.e. the cache) is not created
+func newStorage() moderation.RuleStorage {
+	cfg, err := config.New()
+	if err != nil {
+		exit(err)
+	}
+	if cfg.StorageBackend == config.StorageBackendLocal {
+		st, err := storage.NewLocal(cfg)
+		if err != nil {
+			exit(err)
+		}
+		return st
+	}
+	st, err := storage.New(cfg)
+	if err != nil {
+		exit(err)
+	}
+	return st
+}
+
+func parseTime(value string) time.Time {
+	for _, format := range timeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UTC()
+		}
+	}
+	fmt.Fprintf(os.Stderr, "invalid time: %s\n", value)
+	os.Exit(2)
+	return time.Time{}
+}
+
+func exit(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: blocklist list")
+	fmt.Fprintln(os.Stderr, "       blocklist add [-type host|host_suffix|path|url] -value <value> -reason <reason> [-for <duration> | -expires <time>]")
+	fmt.Fprintln(os.Stderr, "       blocklist remove -id <id>")
+	os.Exit(2)
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/georgemblack/blue-report/pkg/app"
 )
@@ -11,7 +14,12 @@ func main() {
 	if os.Getenv("DEBUG") == "true" {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 	}
-	err := app.Intake()
+
+	// ECS sends SIGTERM before stopping a task. Cancel the context so buffered events are flushed before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	err := app.Intake(ctx)
 	if err != nil {
 		slog.Error(err.Error())
 	}
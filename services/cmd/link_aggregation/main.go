@@ -11,14 +11,16 @@ func main() {
 	if os.Getenv("DEBUG") == "true" {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 	}
-	snapshot, err := app.AggregateLinks()
+	snapshots, err := app.AggregateLinks()
 	if err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
-	err = app.PublishLinkSnapshot(snapshot)
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(1)
+	for _, snapshot := range snapshots {
+		err = app.PublishLinkSnapshot(snapshot)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
 	}
 }
@@ -0,0 +1,41 @@
+// Live aggregation generates a link snapshot from the live aggregation maintained by intake (see 'LIVE_AGGREGATION').
+//
+//	live_aggregation [-publish]
+//
+// By default, the snapshot is written to stdout. With '-publish', it is published in place of the batch snapshot.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/georgemblack/blue-report/pkg/app"
+)
+
+func main() {
+	if os.Getenv("DEBUG") == "true" {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	publish := flag.Bool("publish", false, "publish the snapshot, rather than writing it to stdout")
+	flag.Parse()
+
+	snapshot, err := app.AggregateLiveLinks()
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if *publish {
+		err = app.PublishLinkSnapshot(snapshot)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(snapshot)
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,86 @@
+// Replay records the Jetstream to a file, or replays a recording through the intake pipeline.
+//
+//	replay record -out stream.jsonl.zst [-limit 100000]
+//	replay play -in stream.jsonl.zst [-speed 10]
+//
+// Playing requires local storage and the in-memory cache, so a replay can never write to production. The output is deterministic, so it can be diffed across code changes:
+//
+//	STORAGE_BACKEND=local LOCAL_STORAGE_DIR=replay-output CACHE_BACKEND=memory replay play -in stream.jsonl.zst
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/georgemblack/blue-report/pkg/app"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// Run the given command, returning the exit code. Deferred calls run before the process exits.
+func run() int {
+	if os.Getenv("DEBUG") == "true" {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	switch os.Args[1] {
+	case "record":
+		flags := flag.NewFlagSet("record", flag.ExitOnError)
+		out := flags.String("out", "", "file to write frames to (compressed if ending in '.zst')")
+		limit := flags.Int("limit", 0, "number of frames to record (zero records until interrupted)")
+		flags.Parse(os.Args[2:])
+		if *out == "" {
+			usage()
+		}
+
+		count, err := app.RecordStream(ctx, app.JetstreamURL, *out, *limit)
+		slog.Info("recorded frames", "count", count, "file", *out)
+		if err != nil {
+			slog.Error(err.Error())
+			return 1
+		}
+	case "play":
+		flags := flag.NewFlagSet("play", flag.ExitOnError)
+		in := flags.String("in", "", "recording to replay")
+		speed := flags.Float64("speed", 0, "speed-up relative to the original stream (zero replays as fast as possible)")
+		flags.Parse(os.Args[2:])
+		if *in == "" {
+			usage()
+		}
+
+		application, err := app.NewApp()
+		if err != nil {
+			slog.Error(err.Error())
+			return 1
+		}
+		defer application.Close()
+
+		_, err = app.ReplayStream(ctx, application, *in, *speed)
+		if err != nil {
+			slog.Error(err.Error())
+			return 1
+		}
+	default:
+		usage()
+	}
+	return 0
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: replay record -out <file> [-limit <frames>]")
+	fmt.Fprintln(os.Stderr, "       replay play -in <file> [-speed <factor>]")
+	os.Exit(2)
+}
@@ -0,0 +1,110 @@
+// Snapshots lists archived snapshots, or prints the archived snapshot nearest to a given time.
+//
+//	snapshots list [-kind links] -start 2025-03-03 [-end 2025-03-04]
+//	snapshots get [-kind links] -at 2025-03-03T12:00
+//
+// Times are UTC, and may be given as a date, a date and time, or RFC 3339.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/app"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+var timeFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func main() {
+	if os.Getenv("DEBUG") == "true" {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	kind := flags.String("kind", storage.LinkSnapshot, "kind of snapshot, either 'links', 'links.<language>', or 'sites'")
+
+	switch os.Args[1] {
+	case "list":
+		start := flags.String("start", "", "list snapshots published after this time")
+		end := flags.String("end", "", "list snapshots published before this time (defaults to a day after 'start')")
+		flags.Parse(os.Args[2:])
+		if *start == "" {
+			usage()
+		}
+
+		startTime := parseTime(*start)
+		endTime := startTime.Add(24 * time.Hour)
+		if *end != "" {
+			endTime = parseTime(*end)
+		}
+
+		application := newApp()
+		defer application.Close()
+		snapshots, err := app.ListArchivedSnapshots(application.Storage, *kind, startTime, endTime)
+		if err != nil {
+			exit(err)
+		}
+		for _, snapshot := range snapshots {
+			fmt.Println(snapshot.Time.Format(time.RFC3339), snapshot.Key())
+		}
+	case "get":
+		at := flags.String("at", "", "print the snapshot published closest to this time")
+		flags.Parse(os.Args[2:])
+		if *at == "" {
+			usage()
+		}
+
+		application := newApp()
+		defer application.Close()
+		nearest, err := app.NearestArchivedSnapshot(application.Storage, *kind, parseTime(*at))
+		if err != nil {
+			exit(err)
+		}
+		data, err := application.Storage.ReadSnapshot(nearest)
+		if err != nil {
+			exit(err)
+		}
+
+		slog.Info("found snapshot", "published", nearest.Time.Format(time.RFC3339), "key", nearest.Key())
+		os.Stdout.Write(data)
+	default:
+		usage()
+	}
+}
+
+func newApp() app.App {
+	application, err := app.NewApp()
+	if err != nil {
+		exit(err)
+	}
+	return application
+}
+
+func parseTime(value string) time.Time {
+	for _, format := range timeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UTC()
+		}
+	}
+	fmt.Fprintf(os.Stderr, "invalid time: %s\n", value)
+	os.Exit(2)
+	return time.Time{}
+}
+
+func exit(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: snapshots list [-kind links|links.<language>|sites] -start <time> [-end <time>]")
+	fmt.Fprintln(os.Stderr, "       snapshots get [-kind links|links.<language>|sites] -at <time>")
+	os.Exit(2)
+}
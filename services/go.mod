@@ -16,9 +16,11 @@ require (
 	github.com/deckarep/golang-set/v2 v2.9.0
 	github.com/gorilla/feeds v1.2.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.20.1
 	github.com/valkey-io/valkey-go v1.0.76
 	github.com/vmihailenco/msgpack/v5 v5.4.1
 	go.uber.org/mock v0.6.0
+	golang.org/x/net v0.53.0
 )
 
 require (
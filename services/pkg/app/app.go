@@ -1,20 +1,34 @@
 package app
 
 import (
+	"time"
+
 	"github.com/georgemblack/blue-report/pkg/bluesky"
 	"github.com/georgemblack/blue-report/pkg/cache"
+	"github.com/georgemblack/blue-report/pkg/classify"
 	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/integrity"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/live"
+	"github.com/georgemblack/blue-report/pkg/moderation"
 	"github.com/georgemblack/blue-report/pkg/queue"
 	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/util"
 )
 
 // App creates a new instance of the application, initializing the cache, storage, and Bluesky API client.
 type App struct {
-	Config  config.Config
-	Cache   Cache
-	Storage Storage
-	Queue   Queue
-	Bluesky Bluesky
+	Config      config.Config
+	Cache       Cache
+	Storage     Storage
+	Queue       Queue
+	Bluesky     Bluesky
+	Blocklist   Blocklist             // Rules excluding URLs from the report. Only set by jobs that filter or rank links.
+	Live        Live                  // Only set if live aggregation is enabled
+	History     History               // Previously published snapshots, used to track rank movement
+	Classifier  classify.Classifier   // Assigns categories to links. Only set by jobs that publish link snapshots.
+	AccountAges integrity.AccountAges // Only set if integrity checks are enabled
 }
 
 func NewApp() (App, error) {
@@ -23,32 +37,115 @@ func NewApp() (App, error) {
 		return App{}, err
 	}
 
-	cache, err := cache.New(config)
+	cache, err := newCache(config)
 	if err != nil {
 		return App{}, err
 	}
 
-	storage, err := storage.New(config)
+	storage, queue, err := newBackends(config)
 	if err != nil {
 		return App{}, err
 	}
 
-	queue, err := queue.New(config)
-	if err != nil {
-		return App{}, err
+	bluesky := bluesky.New(config)
+
+	var ages integrity.AccountAges
+	if config.IntegrityChecks {
+		ages = integrity.NewPLC(config)
 	}
 
-	bluesky := bluesky.New(config)
+	var live Live
+	if config.LiveAggregation {
+		live, err = newLive(config)
+		if err != nil {
+			return App{}, err
+		}
+	}
 
 	return App{
-		Config:  config,
-		Cache:   cache,
-		Storage: storage,
-		Queue:   queue,
-		Bluesky: bluesky,
+		Config:      config,
+		Cache:       cache,
+		Storage:     storage,
+		Queue:       queue,
+		Bluesky:     bluesky,
+		Live:        live,
+		History:     ArchiveHistory{Storage: storage},
+		AccountAges: ages,
 	}, nil
 }
 
-func (a App) Close() {
+// Create the cache selected by the config.
+func newCache(cfg config.Config) (Cache, error) {
+	if cfg.CacheBackend == config.CacheBackendMemory {
+		return cache.NewMemory(), nil
+	}
+	return cache.New(cfg)
+}
+
+// Load the blocklist, which is refreshed in the background until the app is closed.
+// Loading fails if the rules can't be read, so only jobs that filter or rank links should load it.
+func newBlocklist(st Storage) (Blocklist, error) {
+	store, err := moderation.NewStore(st, moderation.RefreshInterval)
+	if err != nil {
+		return nil, util.WrapErr("failed to load blocklist", err)
+	}
+	return store, nil
+}
+
+// Create the live aggregation, using the same backend as the cache.
+// Buckets are kept for the longest configured window.
+func newLive(cfg config.Config) (Live, error) {
+	windows, err := links.ParseWindows(cfg.LinkWindows)
+	if err != nil {
+		return nil, util.WrapErr("failed to parse link windows", err)
+	}
+	retention := links.NewTimeBounds(time.Time{}, windows).Longest().Duration
+
+	if cfg.CacheBackend == config.CacheBackendMemory {
+		return live.NewMemory(retention), nil
+	}
+	return live.New(cfg, retention)
+}
+
+// Create the filter used to detect duplicate events during aggregation.
+// Bloom filters are sized for the number of chunks being aggregated, assuming each chunk is full.
+func newFilter(cfg config.Config, chunks int) (dedupe.Filter, error) {
+	return dedupe.New(cfg.DedupeMode, uint(chunks*EventBufferSize))
+}
+
+// Create the storage & queue backends selected by the config.
+// The 'local' backend keeps everything on disk, so the pipeline can run without AWS.
+func newBackends(cfg config.Config) (Storage, Queue, error) {
+	if cfg.StorageBackend == config.StorageBackendLocal {
+		st, err := storage.NewLocal(cfg)
+		if err != nil {
+			return nil, nil, util.WrapErr("failed to create local storage", err)
+		}
+		q, err := queue.NewLocal(cfg)
+		if err != nil {
+			return nil, nil, util.WrapErr("failed to create local queue", err)
+		}
+		return st, q, nil
+	}
+
+	st, err := storage.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	q, err := queue.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return st, q, nil
+}
+
+// Close the app's clients, including any set after the app was created (i.e. the blocklist).
+func (a *App) Close() {
 	a.Cache.Close()
+	if a.Blocklist != nil {
+		a.Blocklist.Close()
+	}
+	if a.Live != nil {
+		a.Live.Close()
+	}
 }
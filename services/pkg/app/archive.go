@@ -0,0 +1,115 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/sites"
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// ArchiveSearchWindow is how far from the requested time to look for the nearest archived snapshot.
+const ArchiveSearchWindow = 24 * time.Hour
+
+// LinkSnapshotKind returns the kind of link snapshot for the given language (i.e. 'links.ja').
+// The default report's language keeps the original kind, 'links', so it is published in the same place as before.
+func LinkSnapshotKind(cfg config.Config, language string) string {
+	if language == "" || language == cfg.PrimaryLanguage() {
+		return storage.LinkSnapshot
+	}
+	return storage.LinkSnapshot + "." + language
+}
+
+// ListArchivedSnapshots lists the archived snapshots of the given kind (i.e. 'links', 'links.ja', or 'sites') published between 'start' and 'end'.
+func ListArchivedSnapshots(st Storage, kind string, start, end time.Time) ([]storage.ArchivedSnapshot, error) {
+	if kind != storage.LinkSnapshot && kind != storage.SiteSnapshot && !strings.HasPrefix(kind, storage.LinkSnapshot+".") {
+		return nil, fmt.Errorf("unknown snapshot kind: %s", kind)
+	}
+
+	snapshots, err := st.ListSnapshots(kind, start, end)
+	if err != nil {
+		return nil, util.WrapErr("failed to list snapshots", err)
+	}
+	return snapshots, nil
+}
+
+// NearestArchivedSnapshot finds the archived snapshot published closest to the given time, before or after.
+// An error is returned if there is no snapshot within 'ArchiveSearchWindow'.
+func NearestArchivedSnapshot(st Storage, kind string, at time.Time) (storage.ArchivedSnapshot, error) {
+	snapshots, err := ListArchivedSnapshots(st, kind, at.Add(-ArchiveSearchWindow), at.Add(ArchiveSearchWindow))
+	if err != nil {
+		return storage.ArchivedSnapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return storage.ArchivedSnapshot{}, fmt.Errorf("no %s snapshot within %s of %s", kind, ArchiveSearchWindow, at.Format(time.RFC3339))
+	}
+
+	nearest := snapshots[0]
+	for _, snapshot := range snapshots[1:] {
+		if snapshot.Time.Sub(at).Abs() < nearest.Time.Sub(at).Abs() {
+			nearest = snapshot
+		}
+	}
+	return nearest, nil
+}
+
+// LoadLinkSnapshot loads the archived link snapshot published closest to the given time.
+func LoadLinkSnapshot(st Storage, at time.Time) (links.Snapshot, error) {
+	var snapshot links.Snapshot
+	err := loadArchivedSnapshot(st, storage.LinkSnapshot, at, &snapshot)
+	return snapshot, err
+}
+
+// LoadSiteSnapshot loads the archived site snapshot published closest to the given time.
+func LoadSiteSnapshot(st Storage, at time.Time) (sites.Snapshot, error) {
+	var snapshot sites.Snapshot
+	err := loadArchivedSnapshot(st, storage.SiteSnapshot, at, &snapshot)
+	return snapshot, err
+}
+
+// ArchiveHistory finds previously published snapshots in the archive.
+type ArchiveHistory struct {
+	Storage Storage
+}
+
+// PreviousLinkSnapshot loads the most recent link snapshot of the given kind published before the given time.
+// If there is none within 'ArchiveSearchWindow', an empty snapshot is returned.
+func (h ArchiveHistory) PreviousLinkSnapshot(kind string, before time.Time) (links.Snapshot, error) {
+	snapshots, err := ListArchivedSnapshots(h.Storage, kind, before.Add(-ArchiveSearchWindow), before)
+	if err != nil {
+		return links.Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return links.Snapshot{}, nil
+	}
+
+	var snapshot links.Snapshot
+	err = readArchivedSnapshot(h.Storage, snapshots[len(snapshots)-1], &snapshot)
+	return snapshot, err
+}
+
+func loadArchivedSnapshot(st Storage, kind string, at time.Time, value any) error {
+	nearest, err := NearestArchivedSnapshot(st, kind, at)
+	if err != nil {
+		return err
+	}
+	return readArchivedSnapshot(st, nearest, value)
+}
+
+func readArchivedSnapshot(st Storage, snapshot storage.ArchivedSnapshot, value any) error {
+	data, err := st.ReadSnapshot(snapshot)
+	if err != nil {
+		return util.WrapErr("failed to read snapshot", err)
+	}
+
+	err = json.Unmarshal(data, value)
+	if err != nil {
+		return util.WrapErr("failed to unmarshal snapshot", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// Test that the snapshot published closest to the requested time is loaded, whether before or after
+func TestLoadLinkSnapshot(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		archiveTestSnapshot(t, app, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	tests := []struct {
+		at       time.Time
+		expected time.Time
+	}{
+		{start.Add(-time.Hour), start},
+		{start.Add(20 * time.Minute), start},
+		{start.Add(40 * time.Minute), start.Add(time.Hour)},
+		{start.Add(5 * time.Hour), start.Add(2 * time.Hour)},
+	}
+	for _, test := range tests {
+		snapshot, err := LoadLinkSnapshot(app.Storage, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.GeneratedAt != test.expected.Format(time.RFC3339) {
+			t.Errorf("unexpected snapshot for %s: %s", test.at, snapshot.GeneratedAt)
+		}
+	}
+
+	if _, err := LoadLinkSnapshot(app.Storage, start.AddDate(0, 0, 7)); err == nil {
+		t.Error("expected error when no snapshot is nearby")
+	}
+	if _, err := LoadSiteSnapshot(app.Storage, start); err == nil {
+		t.Error("expected error when no site snapshot exists")
+	}
+	if _, err := ListArchivedSnapshots(app.Storage, "posts", start, start); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+}
+
+// Test that the most recent snapshot before the given time is used to track movement
+func TestArchiveHistory(t *testing.T) {
+	app, _ := newTestApp(t)
+	history := ArchiveHistory{Storage: app.Storage}
+
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	previous, err := history.PreviousLinkSnapshot(storage.LinkSnapshot, start)
+	if err != nil || previous.GeneratedAt != "" {
+		t.Errorf("expected empty snapshot, got %+v (%v)", previous, err)
+	}
+
+	archiveTestSnapshot(t, app, start)
+	archiveTestSnapshot(t, app, start.Add(time.Hour))
+	archiveTestSnapshot(t, app, start.Add(2*time.Hour))
+
+	previous, err = history.PreviousLinkSnapshot(storage.LinkSnapshot, start.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.GeneratedAt != start.Add(time.Hour).Format(time.RFC3339) {
+		t.Errorf("unexpected previous snapshot: %s", previous.GeneratedAt)
+	}
+}
+
+func TestLinkSnapshotKind(t *testing.T) {
+	cfg := config.Config{Languages: []string{"en", "ja"}}
+	if kind := LinkSnapshotKind(cfg, "en"); kind != "links" {
+		t.Errorf("unexpected kind: %s", kind)
+	}
+	if kind := LinkSnapshotKind(cfg, "ja"); kind != "links.ja" {
+		t.Errorf("unexpected kind: %s", kind)
+	}
+	if kind := LinkSnapshotKind(config.Config{}, "en"); kind != "links" {
+		t.Errorf("unexpected kind: %s", kind)
+	}
+	app, _ := newTestApp(t)
+	if _, err := ListArchivedSnapshots(app.Storage, "links.ja", time.Now(), time.Now()); err != nil {
+		t.Errorf("unexpected error for language kind: %s", err)
+	}
+}
+
+// Snapshots are archived at the time they are published, so write them directly
+func archiveTestSnapshot(t *testing.T, app App, published time.Time) {
+	data, _ := json.Marshal(links.Snapshot{GeneratedAt: published.Format(time.RFC3339)})
+	path := filepath.Join(app.Config.LocalStorageDir, storage.ArchivedSnapshot{Kind: storage.LinkSnapshot, Time: published}.Key())
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"sync"
+)
+
+// Checkpoint tracks how far into the Jetstream events have been durably written to storage, across every intake worker.
+// Each worker holds the 'time_us' of the first event in a chunk until the chunk is flushed, so the saved cursor never passes an event that could still be lost.
+// A chunk that fails to flush is never released, so a restart resumes before it.
+//
+// A nil checkpoint ignores every call, i.e. when replaying a recording.
+type Checkpoint struct {
+	mu      sync.Mutex
+	handled int64              // 'time_us' of the most recent event handled by any worker
+	held    map[chunkRef]int64 // 'time_us' of the first event in each chunk that has not been flushed
+	err     error              // First error encountered while flushing
+}
+
+type chunkRef struct {
+	worker   int
+	sequence int
+}
+
+func NewCheckpoint() *Checkpoint {
+	return &Checkpoint{held: make(map[chunkRef]int64)}
+}
+
+// Handled records that a worker has finished with an event, either by buffering or discarding it.
+func (c *Checkpoint) Handled(timeUS int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handled = max(c.handled, timeUS)
+}
+
+// Hold prevents the cursor from passing the first event of a chunk, until it is released.
+func (c *Checkpoint) Hold(worker, sequence int, timeUS int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.held[chunkRef{worker: worker, sequence: sequence}] = timeUS
+}
+
+// Release is called once a chunk has been written to storage.
+func (c *Checkpoint) Release(worker, sequence int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.held, chunkRef{worker: worker, sequence: sequence})
+}
+
+// Fail records a chunk that could not be written to storage. The chunk remains held.
+func (c *Checkpoint) Fail(err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Err returns the first error encountered while flushing, if any.
+func (c *Checkpoint) Err() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Cursor returns the 'time_us' up to which every event has been written to storage (or discarded), or zero if unknown.
+// Events handled concurrently by other workers may be slightly older, which is covered by 'CursorRewind'.
+func (c *Checkpoint) Cursor() int64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cursor := c.handled
+	for _, timeUS := range c.held {
+		cursor = min(cursor, timeUS-1)
+	}
+	return max(cursor, 0)
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/testutil"
+)
+
+// Test the cursor never passes the first event of a chunk that has not been flushed
+func TestCheckpointCursor(t *testing.T) {
+	checkpoint := NewCheckpoint()
+	if checkpoint.Cursor() != 0 {
+		t.Errorf("expected no cursor, got %d", checkpoint.Cursor())
+	}
+
+	checkpoint.Hold(1, 0, 100)
+	checkpoint.Handled(100)
+	checkpoint.Hold(2, 0, 101)
+	checkpoint.Handled(101)
+	checkpoint.Handled(102)
+	if checkpoint.Cursor() != 99 {
+		t.Errorf("expected cursor 99, got %d", checkpoint.Cursor())
+	}
+
+	// Releasing a later chunk does not move the cursor past an earlier one
+	checkpoint.Release(2, 0)
+	if checkpoint.Cursor() != 99 {
+		t.Errorf("expected cursor 99, got %d", checkpoint.Cursor())
+	}
+	checkpoint.Release(1, 0)
+	if checkpoint.Cursor() != 102 {
+		t.Errorf("expected cursor 102, got %d", checkpoint.Cursor())
+	}
+
+	// A nil checkpoint ignores every call
+	var empty *Checkpoint
+	empty.Hold(1, 0, 100)
+	empty.Handled(100)
+	if empty.Cursor() != 0 || empty.Err() != nil {
+		t.Error("unexpected state for nil checkpoint")
+	}
+}
+
+// failingStorage fails to write every chunk.
+type failingStorage struct {
+	Storage
+}
+
+func (failingStorage) FlushEvents(key storage.ChunkKey, events []storage.EventRecord) error {
+	return errors.New("unavailable")
+}
+
+// Test workers only advance the checkpoint once buffered events have been written to storage
+func TestWorkerCheckpoint(t *testing.T) {
+	event := toStreamEvent(testutil.GetTestData("post-facet-only.json"))
+	app, _ := newTestApp(t)
+	checkpoint := NewCheckpoint()
+	w := newWorker(1, app, nil, checkpoint)
+
+	for i := int64(0); i < 3; i++ {
+		event.TimeUS = 1736019684000000 + i
+		if err := w.handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checkpoint.Cursor() != 1736019684000000-1 {
+		t.Errorf("unexpected cursor before flush: %d", checkpoint.Cursor())
+	}
+
+	w.close()
+	if checkpoint.Cursor() != 1736019684000002 || checkpoint.Err() != nil {
+		t.Errorf("unexpected cursor after flush: %d (%v)", checkpoint.Cursor(), checkpoint.Err())
+	}
+
+	// Chunks that fail to flush are never released
+	app.Storage = failingStorage{app.Storage}
+	w = newWorker(2, app, nil, checkpoint)
+	event.TimeUS = 1736019684000003
+	w.handle(event)
+	w.close()
+	if checkpoint.Cursor() != 1736019684000002 || checkpoint.Err() == nil {
+		t.Errorf("unexpected cursor after failed flush: %d (%v)", checkpoint.Cursor(), checkpoint.Err())
+	}
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/georgemblack/blue-report/pkg/classify"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+const (
+	CategoryCandidates = 100 // Number of top links in each window considered for the category lists
+	CategoryListSize   = 5   // Number of links in each category list
+	MaxCategoryLookups = 50  // Number of links without a saved category that are classified in each run, as each may require fetching its title and asking the LLM
+)
+
+// categorizer assigns a category to each link, caching results for the duration of a run.
+// Categories are saved to the link's URL metadata, so each link is only classified once.
+type categorizer struct {
+	stg        Storage
+	classifier classify.Classifier
+	results    map[string]categoryResult
+	lookups    int // Number of links classified without a saved category, counted against 'MaxCategoryLookups'
+}
+
+type categoryResult struct {
+	category string
+	final    bool // Whether the category was decided by the classifier, or defaulted to 'other'
+}
+
+func newCategorizer(app App) *categorizer {
+	return &categorizer{
+		stg:        app.Storage,
+		classifier: app.Classifier,
+		results:    make(map[string]categoryResult),
+	}
+}
+
+// known returns the category of a link if it has been saved, or the link has already been classified during this run.
+func (c *categorizer) known(url string) (string, bool) {
+	if result, ok := c.results[url]; ok {
+		return result.category, true
+	}
+	metadata := getURLMetadata(c.stg, url)
+	if metadata.Category == "" {
+		return "", false
+	}
+	c.results[url] = categoryResult{category: metadata.Category, final: true}
+	return metadata.Category, true
+}
+
+// category returns the category of a link, or 'other' if it can't be classified.
+// The stored title is used if no title is given. Links the classifier can't decide on are not saved,
+// and are classified again if a title is given later (i.e. once the link has been hydrated).
+func (c *categorizer) category(url string, title string) string {
+	result, ok := c.results[url]
+	if ok && (result.final || title == "") {
+		return result.category
+	}
+
+	if !ok {
+		metadata := getURLMetadata(c.stg, url)
+		if metadata.Category != "" {
+			c.results[url] = categoryResult{category: metadata.Category, final: true}
+			return metadata.Category
+		}
+		if title == "" {
+			title = metadata.Title
+		}
+	}
+
+	category, err := c.classifier.Classify(url, title)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to classify link", err).Error(), "url", url)
+	}
+	if err != nil || category == "" {
+		c.results[url] = categoryResult{category: classify.Other}
+		return classify.Other
+	}
+
+	updateCategory(c.stg, url, category)
+	c.results[url] = categoryResult{category: category, final: true}
+	return category
+}
+
+// Build the top links in each category, for each window, from the top candidates in that window (i.e. after moderation).
+// Links are classified by title, so each candidate's title is resolved first (see 'resolveTitle'), and cached in 'titles'.
+// Links are not otherwise hydrated, and links without a category are skipped.
+//
+// Resolving titles and classifying links can require several requests per link, so only 'MaxCategoryLookups' links without a saved category
+// are classified in each run. Any others are left out of the lists until a later run, by which point more links will have been saved.
+//
+// Each list is selected using the diversity policy, as with the top links in each window.
+// Also returns the number of links demoted from each list, by category and window.
+func categoryLinks(app App, c *categorizer, windows []links.Window, candidates map[string][]string, titles map[string]string) (links.CategoryLinks, map[string]map[string]int) {
+	result := make(links.CategoryLinks)
+	demoted := make(map[string]map[string]int)
+	for _, category := range classify.Categories {
+		result[category] = make(links.TopLinks)
+		demoted[category] = make(map[string]int)
+	}
+
+	diversity := links.Diversity{MaxPerHost: app.Config.MaxLinksPerHost}
+	skipped := make(map[string]bool)
+	for _, window := range windows {
+		// Group the window's candidates by category, keeping their rank order
+		ranked := make(map[string][]string)
+		for _, url := range candidates[window.Name] {
+			if skipped[url] {
+				continue
+			}
+			category, ok := c.known(url)
+			if !ok {
+				if c.lookups >= MaxCategoryLookups {
+					skipped[url] = true
+					continue
+				}
+				c.lookups++
+				category = c.category(url, resolveTitle(app, titles, url))
+			}
+			ranked[category] = append(ranked[category], url)
+		}
+
+		for _, category := range classify.Categories {
+			selected, count := diversity.Select(ranked[category], CategoryListSize)
+			list := make([]links.Link, 0, len(selected))
+			for _, url := range selected {
+				list = append(list, links.Link{URL: url})
+			}
+			result[category][window.Name] = list
+			demoted[category][window.Name] = count
+		}
+	}
+
+	if len(skipped) > 0 {
+		slog.Info("skipped classifying links", "count", len(skipped), "limit", MaxCategoryLookups)
+	}
+	return result, demoted
+}
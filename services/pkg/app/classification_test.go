@@ -0,0 +1,151 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/classify"
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// countingClassifier wraps a classifier, counting how many links it classifies
+type countingClassifier struct {
+	classify.Classifier
+	calls int
+}
+
+func (c *countingClassifier) Classify(url, title string) (string, error) {
+	c.calls++
+	return c.Classifier.Classify(url, title)
+}
+
+// Test that categories are saved to URL metadata, without overwriting the title, and reused by later runs
+func TestCategorizer(t *testing.T) {
+	app, _ := newTestApp(t)
+	classifier := &countingClassifier{Classifier: classify.DefaultRules()}
+	app.Classifier = classifier
+
+	app.Storage.SaveURLMetadata(storage.URLMetadata{URL: "https://example.com/a", Title: "Senate passes budget"})
+
+	categories := newCategorizer(app)
+	if category := categories.category("https://example.com/a", ""); category != classify.Politics {
+		t.Errorf("unexpected category: %s", category)
+	}
+	metadata, _ := app.Storage.GetURLMetadata("https://example.com/a")
+	if metadata.Category != classify.Politics || metadata.Title != "Senate passes budget" {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+
+	// Links that can't be classified are not saved, and are classified again once a title is found
+	if category := categories.category("https://example.com/b", ""); category != classify.Other {
+		t.Errorf("unexpected category: %s", category)
+	}
+	if category := categories.category("https://example.com/b", "NBA playoffs preview"); category != classify.Sports {
+		t.Errorf("unexpected category: %s", category)
+	}
+
+	// A new run reads saved categories, without classifying again
+	classifier.calls = 0
+	categories = newCategorizer(app)
+	categories.category("https://example.com/a", "")
+	categories.category("https://example.com/b", "")
+	if classifier.calls != 0 {
+		t.Errorf("expected saved categories to be reused, got %d calls", classifier.calls)
+	}
+}
+
+// Test that category lists contain the top links in each category, in order, up to the list size
+func TestCategoryLinks(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.Classifier = classify.DefaultRules()
+
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	bounds := links.NewTimeBounds(now, []links.Window{{Name: "24h", Duration: 24 * time.Hour}})
+	aggregation := links.NewAggregation(bounds, links.DefaultLinearScorer(), dedupe.NewExact())
+
+	// Titles resolved during the run (i.e. while clustering) are used to classify links
+	titles := map[string]string{
+		"https://espn.com/a":    "Some story",
+		"https://example.com/a": "Some other story",
+		"https://example.com/b": "NBA playoffs preview",
+	}
+
+	// Tech links are more popular than sports links, and one link has no category
+	for i := 0; i < CategoryListSize+2; i++ {
+		url := fmt.Sprintf("https://theverge.com/%d", i)
+		titles[url] = fmt.Sprintf("Story %d", i)
+		for j := 0; j <= 20-i; j++ {
+			aggregation.CountEvent(0, url, fmt.Sprintf("post%d", j), fmt.Sprintf("did%d", j), now.Add(-time.Hour))
+		}
+	}
+	aggregation.CountEvent(0, "https://espn.com/a", "post", "did", now.Add(-time.Hour))
+	aggregation.CountEvent(0, "https://example.com/a", "post", "did", now.Add(-time.Hour))
+	aggregation.CountEvent(0, "https://example.com/b", "post", "did", now.Add(-2*time.Hour))
+
+	candidates := map[string][]string{"24h": aggregation.TopLinks("24h", CategoryCandidates)}
+	result, demoted := categoryLinks(app, newCategorizer(app), bounds.Windows, candidates, titles)
+	tech := result[classify.Tech]["24h"]
+	if len(tech) != CategoryListSize || tech[0].URL != "https://theverge.com/0" {
+		t.Errorf("unexpected tech links: %v", tech)
+	}
+	if sports := result[classify.Sports]["24h"]; len(sports) != 2 || sports[0].URL != "https://espn.com/a" || sports[1].URL != "https://example.com/b" {
+		t.Errorf("unexpected sports links: %v", sports)
+	}
+	if _, ok := result[classify.Other]; ok {
+		t.Error("expected no list for uncategorized links")
+	}
+	if demoted[classify.Tech]["24h"] != 0 {
+		t.Errorf("expected no demoted links without a limit, got %d", demoted[classify.Tech]["24h"])
+	}
+
+	// With a per-host limit, a single site can't fill a category's list
+	app.Config.MaxLinksPerHost = 2
+	result, demoted = categoryLinks(app, newCategorizer(app), bounds.Windows, candidates, titles)
+	if tech := result[classify.Tech]["24h"]; len(tech) != 2 || tech[1].URL != "https://theverge.com/1" {
+		t.Errorf("unexpected tech links: %v", tech)
+	}
+	if demoted[classify.Tech]["24h"] != CategoryListSize-2 || demoted[classify.Sports]["24h"] != 0 {
+		t.Errorf("unexpected demoted links: %v", demoted)
+	}
+}
+
+// Test that only a limited number of links without a saved category are classified in each run, and the rest are left out of the lists
+func TestCategoryLinksLookupLimit(t *testing.T) {
+	app, _ := newTestApp(t)
+	classifier := &countingClassifier{Classifier: classify.DefaultRules()}
+	app.Classifier = classifier
+
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	bounds := links.NewTimeBounds(now, []links.Window{{Name: "1h", Duration: time.Hour}, {Name: "24h", Duration: 24 * time.Hour}})
+	titles := make(map[string]string)
+	candidates := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		url := fmt.Sprintf("https://theverge.com/%d", i)
+		titles[url] = fmt.Sprintf("Story %d", i)
+		candidates = append(candidates, url)
+	}
+
+	// Two lookups remain, and links are shared between windows, so only the first two links are classified
+	categories := newCategorizer(app)
+	categories.lookups = MaxCategoryLookups - 2
+	result, _ := categoryLinks(app, categories, bounds.Windows, map[string][]string{"1h": candidates, "24h": candidates}, titles)
+	for _, window := range []string{"1h", "24h"} {
+		if tech := result[classify.Tech][window]; len(tech) != 2 || tech[1].URL != "https://theverge.com/1" {
+			t.Errorf("unexpected tech links in %s: %v", window, tech)
+		}
+	}
+	if classifier.calls != 2 {
+		t.Errorf("expected 2 links classified, got %d", classifier.calls)
+	}
+
+	// Saved categories don't count against the limit
+	categories = newCategorizer(app)
+	categories.lookups = MaxCategoryLookups
+	result, _ = categoryLinks(app, categories, bounds.Windows, map[string][]string{"24h": candidates}, titles)
+	if tech := result[classify.Tech]["24h"]; len(tech) != 2 {
+		t.Errorf("unexpected tech links: %v", tech)
+	}
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"github.com/georgemblack/blue-report/pkg/links"
+)
+
+// ClusterCandidates is the number of top links in each window considered when grouping links by story.
+const ClusterCandidates = 30
+
+// Group the top links in a window by story, and return the top 'n' stories.
+// Each story is listed once, using its highest ranked link, so other outlets covering the same story don't fill the list.
+// Titles are read from URL metadata (and cached in 'titles'), so links that have never been hydrated are only grouped by co-sharing.
+//
+// Stories are then selected using the diversity policy, limiting the number of links from each host.
+// Also returns the number of stories demoted by the policy.
+func clusterLinks(app App, aggregation *links.Aggregation, urls []string, titles map[string]string, n int) ([]links.Link, int) {
+	stories := make([]links.Story, 0, len(urls))
+	for _, url := range urls {
+		title, ok := titles[url]
+		if !ok {
+			title = getTitle(app.Storage, url)
+			titles[url] = title
+		}
+		stats := aggregation.Get(url)
+		stories = append(stories, links.Story{URL: url, Title: title, Authors: stats.Authors()})
+	}
+
+	clusters := make(map[string]links.Cluster)
+	primaries := make([]string, 0, len(stories))
+	for _, cluster := range links.ClusterStories(stories) {
+		clusters[cluster.Primary.URL] = cluster
+		primaries = append(primaries, cluster.Primary.URL)
+	}
+
+	diversity := links.Diversity{MaxPerHost: app.Config.MaxLinksPerHost}
+	selected, demoted := diversity.Select(primaries, n)
+
+	list := make([]links.Link, 0, len(selected))
+	for _, url := range selected {
+		cluster := clusters[url]
+		coverage := make([]links.Coverage, 0, len(cluster.Others))
+		for _, other := range cluster.Others {
+			coverage = append(coverage, links.Coverage{URL: other.URL, Title: other.Title})
+		}
+		list = append(list, links.Link{URL: cluster.Primary.URL, AlsoCoveredBy: coverage})
+	}
+
+	return list, demoted
+}
@@ -1,24 +1,25 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/georgemblack/blue-report/pkg/cache"
+	"github.com/georgemblack/blue-report/pkg/labels"
 	"github.com/georgemblack/blue-report/pkg/queue"
 	"github.com/georgemblack/blue-report/pkg/storage"
 	"github.com/georgemblack/blue-report/pkg/urltools"
 	"github.com/georgemblack/blue-report/pkg/util"
-	"github.com/gorilla/websocket"
 )
 
 const (
-	WorkerPoolSize   = 1
 	StreamBufferSize = 10000
 	EventBufferSize  = 10000
-	ErrorThreshold   = 10
+	ErrorThreshold   = 10 // Number of consecutive failed attempts to read from the Jetstream before exiting
 	JetstreamURL     = "wss://jetstream1.us-west.bsky.network/subscribe?wantedCollections=app.bsky.feed.post&wantedCollections=app.bsky.feed.repost&wantedCollections=app.bsky.feed.like"
 )
 
@@ -44,7 +45,9 @@ func newStats() Stats {
 	}
 }
 
-func Intake() error {
+// Intake reads events from the Jetstream and writes them to storage until the context is cancelled.
+// On shutdown, every worker flushes its partially filled buffer before exiting, so no events are dropped.
+func Intake(ctx context.Context) error {
 	slog.Info("starting intake")
 
 	app, err := NewApp()
@@ -53,171 +56,248 @@ func Intake() error {
 	}
 	defer app.Close()
 
-	// Start worker threads
-	var wg sync.WaitGroup
-	wg.Add(WorkerPoolSize)
-	stream := make(chan StreamEvent, StreamBufferSize)
-	shutdown := make(chan struct{})
-	for i := 0; i < WorkerPoolSize; i++ {
-		go intakeWorker(i+1, stream, shutdown, app, &wg)
-	}
-
-	// Connect to Jetstream
-	conn, _, err := websocket.DefaultDialer.Dial(JetstreamURL, nil)
+	app.Blocklist, err = newBlocklist(app.Storage)
 	if err != nil {
-		return util.WrapErr("failed to dial jetstream", err)
+		return err
 	}
-	defer conn.Close()
 
-	// Send Jetstream messages to workers
-	errors := 0
-	for {
-		event := StreamEvent{}
-		err := conn.ReadJSON(&event)
-		if err != nil {
-			errors++
-			slog.Warn(util.WrapErr("failed to read json", err).Error())
+	// Start worker threads. Workers report the events they have flushed to the checkpoint.
+	var wg sync.WaitGroup
+	wg.Add(app.Config.IntakeWorkers)
+	stream := make(chan StreamEvent, StreamBufferSize)
+	checkpoint := NewCheckpoint()
+	for i := 0; i < app.Config.IntakeWorkers; i++ {
+		go intakeWorker(i+1, stream, app, checkpoint, &wg)
+	}
 
-			if errors > ErrorThreshold {
-				slog.Error("encountered too many errors reading from jetstream")
-				break
-			}
+	// Read events from the Jetstream and send them to workers.
+	// This returns once the context is cancelled, or the Jetstream has failed too many consecutive times.
+	// The cursor saved along the way is taken from the checkpoint, so it only covers events that have been flushed.
+	jetstream := NewJetstream(JetstreamURL, app.Cache)
+	jetstream.checkpoint = checkpoint
+	streamErr := jetstream.Stream(ctx, stream)
 
-			continue
-		}
+	// Signal workers to exit by closing the stream. Workers drain any remaining events and flush their buffers.
+	slog.Info("shutting down intake, waiting for workers to flush events")
+	close(stream)
+	wg.Wait()
 
-		stream <- event
+	// Only save the final cursor once all events have been flushed.
+	// If any flush failed, the saved cursor is left before the lost events, so they are read again on restart.
+	if err := checkpoint.Err(); err != nil {
+		return errors.Join(streamErr, util.WrapErr("failed to flush events", err))
 	}
-
-	// Signal workers to exit, and wait for them to finish
-	close(shutdown)
-	wg.Wait()
-	return nil
+	jetstream.saveCursor(true)
+	slog.Info("intake shut down", "cursor", checkpoint.Cursor())
+	return streamErr
 }
 
 // The intakeWorker is responsible for processing events from the stream. This includes:
 // - Determining whether the event is valid (i.e. a post, like, or repost, and references a URL)
 // - Transforming the event into a storage record (and saving to S3)
 // - Updating metadata in the cache
-func intakeWorker(id int, stream chan StreamEvent, shutdown chan struct{}, app App, wg *sync.WaitGroup) {
+//
+// The worker exits once the stream is closed. Before exiting, any buffered events are flushed, and in-flight flushes are awaited.
+func intakeWorker(id int, stream chan StreamEvent, app App, checkpoint *Checkpoint, wg *sync.WaitGroup) {
 	slog.Info(fmt.Sprintf("starting worker %d", id))
 	defer wg.Done()
 
-	buffer := make([]storage.EventRecord, 0, EventBufferSize) // Aggregate records before writing to storage
-	stats := newStats()
-
-	for {
-		event := StreamEvent{}
-		ok := true
-
-		select {
-		case event, ok = <-stream:
-			if !ok {
-				slog.Error("error reading message from channel")
-				continue
-			}
-		case <-shutdown:
-			slog.Info(fmt.Sprintf("shutting down worker %d", id))
+	w := newWorker(id, app, stream, checkpoint)
+
+	// However the worker exits, flush the partial buffer and wait for all flushes to complete
+	defer func() {
+		w.close()
+		slog.Info(fmt.Sprintf("shut down worker %d", id))
+	}()
+
+	for event := range stream {
+		err := w.handle(event)
+		if err != nil {
+			slog.Error(err.Error())
 			return
 		}
+	}
+}
 
-		// Check whether event is a valid post, repost, or like
-		if !event.Valid() {
-			stats.invalid++
-			continue
-		}
+// worker holds the state of a single intake worker, i.e. the buffer of events waiting to be flushed.
+// Events are passed to 'handle' one at a time, so the worker can also be driven directly (i.e. when replaying events).
+type worker struct {
+	id         int
+	app        App
+	stream     chan StreamEvent // Only used to report the queue length
+	checkpoint *Checkpoint      // Records the events that have been flushed, or nil
+	instance   string
+	languages  []string              // Languages of posts to count
+	buffer     []storage.EventRecord // Aggregate records before writing to storage
+	stats      Stats
+	sequence   int
+	flushes    sync.WaitGroup // Track in-flight flushes
+}
 
-		stRecord := storage.EventRecord{}
-		urlRecord := cache.URLRecord{}
-		skip := false
-		err := error(nil)
+func newWorker(id int, app App, stream chan StreamEvent, checkpoint *Checkpoint) *worker {
+	// Chunks written by this worker are identified by instance, worker ID, and sequence number.
+	// This prevents collisions with chunks written by other workers (or instances) in the same second.
+	instance := storage.InstanceName(app.Config.InstanceID)
+	if instance == "" {
+		instance = "intake"
+	}
 
-		if event.IsPost() && !event.IsQuotePost() {
-			stRecord, skip, err = handlePost(app.Cache, event)
-		}
-		if event.IsPost() && event.IsQuotePost() {
-			stRecord, skip, err = handleQuotePost(app.Cache, event)
-		}
-		if event.IsLike() || event.IsRepost() {
-			stRecord, skip, err = handleLikeOrRepost(app.Cache, event)
+	return &worker{
+		id:         id,
+		app:        app,
+		stream:     stream,
+		checkpoint: checkpoint,
+		instance:   instance,
+		languages:  app.Config.ReportLanguages(),
+		buffer:     make([]storage.EventRecord, 0, EventBufferSize),
+		stats:      newStats(),
+	}
+}
+
+// Process a single event from the stream. An error is returned if the worker can no longer continue.
+// Once the event has been buffered (or discarded), it is reported to the checkpoint.
+func (w *worker) handle(event StreamEvent) error {
+	err := w.process(event)
+	w.checkpoint.Handled(event.TimeUS)
+	return err
+}
+
+func (w *worker) process(event StreamEvent) error {
+	// Check whether event is a valid post, repost, or like
+	if !event.Valid(w.languages) {
+		w.stats.invalid++
+		return nil
+	}
+
+	stRecord := storage.EventRecord{}
+	urlRecord := cache.URLRecord{}
+	skip := false
+	err := error(nil)
+
+	if event.IsPost() && !event.IsQuotePost() {
+		stRecord, skip, err = handlePost(w.app.Cache, event, w.languages)
+	}
+	if event.IsPost() && event.IsQuotePost() {
+		stRecord, skip, err = handleQuotePost(w.app.Cache, event, w.languages)
+	}
+	if event.IsLike() || event.IsRepost() {
+		stRecord, skip, err = handleLikeOrRepost(w.app.Cache, event)
+	}
+
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to handle event", err).Error())
+		w.stats.errors++
+		return nil
+	}
+	if skip {
+		w.stats.skipped++
+		return nil
+	}
+
+	// Skip URLs excluded by the blocklist. They are also removed during aggregation, so rules added later still apply to past events.
+	if w.app.Blocklist != nil {
+		if _, blocked := w.app.Blocklist.Match(stRecord.URL); blocked {
+			w.stats.skipped++
+			return nil
 		}
+	}
+
+	// Update stats with event type
+	if event.IsPost() {
+		w.stats.posts++
+	}
+	if event.IsLike() {
+		w.stats.likes++
+	}
+	if event.IsRepost() {
+		w.stats.reposts++
+	}
 
+	// Fetch the URL record from the cache.
+	// With multiple workers, concurrent updates to the same record may occasionally be lost. This is acceptable, as the count is only used as a threshold.
+	urlRecord, err = w.app.Cache.ReadURL(util.Hash(stRecord.URL))
+	if err != nil {
+		return util.WrapErr("failed to read url record", err)
+	}
+
+	// Increment the number of interactions for the URL
+	urlRecord.Interactions++
+
+	// If the total number of interactions reaches a threshold, send URL to the normalization queue.
+	// Prevent sending the same URL to the queue multiple times
+	if urlRecord.Interactions >= 1000 && !urlRecord.Normalized {
+		err = w.app.Queue.Send(queue.Message{URL: stRecord.URL})
 		if err != nil {
-			slog.Warn(util.WrapErr("failed to handle event", err).Error())
-			stats.errors++
-			continue
-		}
-		if skip {
-			stats.skipped++
-			continue
+			return util.WrapErr("failed to send message to queue", err)
 		}
+		urlRecord.Normalized = true
+	}
 
-		// Update stats with event type
-		if event.IsPost() {
-			stats.posts++
-		}
-		if event.IsLike() {
-			stats.likes++
-		}
-		if event.IsRepost() {
-			stats.reposts++
-		}
+	// Save or update the URL record to cache.
+	// This also has the side-effect of refreshing the TTL of the record.
+	err = w.app.Cache.SaveURL(util.Hash(stRecord.URL), urlRecord)
+	if err != nil {
+		return util.WrapErr("failed to save url record", err)
+	}
 
-		// Fetch the URL record from the cache
-		urlRecord, err = app.Cache.ReadURL(util.Hash(stRecord.URL))
+	// Update the live aggregation, if enabled. Only the default report's language is aggregated live, and labels are checked against the policy up front.
+	// Failures are not fatal, as the event is still saved to storage for batch aggregation.
+	if w.app.Live != nil && stRecord.Language == w.languages[0] && w.app.Config.LabelPolicy.Counted(stRecord.Labels) {
+		err = w.app.Live.Record(stRecord)
 		if err != nil {
-			slog.Error(util.WrapErr("failed to read url record", err).Error())
-			return
+			slog.Warn(util.WrapErr("failed to update live aggregation", err).Error())
 		}
+	}
 
-		// Increment the number of interactions for the URL
-		urlRecord.Interactions++
-
-		// If the total number of interactions reaches a threshold, send URL to the normalization queue.
-		// Prevent sending the same URL to the queue multiple times
-		if urlRecord.Interactions >= 1000 && !urlRecord.Normalized {
-			err = app.Queue.Send(queue.Message{URL: stRecord.URL})
-			if err != nil {
-				slog.Error(util.WrapErr("failed to send message to queue", err).Error())
-				return
-			}
-			urlRecord.Normalized = true
-		}
+	// Save event to the buffer. The chunk starts at the time of its first event.
+	// Once the buffer is full, write to storage asynchronously.
+	// The chunk is held by the checkpoint until it has been flushed.
+	if len(w.buffer) == 0 {
+		w.stats.start = stRecord.Timestamp
+		w.checkpoint.Hold(w.id, w.sequence, event.TimeUS)
+	}
+	w.buffer = append(w.buffer, stRecord)
+	if len(w.buffer) >= EventBufferSize {
+		w.flush()
+	}
+	return nil
+}
 
-		// Save or update the URL record to cache.
-		// This also has the side-effect of refreshing the TTL of the record.
-		err = app.Cache.SaveURL(util.Hash(stRecord.URL), urlRecord)
+// Write buffered records to storage asynchronously, then reset the buffer & stats.
+func (w *worker) flush() {
+	records, counts, sequence := w.buffer, w.stats, w.sequence
+	key := storage.ChunkKey{Start: counts.start, Instance: w.instance, Worker: w.id, Sequence: sequence}
+	queue := len(w.stream)
+
+	w.sequence++
+	w.buffer = make([]storage.EventRecord, 0, EventBufferSize)
+	w.stats = newStats()
+
+	w.flushes.Add(1)
+	go func() {
+		defer w.flushes.Done()
+		err := w.app.Storage.FlushEvents(key, records)
 		if err != nil {
-			slog.Error(util.WrapErr("failed to save url record", err).Error())
-			return
+			slog.Warn(util.WrapErr("failed to write events", err).Error())
+			w.checkpoint.Fail(util.WrapErr(fmt.Sprintf("failed to write chunk %s", key.String()), err))
+		} else {
+			w.checkpoint.Release(w.id, sequence)
+			slog.Info("flushed events to storage", "chunk", key.String(), "posts", counts.posts, "reposts", counts.reposts, "likes", counts.likes, "skipped", counts.skipped, "invalid", counts.invalid, "errors", counts.errors, "queue", queue)
 		}
+	}()
+}
 
-		// Save event to the buffer.
-		// Once the buffer is full, write to storage asynchronously.
-		buffer = append(buffer, stRecord)
-		if len(buffer) >= EventBufferSize {
-			// Create local copies of buffer & stats to prevent the reset from occurring before the flush
-			localBuffer := buffer
-			localStats := stats
-			queue := len(stream)
-
-			go func() {
-				err = app.Storage.FlushEvents(localStats.start, localBuffer)
-				if err != nil {
-					slog.Warn(util.WrapErr("failed to write events", err).Error())
-				} else {
-					slog.Info("flushed events to storage", "posts", localStats.posts, "reposts", localStats.reposts, "likes", localStats.likes, "skipped", localStats.skipped, "invalid", localStats.invalid, "errors", localStats.errors, "queue", queue)
-				}
-			}()
-			buffer = make([]storage.EventRecord, 0, EventBufferSize)
-			stats = newStats()
-		}
+// Flush any partially filled buffer, and wait for all flushes to complete.
+func (w *worker) close() {
+	if len(w.buffer) > 0 {
+		w.flush()
 	}
+	w.flushes.Wait()
 }
 
 // handlePost processes a 'post' stream event.
 // The post is also saved to the cache to be later referenced by quote posts, reposts, and likes.
-func handlePost(ch Cache, event StreamEvent) (storage.EventRecord, bool, error) {
+func handlePost(ch Cache, event StreamEvent, languages []string) (storage.EventRecord, bool, error) {
 	url, _, _ := event.ParsePost()
 
 	// Filter out unwanted URLs (or posts with no URL)
@@ -226,10 +306,14 @@ func handlePost(ch Cache, event StreamEvent) (storage.EventRecord, bool, error)
 	}
 
 	cleanedURL := urltools.Clean(url)
+	language := event.Language(languages)
+	selfLabels := event.Labels()
 
 	// Add the post to the cache, so it can be quickly referenced by reposts and likes.
 	post := cache.PostRecord{
-		URL: cleanedURL,
+		URL:      cleanedURL,
+		Language: language,
+		Labels:   selfLabels,
 	}
 	ch.SavePost(util.Hash(event.Commit.CID), post)
 
@@ -238,15 +322,19 @@ func handlePost(ch Cache, event StreamEvent) (storage.EventRecord, bool, error)
 		Type:      event.TypeOf(),
 		URL:       cleanedURL,
 		DID:       event.DID,
-		Timestamp: time.Now(),
+		Timestamp: event.Time(),
 		Post:      fmt.Sprintf("at://%s/app.bsky.feed.post/%s", event.DID, event.Commit.RKey), // AT URI of the current post
+		Language:  language,
+		Labels:    selfLabels,
 	}
 	return stgRecord, false, nil
 }
 
 // handleQuotePost processes a 'quote post' stream event.
 // If the embed references a post in the cache, return a storage event and URL record to save.
-func handleQuotePost(ch Cache, event StreamEvent) (storage.EventRecord, bool, error) {
+// Quote posts are written by the user, so they are counted in their own language, rather than the language of the embedded post.
+// Quote posts carry both their own labels, and those of the embedded post.
+func handleQuotePost(ch Cache, event StreamEvent, languages []string) (storage.EventRecord, bool, error) {
 	postCID := event.Commit.Record.Embed.Record.CID
 	postHash := util.Hash(postCID)
 	postRecord, err := ch.ReadPost(postHash)
@@ -269,8 +357,10 @@ func handleQuotePost(ch Cache, event StreamEvent) (storage.EventRecord, bool, er
 		Type:      event.TypeOf(),
 		URL:       postRecord.URL,
 		DID:       event.DID,
-		Timestamp: time.Now(),
+		Timestamp: event.Time(),
 		Post:      event.Commit.Record.Embed.Record.URI, // AT URI of the embedded post
+		Language:  event.Language(languages),
+		Labels:    labels.Merge(event.Labels(), postRecord.Labels),
 	}
 	return stgRecord, false, nil
 }
@@ -299,8 +389,18 @@ func handleLikeOrRepost(ch Cache, event StreamEvent) (storage.EventRecord, bool,
 		Type:      event.TypeOf(),
 		URL:       postRecord.URL,
 		DID:       event.DID,
-		Timestamp: time.Now(),
+		Timestamp: event.Time(),
 		Post:      event.Commit.Record.Subject.URI, // AT URI of the liked/reposted post
+		Language:  postLanguage(postRecord),
+		Labels:    postRecord.Labels,
 	}
 	return stgRecord, false, nil
 }
+
+// Return the language of a cached post. Posts cached before languages were recorded were always English.
+func postLanguage(post cache.PostRecord) string {
+	if post.Language == "" {
+		return storage.LegacyLanguage
+	}
+	return post.Language
+}
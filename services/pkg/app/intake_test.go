@@ -2,26 +2,26 @@ package app
 
 import (
 	"encoding/json"
+	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/georgemblack/blue-report/pkg/cache"
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/queue"
 	"github.com/georgemblack/blue-report/pkg/storage"
 	"github.com/georgemblack/blue-report/pkg/testutil"
 	"github.com/georgemblack/blue-report/pkg/util"
-	"go.uber.org/mock/gomock"
 )
 
 func TestHandlePostNoURL(t *testing.T) {
 	bytes := testutil.GetTestData("post-no-url.json")
 	event := toStreamEvent(bytes)
 
-	// The cache should not be called – the event does not contain a URL
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockCache.EXPECT().ReadPost(gomock.Any()).Times(0)
-	mockCache.EXPECT().SavePost(gomock.Any(), gomock.Any()).Times(0)
-
-	_, skip, err := handlePost(mockCache, event)
+	// Nothing should be saved to the cache – the event does not contain a URL
+	ch := cache.NewMemory()
+	_, skip, err := handlePost(ch, event, []string{"en"})
 
 	if !skip {
 		t.Error("expected event to be skipped")
@@ -29,6 +29,9 @@ func TestHandlePostNoURL(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if post, _ := ch.ReadPost(util.Hash(event.Commit.CID)); post.Valid() {
+		t.Errorf("unexpected post saved to cache: %v", post)
+	}
 }
 
 func TestHandlePostWithEmbed(t *testing.T) {
@@ -36,14 +39,13 @@ func TestHandlePostWithEmbed(t *testing.T) {
 	event := toStreamEvent(bytes)
 
 	expectedPost := cache.PostRecord{
-		URL: "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge",
+		URL:      "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge",
+		Language: "en",
 	}
 	hashedCID := util.Hash("bafyreiehzp2ehowobuutnjsednkq24iisx2mzpdc27yuy4xztspcqid3ni")
 
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockCache.EXPECT().SavePost(hashedCID, expectedPost) // Save the post
-
-	stg, skip, err := handlePost(mockCache, event)
+	ch := cache.NewMemory()
+	stg, skip, err := handlePost(ch, event, []string{"en"})
 
 	if skip {
 		t.Error("unexpected event skip")
@@ -57,6 +59,9 @@ func TestHandlePostWithEmbed(t *testing.T) {
 	if stg.Post != "at://did:plc:ruzlll5u7u7pfxybmppqyxbx/app.bsky.feed.post/3ldkcy6xjvc2l" {
 		t.Errorf("unexpected at uri for post: %s", stg.Post)
 	}
+	if post, _ := ch.ReadPost(hashedCID); !reflect.DeepEqual(post, expectedPost) {
+		t.Errorf("unexpected post saved to cache: %v", post)
+	}
 }
 
 // Save a post with a URL that has already been cached. The cached URL is missing data, so it should be updated.
@@ -65,14 +70,14 @@ func TestHandlePostWithPartiallySavedURL(t *testing.T) {
 	event := toStreamEvent(bytes)
 
 	expectedPost := cache.PostRecord{
-		URL: "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge",
+		URL:      "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge",
+		Language: "en",
 	}
 	hashedCID := util.Hash("bafyreiehzp2ehowobuutnjsednkq24iisx2mzpdc27yuy4xztspcqid3ni")
 
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockCache.EXPECT().SavePost(hashedCID, expectedPost)
-
-	stg, skip, err := handlePost(mockCache, event)
+	ch := cache.NewMemory()
+	ch.SaveURL(util.Hash(expectedPost.URL), cache.URLRecord{Interactions: 1})
+	stg, skip, err := handlePost(ch, event, []string{"en"})
 
 	if skip {
 		t.Error("unexpected event skip")
@@ -86,6 +91,9 @@ func TestHandlePostWithPartiallySavedURL(t *testing.T) {
 	if stg.Post != "at://did:plc:ruzlll5u7u7pfxybmppqyxbx/app.bsky.feed.post/3ldkcy6xjvc2l" {
 		t.Errorf("unexpected at uri for post: %s", stg.Post)
 	}
+	if post, _ := ch.ReadPost(hashedCID); !reflect.DeepEqual(post, expectedPost) {
+		t.Errorf("unexpected post saved to cache: %v", post)
+	}
 }
 
 // Test handling a quote post
@@ -101,11 +109,13 @@ func TestHandleQuotePost(t *testing.T) {
 
 	hashedCID := util.Hash("bafyreihhlj7nktvq3h6issjqxor5ldy7yq64qv5wk5jawqeorfhn65evoe")
 
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockCache.EXPECT().ReadPost(hashedCID).Return(cache.PostRecord{URL: expectedRecord.URL}, nil) // Check for existing post record (it exists)
-	mockCache.EXPECT().RefreshPost(hashedCID).Return(nil)                                         // Refresh the TTL of the referenced post
+	// The referenced post was saved to the cache almost 12 hours ago
+	now := time.Now()
+	ch := cache.NewMemoryWithClock(func() time.Time { return now })
+	ch.SavePost(hashedCID, cache.PostRecord{URL: expectedRecord.URL})
+	now = now.Add(11 * time.Hour)
 
-	stg, skip, err := handleQuotePost(mockCache, event)
+	stg, skip, err := handleQuotePost(ch, event, []string{"en"})
 
 	if err != nil {
 		t.Fatal(err)
@@ -125,6 +135,12 @@ func TestHandleQuotePost(t *testing.T) {
 	if stg.Post != "at://did:plc:ruzlll5u7u7pfxybmppqyxbx/app.bsky.feed.post/3lewu3lbitc2v" {
 		t.Errorf("unexpected at uri for post: %s", stg.Post)
 	}
+
+	// The TTL of the referenced post should have been refreshed
+	now = now.Add(11 * time.Hour)
+	if post, _ := ch.ReadPost(hashedCID); !post.Valid() {
+		t.Error("expected referenced post to be refreshed")
+	}
 }
 
 // Test handling a quote post that references a post that doesn't exist in the cache.
@@ -134,10 +150,13 @@ func TestHandleQuotePostWithInvalidReference(t *testing.T) {
 
 	hashedCID := util.Hash("bafyreihhlj7nktvq3h6issjqxor5ldy7yq64qv5wk5jawqeorfhn65evoe")
 
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockCache.EXPECT().ReadPost(hashedCID).Return(cache.PostRecord{}, nil) // Check for existing post record (it doesn't exist)
+	// The referenced post has expired from the cache
+	now := time.Now()
+	ch := cache.NewMemoryWithClock(func() time.Time { return now })
+	ch.SavePost(hashedCID, cache.PostRecord{URL: "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge"})
+	now = now.Add(13 * time.Hour)
 
-	_, skip, err := handleQuotePost(mockCache, event)
+	_, skip, err := handleQuotePost(ch, event, []string{"en"})
 
 	if err != nil {
 		t.Fatal(err)
@@ -148,73 +167,238 @@ func TestHandleQuotePostWithInvalidReference(t *testing.T) {
 }
 
 // Test worker with an invalid event.
-// Cache and storage APIs should not be called.
+// Nothing should be saved to the cache or storage.
 func TestWorkerWithInvalidEvent(t *testing.T) {
 	bytes := testutil.GetTestData("invalid-event.json")
 	event := toStreamEvent(bytes)
 
+	app, ch := newTestApp(t)
 	stream := make(chan StreamEvent, 1)
-	shutdown := make(chan struct{})
 	var wg sync.WaitGroup
 
-	// Mock cache and storage should not be called
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockStorage := testutil.NewMockStorage(gomock.NewController(t))
-	mockCache.EXPECT().ReadPost(gomock.Any()).Times(0)
-	mockCache.EXPECT().SavePost(gomock.Any(), gomock.Any()).Times(0)
-	mockCache.EXPECT().ReadURL(gomock.Any()).Times(0)
-	mockCache.EXPECT().SaveURL(gomock.Any(), gomock.Any()).Times(0)
-	mockStorage.EXPECT().FlushEvents(gomock.Any(), gomock.Any()).Times(0)
-
-	app := App{
-		Cache:   mockCache,
-		Storage: mockStorage,
-	}
-
 	wg.Add(1)
-	go intakeWorker(1, stream, shutdown, app, &wg)
+	go intakeWorker(1, stream, app, nil, &wg)
 
 	// Send the event to the worker
 	stream <- event
 
-	close(shutdown)
+	close(stream)
 	wg.Wait()
+
+	if post, _ := ch.ReadPost(util.Hash(event.Commit.CID)); post.Valid() {
+		t.Errorf("unexpected post saved to cache: %v", post)
+	}
+	if records := readTestEvents(t, app); len(records) != 0 {
+		t.Errorf("expected no events, got %d", len(records))
+	}
 }
 
 // Test worker a number of events that match the buffer size, to ensure events are flushed to storage.
-// TODO: This is flakey, fix it.
+// The URL passes the interaction threshold along the way, so it should be sent to the normalization queue exactly once.
 func TestWorkerWithFlush(t *testing.T) {
 	bytes := testutil.GetTestData("post-facet-only.json")
 	event := toStreamEvent(bytes)
 
+	app, _ := newTestApp(t)
 	stream := make(chan StreamEvent, 1)
-	shutdown := make(chan struct{})
 	var wg sync.WaitGroup
 
-	// Ensure mock storage is called once to flush events, with correct input.
-	// Ignore calls to mock cache – outside the scope of this test.
-	mockCache := testutil.NewMockCache(gomock.NewController(t))
-	mockCache.EXPECT().SavePost(gomock.Any(), gomock.Any()).AnyTimes()
-	mockCache.EXPECT().SaveURL(gomock.Any(), gomock.Any()).AnyTimes()
-	mockCache.EXPECT().ReadURL(gomock.Any()).AnyTimes()
-	mockStorage := testutil.NewMockStorage(gomock.NewController(t))
-	mockStorage.EXPECT().FlushEvents(gomock.Any(), gomock.Any()).Times(1)
-
-	app := App{
-		Cache:   mockCache,
-		Storage: mockStorage,
-	}
-
 	wg.Add(1)
-	go intakeWorker(1, stream, shutdown, app, &wg)
+	go intakeWorker(1, stream, app, nil, &wg)
 
 	// Send the event to the worker
 	for i := 0; i < EventBufferSize; i++ {
 		stream <- event
 	}
 
-	close(shutdown)
+	close(stream)
+	wg.Wait()
+
+	chunks, _ := app.Storage.ListEventChunks(time.Time{}, time.Now())
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(chunks))
+	}
+	if records := readTestEvents(t, app); len(records) != EventBufferSize {
+		t.Errorf("expected %d events, got %d", EventBufferSize, len(records))
+	}
+
+	messages, _ := app.Queue.Receive()
+	if len(messages) != 1 {
+		t.Errorf("expected 1 queued url, got %d", len(messages))
+	}
+}
+
+// Test worker shutdown with a partially filled buffer, to ensure remaining events are flushed before the worker exits.
+func TestWorkerFlushesOnShutdown(t *testing.T) {
+	bytes := testutil.GetTestData("post-facet-only.json")
+	event := toStreamEvent(bytes)
+
+	app, _ := newTestApp(t)
+	stream := make(chan StreamEvent, 3)
+	var wg sync.WaitGroup
+
+	// Events are queued before the worker starts, and the stream is closed immediately.
+	// The worker should still process all three events before exiting.
+	for i := 0; i < 3; i++ {
+		stream <- event
+	}
+	close(stream)
+
+	wg.Add(1)
+	go intakeWorker(1, stream, app, nil, &wg)
+	wg.Wait()
+
+	if records := readTestEvents(t, app); len(records) != 3 {
+		t.Errorf("expected 3 events, got %d", len(records))
+	}
+}
+
+// Test a like that references a post seen earlier in the stream.
+// Once the post expires from the cache, likes referencing it are skipped.
+func TestWorkerWithLikeOfCachedPost(t *testing.T) {
+	post := toStreamEvent(testutil.GetTestData("post-embed-only.json"))
+	like := toStreamEvent(testutil.GetTestData("like.json"))
+	like.Commit.Record.Subject.CID = post.Commit.CID
+
+	now := time.Now()
+	ch := cache.NewMemoryWithClock(func() time.Time { return now })
+	app, _ := newTestApp(t)
+	app.Cache = ch
+
+	run := func(events ...StreamEvent) {
+		stream := make(chan StreamEvent, len(events))
+		for _, event := range events {
+			stream <- event
+		}
+		close(stream)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go intakeWorker(1, stream, app, nil, &wg)
+		wg.Wait()
+	}
+
+	run(post, like)
+	now = now.Add(13 * time.Hour)
+	run(like)
+
+	records := readTestEvents(t, app)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(records))
+	}
+	if !records[1].IsLike() || records[1].URL != "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge" {
+		t.Errorf("unexpected like event: %v", records[1])
+	}
+}
+
+// Test that posts are only counted in the configured languages, and likes inherit the language of the post they reference
+func TestWorkerLanguages(t *testing.T) {
+	post := toStreamEvent(testutil.GetTestData("post-embed-only.json"))
+	post.Commit.Record.Languages = []string{"ja"}
+	like := toStreamEvent(testutil.GetTestData("like.json"))
+	like.Commit.Record.Subject.CID = post.Commit.CID
+
+	run := func(languages []string) []storage.EventRecord {
+		app, _ := newTestApp(t)
+		app.Config.Languages = languages
+		stream := make(chan StreamEvent, 2)
+		stream <- post
+		stream <- like
+		close(stream)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go intakeWorker(1, stream, app, nil, &wg)
+		wg.Wait()
+		return readTestEvents(t, app)
+	}
+
+	// Japanese posts are not counted by default, so the like is skipped too
+	if records := run(nil); len(records) != 0 {
+		t.Errorf("expected no events, got %d", len(records))
+	}
+
+	records := run([]string{"en", "ja"})
+	if len(records) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(records))
+	}
+	for _, record := range records {
+		if record.Language != "ja" {
+			t.Errorf("unexpected language: %v", record)
+		}
+	}
+}
+
+// Test that self-labels are recorded on posts, and inherited by likes of the post
+func TestWorkerLabels(t *testing.T) {
+	post := toStreamEvent(testutil.GetTestData("post-embed-only.json"))
+	post.Commit.Record.Labels = Labels{Type: "com.atproto.label.defs#selfLabels", Values: []Label{{Val: "porn"}}}
+	like := toStreamEvent(testutil.GetTestData("like.json"))
+	like.Commit.Record.Subject.CID = post.Commit.CID
+
+	app, _ := newTestApp(t)
+	stream := make(chan StreamEvent, 2)
+	stream <- post
+	stream <- like
+	close(stream)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go intakeWorker(1, stream, app, nil, &wg)
 	wg.Wait()
+
+	records := readTestEvents(t, app)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(records))
+	}
+	for _, record := range records {
+		if len(record.Labels) != 1 || record.Labels[0] != "porn" {
+			t.Errorf("unexpected labels: %v", record)
+		}
+	}
+}
+
+// Create an app backed by an in-memory cache, and local storage & queue in a temporary directory
+func newTestApp(t *testing.T) (App, *cache.Memory) {
+	cfg := config.Config{
+		CacheBackend:          config.CacheBackendMemory,
+		EventCodec:            "columnar",
+		StorageBackend:        config.StorageBackendLocal,
+		LocalStorageDir:       t.TempDir(),
+		NoralizationQueueName: "test",
+		RedirectMaxHops:       2,
+		RedirectTimeout:       time.Second,
+	}
+	st, err := storage.NewLocal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := queue.NewLocal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := cache.NewMemory()
+	return App{Config: cfg, Cache: ch, Storage: st, Queue: q}, ch
+}
+
+// Read all events written to storage, in order.
+// Events are timestamped by the Jetstream, so test data produces chunks in the past.
+func readTestEvents(t *testing.T, app App) []storage.EventRecord {
+	chunks, err := app.Storage.ListEventChunks(time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := make([]storage.EventRecord, 0)
+	for _, chunk := range chunks {
+		events, err := app.Storage.ReadEvents(chunk, EventBufferSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, events...)
+	}
+	return records
 }
 
 func toStreamEvent(bytes []byte) StreamEvent {
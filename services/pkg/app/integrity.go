@@ -0,0 +1,79 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/integrity"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+const IntegrityWorkerCount = 6
+
+// CheckIntegrity analyzes the activity of each account within 'integrity.Window' of the given time, to detect suspicious accounts (i.e. bots).
+// Returns the verdicts used to down-weight or exclude flagged accounts during aggregation, and a report of the flagged accounts.
+func CheckIntegrity(app App, now time.Time) (*integrity.Verdicts, integrity.Report, error) {
+	chunks, err := app.Storage.ListEventChunks(now.Add(-integrity.Window), now)
+	if err != nil {
+		return nil, integrity.Report{}, util.WrapErr("failed to list event chunks", err)
+	}
+
+	detector := integrity.NewDetector(now)
+	length := len(chunks)
+
+	var wg sync.WaitGroup
+	wg.Add(IntegrityWorkerCount)
+	errs := make(chan error, IntegrityWorkerCount)
+
+	// Divide the work into segments and start workers
+	segmentSize := length / IntegrityWorkerCount
+	for i := 0; i < IntegrityWorkerCount; i++ {
+		start := i * segmentSize
+		end := (i + 1) * segmentSize
+		if i == IntegrityWorkerCount-1 {
+			end = length
+		}
+		go checkIntegrityWorker(app.Storage, chunks[start:end], detector, &wg, errs)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return nil, integrity.Report{}, util.WrapErr("failed to check integrity", err)
+		}
+	}
+
+	verdicts, report := detector.Evaluate(app.AccountAges)
+	slog.Info("checked integrity", "accounts", report.Accounts, "down_weighted", report.DownWeighted, "excluded", report.Excluded, "clusters", len(report.Clusters))
+	return verdicts, report, nil
+}
+
+func checkIntegrityWorker(st Storage, chunks []string, detector *integrity.Detector, wg *sync.WaitGroup, errs chan error) {
+	defer wg.Done()
+
+	for _, chunk := range chunks {
+		partial, err := loadPartial(st, chunk)
+		if err != nil {
+			errs <- util.WrapErr("failed to load partial", err)
+			return
+		}
+		detector.Add(partial)
+	}
+}
+
+// Save the report of flagged accounts. This is not critical to the report, so only log failures.
+func saveIntegrityReport(app App, report integrity.Report) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to marshal integrity report", err).Error())
+		return
+	}
+	err = app.Storage.SaveIntegrityReport(data)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to save integrity report", err).Error())
+	}
+}
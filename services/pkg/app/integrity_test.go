@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/integrity"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// Test that accounts bursting together across chunks are flagged, and the report is saved
+func TestCheckIntegrity(t *testing.T) {
+	app, _ := newTestApp(t)
+	now := time.Now().UTC().Truncate(time.Hour)
+
+	// Six accounts like posts linking to the same three URLs, within the same minute, split across workers
+	for i := 0; i < 3; i++ {
+		ts := now.Add(-time.Duration(i+1) * time.Hour)
+		for worker := 0; worker < 2; worker++ {
+			events := make([]storage.EventRecord, 0)
+			for j := worker * 3; j < worker*3+3; j++ {
+				events = append(events, storage.EventRecord{
+					Type:      2,
+					URL:       fmt.Sprintf("https://spam.com/%d", i),
+					DID:       fmt.Sprintf("did:plc:bot%d", j),
+					Timestamp: ts.Add(time.Duration(j) * time.Second),
+					Post:      fmt.Sprintf("at://did:plc:spammer/app.bsky.feed.post/%d", i),
+					Language:  "en",
+				})
+			}
+			key := storage.ChunkKey{Start: ts, Instance: "intake", Worker: worker}
+			if err := app.Storage.FlushEvents(key, events); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	verdicts, report, err := CheckIntegrity(app, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdicts.Len() != 6 || verdicts.Weight(dedupe.HashDID("did:plc:bot0")) != integrity.DownWeight {
+		t.Errorf("expected all bots to be down-weighted, got %d", verdicts.Len())
+	}
+	if len(report.Clusters) != 1 || report.Clusters[0].URLs[0] != "https://spam.com/0" {
+		t.Errorf("unexpected clusters: %v", report.Clusters)
+	}
+
+	saveIntegrityReport(app, report)
+	reports, _ := filepath.Glob(filepath.Join(app.Config.LocalStorageDir, "integrity", "*", "*", "*", "*.json"))
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	if data, _ := os.ReadFile(reports[0]); len(data) == 0 {
+		t.Error("expected report to be written")
+	}
+}
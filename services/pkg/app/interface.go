@@ -5,6 +5,8 @@ import (
 
 	"github.com/georgemblack/blue-report/pkg/bluesky"
 	"github.com/georgemblack/blue-report/pkg/cache"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/live"
 	"github.com/georgemblack/blue-report/pkg/queue"
 	"github.com/georgemblack/blue-report/pkg/storage"
 )
@@ -16,15 +18,24 @@ type Cache interface {
 	SavePost(hash string, post cache.PostRecord) error
 	ReadPost(hash string) (cache.PostRecord, error)
 	RefreshPost(hash string) error
+	SaveCursor(cursor int64) error
+	ReadCursor() (int64, error)
 	Close()
 }
 
 type Storage interface {
-	PublishLinkSnapshot(snapshot []byte) error
+	PublishLinkSnapshot(kind string, snapshot []byte) error
 	PublishSiteSnapshot(snapshot []byte) error
+	ListSnapshots(kind string, start, end time.Time) ([]storage.ArchivedSnapshot, error)
+	ReadSnapshot(snapshot storage.ArchivedSnapshot) ([]byte, error)
+	SaveIntegrityReport(report []byte) error
+	GetBlockRules() ([]storage.BlockRule, error)
+	SaveBlockRules(rules []storage.BlockRule) error
 	ReadEvents(key string, eventBufferSize int) ([]storage.EventRecord, error)
-	FlushEvents(start time.Time, events []storage.EventRecord) error
+	FlushEvents(key storage.ChunkKey, events []storage.EventRecord) error
 	ListEventChunks(start, end time.Time) ([]string, error)
+	ReadPartial(chunk string) (storage.Partial, error)
+	SavePartial(partial storage.Partial) error
 	SaveThumbnail(id string, url string) (string, error)
 	GetThumbnailURL(id string) (string, error)
 	GetURLMetadata(url string) (storage.URLMetadata, error)
@@ -38,6 +49,21 @@ type Storage interface {
 	CleanFeed() error
 }
 
+type Blocklist interface {
+	Match(url string) (storage.BlockRule, bool)
+	Close()
+}
+
+type Live interface {
+	Record(record storage.EventRecord) error
+	Buckets(bounds links.TimeBounds) ([]live.Bucket, error)
+	Close()
+}
+
+type History interface {
+	PreviousLinkSnapshot(kind string, before time.Time) (links.Snapshot, error)
+}
+
 type Queue interface {
 	Send(message queue.Message) error
 	Receive() ([]queue.Message, error)
@@ -45,4 +71,5 @@ type Queue interface {
 
 type Bluesky interface {
 	GetPost(atURI string) (bluesky.Post, error)
+	GetLabels(atURIs []string) (map[string][]string, error)
 }
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/util"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	CursorSaveInterval  = 10 * time.Second
+	CursorRewind        = 5 * time.Second // When resuming, rewind the cursor slightly to cover events in flight when the connection dropped
+	MinReconnectBackoff = 1 * time.Second
+	MaxReconnectBackoff = 60 * time.Second
+)
+
+// Jetstream manages the connection to the Bluesky Jetstream.
+// If the connection drops, it reconnects with exponential backoff and resumes from the last known cursor (i.e. 'time_us' of the last event received).
+// The cursor is periodically saved to the cache, so that the stream can also be resumed after a restart.
+// If a checkpoint is set, the saved cursor is taken from it instead, so events that have been received (but not flushed) are read again after a restart.
+//
+// Resuming may replay a small number of events. This is safe, as duplicate events are discarded during aggregation.
+type Jetstream struct {
+	endpoint   string
+	cache      Cache
+	cursor     int64
+	checkpoint *Checkpoint
+	savedAt    time.Time
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	maxRetries int
+}
+
+func NewJetstream(endpoint string, ch Cache) Jetstream {
+	return Jetstream{
+		endpoint:   endpoint,
+		cache:      ch,
+		minBackoff: MinReconnectBackoff,
+		maxBackoff: MaxReconnectBackoff,
+		maxRetries: ErrorThreshold,
+	}
+}
+
+// Stream reads events from the Jetstream and sends them to the given channel, until the context is cancelled.
+// An error is returned once connecting to (or reading from) the Jetstream has failed too many consecutive times.
+func (j *Jetstream) Stream(ctx context.Context, stream chan StreamEvent) error {
+	return j.run(ctx, func(_ []byte, event StreamEvent) {
+		// If the workers have exited, the stream is never drained, so stop waiting once the context is cancelled
+		select {
+		case stream <- event:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// Read messages from the Jetstream, reconnecting as needed, and pass each (along with the parsed event) to the handler.
+func (j *Jetstream) run(ctx context.Context, handle func(message []byte, event StreamEvent)) error {
+	cursor, err := j.cache.ReadCursor()
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to read cursor, starting from live stream", err).Error())
+	}
+	j.cursor = cursor
+
+	failures := 0
+	backoff := j.minBackoff
+	for {
+		received, err := j.consume(ctx, handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		j.saveCursor(true)
+		if received > 0 {
+			// The connection was healthy before failing, so reset the failure count and backoff
+			failures = 0
+			backoff = j.minBackoff
+		}
+
+		failures++
+		if failures > j.maxRetries {
+			return util.WrapErr("encountered too many errors reading from jetstream", err)
+		}
+
+		slog.Warn(util.WrapErr("jetstream connection lost", err).Error(), "attempt", failures, "backoff", backoff.String(), "cursor", j.cursor)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, j.maxBackoff)
+	}
+}
+
+// Cursor returns the 'time_us' of the most recent event received from the Jetstream.
+func (j *Jetstream) Cursor() int64 {
+	return j.cursor
+}
+
+// Connect to the Jetstream and read events until the connection fails, or the context is cancelled.
+// Returns the number of events received on this connection, along with the error that ended it.
+func (j *Jetstream) consume(ctx context.Context, handle func(message []byte, event StreamEvent)) (int, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, j.url(), nil)
+	if err != nil {
+		return 0, util.WrapErr("failed to dial jetstream", err)
+	}
+	defer conn.Close()
+
+	// Reads block indefinitely, so close the connection to unblock them once the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	received := 0
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return received, util.WrapErr("failed to read message", err)
+		}
+		received++
+
+		// A malformed message does not mean the connection is broken, so skip it and continue
+		event := StreamEvent{}
+		if err := json.Unmarshal(message, &event); err != nil {
+			slog.Warn(util.WrapErr("failed to read json", err).Error())
+			continue
+		}
+
+		if event.TimeUS > j.cursor {
+			j.cursor = event.TimeUS
+		}
+		j.saveCursor(false)
+
+		handle(message, event)
+	}
+}
+
+// Save the cursor to the cache, at most once per 'CursorSaveInterval' (unless forced).
+func (j *Jetstream) saveCursor(force bool) {
+	cursor := j.cursor
+	if j.checkpoint != nil {
+		cursor = j.checkpoint.Cursor()
+	}
+	if cursor == 0 {
+		return
+	}
+	if !force && time.Since(j.savedAt) < CursorSaveInterval {
+		return
+	}
+
+	err := j.cache.SaveCursor(cursor)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to save cursor", err).Error())
+		return
+	}
+	j.savedAt = time.Now()
+}
+
+// Build the URL used to connect to the Jetstream, including the cursor if one is known.
+func (j *Jetstream) url() string {
+	if j.cursor == 0 {
+		return j.endpoint
+	}
+
+	parsed, err := url.Parse(j.endpoint)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to parse jetstream url", err).Error())
+		return j.endpoint
+	}
+
+	cursor := max(j.cursor-CursorRewind.Microseconds(), 0)
+	query := parsed.Query()
+	query.Set("cursor", strconv.FormatInt(cursor, 10))
+	parsed.RawQuery = query.Encode()
+
+	slog.Info(fmt.Sprintf("resuming jetstream from cursor %d", cursor))
+	return parsed.String()
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/cache"
+	"github.com/gorilla/websocket"
+)
+
+// jetstreamStandIn is a local stand-in for the Jetstream.
+// Each connection is served one batch of messages, after which the connection is dropped without a close frame.
+// Once all batches have been served, new connections are refused.
+// If 'hold' is set, the last connection is held open instead, until the client disconnects.
+type jetstreamStandIn struct {
+	server  *httptest.Server
+	batches [][]string
+	hold    bool
+	cursors []string // Value of the 'cursor' query parameter for each connection
+	lock    sync.Mutex
+}
+
+func newJetstreamStandIn(batches [][]string, hold bool) *jetstreamStandIn {
+	standIn := &jetstreamStandIn{batches: batches, hold: hold}
+	upgrader := websocket.Upgrader{}
+
+	standIn.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		standIn.lock.Lock()
+		index := len(standIn.cursors)
+		standIn.cursors = append(standIn.cursors, r.URL.Query().Get("cursor"))
+		standIn.lock.Unlock()
+
+		if index >= len(standIn.batches) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		for _, message := range standIn.batches[index] {
+			conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+		if standIn.hold && index == len(standIn.batches)-1 {
+			conn.ReadMessage() // Blocks until the client disconnects
+		}
+		conn.NetConn().Close()
+	}))
+
+	return standIn
+}
+
+func (s *jetstreamStandIn) url() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/subscribe?wantedCollections=app.bsky.feed.post"
+}
+
+func testJetstreamMessage(timeUS int64) string {
+	data, _ := json.Marshal(StreamEvent{DID: "did:plc:test", TimeUS: timeUS, Kind: "commit"})
+	return string(data)
+}
+
+func testJetstream(endpoint string, ch Cache) Jetstream {
+	jetstream := NewJetstream(endpoint, ch)
+	jetstream.minBackoff = time.Millisecond
+	jetstream.maxBackoff = 5 * time.Millisecond
+	jetstream.maxRetries = 2
+	return jetstream
+}
+
+// Test that dropped connections are re-established, resuming from the cursor of the last event received.
+func TestJetstreamReconnectsWithCursor(t *testing.T) {
+	standIn := newJetstreamStandIn([][]string{
+		{testJetstreamMessage(1736019684000001), "not json", testJetstreamMessage(1736019684000002)},
+		{testJetstreamMessage(1736019684000003)},
+	}, false)
+	defer standIn.server.Close()
+
+	ch := cache.NewMemory()
+	stream := make(chan StreamEvent, 10)
+	jetstream := testJetstream(standIn.url(), ch)
+	err := jetstream.Stream(context.Background(), stream)
+	if err == nil {
+		t.Fatal("expected error once the stand-in refuses connections")
+	}
+	close(stream)
+
+	received := make([]int64, 0)
+	for event := range stream {
+		received = append(received, event.TimeUS)
+	}
+	if len(received) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(received))
+	}
+	if received[2] != 1736019684000003 {
+		t.Errorf("unexpected last event: %d", received[2])
+	}
+
+	// First connection has no cursor, the second resumes from the last event (minus the rewind)
+	if standIn.cursors[0] != "" {
+		t.Errorf("unexpected cursor on first connection: %s", standIn.cursors[0])
+	}
+	expected := strconv.FormatInt(1736019684000002-CursorRewind.Microseconds(), 10)
+	if standIn.cursors[1] != expected {
+		t.Errorf("expected cursor %s, got %s", expected, standIn.cursors[1])
+	}
+	if saved, _ := ch.ReadCursor(); saved != 1736019684000003 {
+		t.Errorf("unexpected saved cursor: %d", saved)
+	}
+}
+
+// Test that a cursor saved by a previous run is used on the first connection.
+func TestJetstreamResumesFromSavedCursor(t *testing.T) {
+	standIn := newJetstreamStandIn([][]string{
+		{testJetstreamMessage(1736019684902212)},
+	}, false)
+	defer standIn.server.Close()
+
+	ch := cache.NewMemory()
+	ch.SaveCursor(1736019684000000)
+
+	stream := make(chan StreamEvent, 10)
+	jetstream := testJetstream(standIn.url(), ch)
+	_ = jetstream.Stream(context.Background(), stream)
+
+	expected := strconv.FormatInt(1736019684000000-CursorRewind.Microseconds(), 10)
+	if standIn.cursors[0] != expected {
+		t.Errorf("expected cursor %s, got %s", expected, standIn.cursors[0])
+	}
+	if jetstream.Cursor() != 1736019684902212 {
+		t.Errorf("unexpected cursor: %d", jetstream.Cursor())
+	}
+}
+
+// Test that cancelling the context closes an open connection and stops the stream without an error.
+func TestJetstreamStopsOnCancel(t *testing.T) {
+	standIn := newJetstreamStandIn([][]string{
+		{testJetstreamMessage(1736019684000001)},
+	}, true)
+	defer standIn.server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := make(chan StreamEvent, 10)
+	result := make(chan error)
+	jetstream := testJetstream(standIn.url(), cache.NewMemory())
+	go func() {
+		result <- jetstream.Stream(ctx, stream)
+	}()
+
+	// Wait for the first event, then cancel while the connection is still open
+	<-stream
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop after context was cancelled")
+	}
+}
+
+// Test that cancelling the context stops the stream, even if no workers are draining it.
+func TestJetstreamStopsWhenStreamIsFull(t *testing.T) {
+	standIn := newJetstreamStandIn([][]string{
+		{testJetstreamMessage(1736019684000001), testJetstreamMessage(1736019684000002)},
+	}, true)
+	defer standIn.server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := make(chan StreamEvent, 1)
+	result := make(chan error)
+	jetstream := testJetstream(standIn.url(), cache.NewMemory())
+	go func() {
+		result <- jetstream.Stream(ctx, stream)
+	}()
+
+	// Wait until the stream is full, and give the second event time to block
+	for len(stream) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop after context was cancelled")
+	}
+}
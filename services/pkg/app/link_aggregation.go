@@ -2,55 +2,127 @@ package app
 
 import (
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/georgemblack/blue-report/pkg/classify"
+	"github.com/georgemblack/blue-report/pkg/integrity"
+	"github.com/georgemblack/blue-report/pkg/labels"
 	"github.com/georgemblack/blue-report/pkg/links"
-	"github.com/georgemblack/blue-report/pkg/urltools"
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
 const (
 	ListSize                   = 10
 	LinkAggregationWorkerCount = 6
+	LabelQueryBatchSize        = 50 // Number of posts in each query to the labeler, leaving room in each response for posts with several labels
+	MaxLabelRounds             = 5  // Maximum number of times candidate links are re-checked after labelled posts are excluded
 )
 
-// AggregateLinks fetches all events from storage, aggregates trending URLs, and generates a snapshot.
+// AggregateLinks fetches all events from storage, aggregates trending URLs, and generates a snapshot for each configured language.
+// Snapshots are in the same order as the configured languages, so the default report is first.
 // Metadata for each URL is hydrated from the cache, and thumbnails for each URL are stored in S3.
-func AggregateLinks() (links.Snapshot, error) {
+func AggregateLinks() ([]links.Snapshot, error) {
 	slog.Info("starting snapshot generation")
 	jobStart := time.Now()
 
 	app, err := NewApp()
 	if err != nil {
-		return links.Snapshot{}, util.WrapErr("failed to create app", err)
+		return nil, util.WrapErr("failed to create app", err)
 	}
 	defer app.Close()
 
-	// Create the time boundaries for the 'previous day' and 'previous week' reports.
-	now := time.Now().UTC()
-	bounds := links.TimeBounds{
-		HourStart: now.Add(-1 * time.Hour),
-		DayStart:  now.Add(-24 * time.Hour),
-		WeekStart: now.Add(-24 * 7 * time.Hour),
+	app.Blocklist, err = newBlocklist(app.Storage)
+	if err != nil {
+		return nil, err
+	}
+	app.Classifier, err = classify.New(app.Config)
+	if err != nil {
+		return nil, util.WrapErr("failed to create classifier", err)
 	}
 
-	// Create the aggregation.
+	// Create the time boundaries for each configured window (i.e. the past hour, day, and week)
+	windows, err := links.ParseWindows(app.Config.LinkWindows)
+	if err != nil {
+		return nil, util.WrapErr("failed to parse link windows", err)
+	}
+	now := time.Now().UTC()
+	bounds := links.NewTimeBounds(now, windows)
+
+	// Create the aggregation, using the configured scoring model.
 	// This will be used to generate all the data required to render the report.
-	aggregation := links.NewAggregation(bounds)
+	scorer, err := links.NewScorer(app.Config.ScoringModel, app.Config.ScoringParams)
+	if err != nil {
+		return nil, util.WrapErr("failed to create scorer", err)
+	}
+	chunks, err := app.Storage.ListEventChunks(bounds.Earliest(), now)
+	if err != nil {
+		return nil, util.WrapErr("failed to list event chunks", err)
+	}
+	languages := app.Config.ReportLanguages()
+	slog.Info("ranking links", "model", app.Config.ScoringModel, "dedupe", app.Config.DedupeMode, "languages", languages)
 
 	// Fetch all known translations (i.e. URL redirects).
 	// Apply them as we process events.
 	translations, err := app.Storage.GetURLTranslations()
 	if err != nil {
-		return links.Snapshot{}, util.WrapErr("failed to get url translations", err)
+		return nil, util.WrapErr("failed to get url translations", err)
 	}
 	slog.Info("loaded url translations", "count", len(translations))
 
-	chunks, err := app.Storage.ListEventChunks(bounds.WeekStart, now)
+	// Detect suspicious accounts, so their interactions can be down-weighted or excluded
+	var verdicts *integrity.Verdicts
+	if app.Config.IntegrityChecks {
+		var report integrity.Report
+		verdicts, report, err = CheckIntegrity(app, now)
+		if err != nil {
+			return nil, util.WrapErr("failed to check integrity", err)
+		}
+		saveIntegrityReport(app, report)
+	}
+
+	aggregations, err := aggregateLinks(app, chunks, bounds, scorer, translations, verdicts)
 	if err != nil {
-		return links.Snapshot{}, util.WrapErr("failed to list event chunks", err)
+		return nil, err
 	}
+
+	// Labels applied by the labeler are not known at intake, so only self-labels have been excluded so far
+	if len(app.Config.LabelPolicy) > 0 {
+		excludeLabelledPosts(app, aggregations)
+	}
+
+	snapshots := make([]links.Snapshot, 0, len(languages))
+	for _, language := range languages {
+		aggregation := aggregations[language]
+		dedupeStats := aggregation.Deduplication()
+		slog.Info("processed events", "language", language, "count", aggregation.Total(), "skipped", aggregation.Skipped(), "estimated_false_positives", dedupeStats.EstimatedFalsePositives)
+
+		snapshot, err := newLinkSnapshot(app, aggregation, language)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	jobDuration := time.Since(jobStart)
+	slog.Info("aggregation complete", "seconds", jobDuration.Seconds())
+	return snapshots, nil
+}
+
+// Aggregate the links in each chunk, with a separate aggregation for each configured language.
+func aggregateLinks(app App, chunks []string, bounds links.TimeBounds, scorer links.Scorer, translations map[string]string, verdicts *integrity.Verdicts) (map[string]*links.Aggregation, error) {
+	// Each language is aggregated separately, as a link's rank in one language should not depend on interactions in another
+	aggregations := make(map[string]*links.Aggregation)
+	for _, language := range app.Config.ReportLanguages() {
+		fingerprints, err := newFilter(app.Config, len(chunks))
+		if err != nil {
+			return nil, util.WrapErr("failed to create dedupe filter", err)
+		}
+		aggregation := links.NewAggregation(bounds, scorer, fingerprints)
+		aggregations[language] = &aggregation
+	}
+
 	length := len(chunks)
 
 	// Start worker threads to divide the work.
@@ -67,7 +139,7 @@ func AggregateLinks() (links.Snapshot, error) {
 		if i == LinkAggregationWorkerCount-1 {
 			end = length
 		}
-		go aggregateLinksWorker(i, app.Storage, chunks[start:end], &aggregation, translations, &wg, errs)
+		go aggregateLinksWorker(i, app.Storage, chunks[start:end], aggregations, translations, verdicts, app.Config.LabelPolicy, &wg, errs)
 	}
 
 	wg.Wait()
@@ -76,85 +148,166 @@ func AggregateLinks() (links.Snapshot, error) {
 	// Check for any errors
 	for err := range errs {
 		if err != nil {
-			return links.Snapshot{}, util.WrapErr("failed to aggregate sites", err)
+			return nil, util.WrapErr("failed to aggregate sites", err)
 		}
 	}
 
-	slog.Info("processed events", "count", aggregation.Total(), "skipped", aggregation.Skipped())
+	return aggregations, nil
+}
 
-	// Sort links based on score
-	topHour := aggregation.TopHourLinks(ListSize)
-	topDay := aggregation.TopDayLinks(ListSize)
-	topWeek := aggregation.TopWeekLinks(ListSize)
+// Query the labeler for the top posts of each window's candidate links, and remove interactions with posts excluded by the policy.
+// Removing posts changes the candidates (and their top posts), so this is repeated until every candidate's top posts have been checked.
+func excludeLabelledPosts(app App, aggregations map[string]*links.Aggregation) {
+	checked := make(map[string]bool)
+	for round := 1; round <= MaxLabelRounds; round++ {
+		excluded, queried := labelledPosts(app, aggregations, checked)
+		if len(excluded) == 0 {
+			return
+		}
 
-	// Format the data into a snapshot
-	snapshot := links.NewSnapshot()
+		removed := 0
+		for _, aggregation := range aggregations {
+			removed += aggregation.Exclude(excluded)
+		}
+		slog.Info("excluded labelled posts", "round", round, "queried", queried, "posts", len(excluded), "interactions", removed)
+	}
+	slog.Warn("candidate links changed after the last round of label queries", "rounds", MaxLabelRounds)
+}
 
-	hour := make([]links.Link, 0, len(topHour))
-	for _, url := range topHour {
-		hour = append(hour, links.Link{
-			URL: url,
-		})
+// Query the labeler for the top posts of each window's candidate links that have not yet been checked, returning the posts whose labels are excluded by the policy.
+// Also returns the number of posts queried. Queried posts are added to 'checked'. If the labeler is unavailable, no posts are excluded.
+func labelledPosts(app App, aggregations map[string]*links.Aggregation, checked map[string]bool) (map[string]bool, int) {
+	uris := make([]string, 0)
+	for _, aggregation := range aggregations {
+		for _, window := range aggregation.Bounds().Windows {
+			for _, url := range aggregation.TopLinks(window.Name, CategoryCandidates) {
+				item := aggregation.Get(url)
+				for _, post := range item.TopPosts() {
+					if !checked[post] {
+						checked[post] = true
+						uris = append(uris, post)
+					}
+				}
+			}
+		}
 	}
-	day := make([]links.Link, 0, len(topDay))
-	for _, url := range topDay {
-		day = append(day, links.Link{
-			URL: url,
-		})
+
+	excluded := make(map[string]bool)
+	for batch := range slices.Chunk(uris, LabelQueryBatchSize) {
+		applied, err := app.Bluesky.GetLabels(batch)
+		if err != nil {
+			slog.Warn(util.WrapErr("failed to get labels", err).Error())
+			continue
+		}
+		for uri, values := range applied {
+			if !app.Config.LabelPolicy.Counted(values) {
+				excluded[uri] = true
+			}
+		}
 	}
-	week := make([]links.Link, 0, len(topWeek))
-	for _, url := range topWeek {
-		week = append(week, links.Link{
-			URL: url,
-		})
+	return excluded, len(uris)
+}
+
+// Format an aggregation into a snapshot, with the top links in each window hydrated with metadata.
+func newLinkSnapshot(app App, aggregation *links.Aggregation, language string) (links.Snapshot, error) {
+	// Sort links based on score, and format the data into a snapshot
+	snapshot := links.NewSnapshot()
+	snapshot.Language = language
+	snapshot.Scoring = aggregation.Scoring()
+	snapshot.Deduplication = aggregation.Deduplication()
+	snapshot.TopLinks = make(links.TopLinks)
+	snapshot.Diversity = links.DiversityStats{MaxPerHost: app.Config.MaxLinksPerHost, Demoted: make(map[string]int), CategoryDemoted: make(map[string]map[string]int)}
+	snapshot.Removed = make([]links.RemovedLink, 0)
+	titles := make(map[string]string)
+	candidates := make(map[string][]string)
+	for _, window := range aggregation.Bounds().Windows {
+		// Remove links excluded by the blocklist, recording those that would have been listed
+		top, removed := moderateLinks(app.Blocklist, window.Name, aggregation.TopLinks(window.Name, CategoryCandidates))
+		snapshot.Removed = append(snapshot.Removed, removed...)
+		candidates[window.Name] = top
+
+		// Links covering the same story are grouped, so each story is only listed once
+		list, demoted := clusterLinks(app, aggregation, top[:min(len(top), ClusterCandidates)], titles, ListSize)
+		snapshot.Windows = append(snapshot.Windows, window.Name)
+		snapshot.TopLinks[window.Name] = list
+		snapshot.Diversity.Demoted[window.Name] = demoted
 	}
 
-	snapshot.TopHour = hour
-	snapshot.TopDay = day
-	snapshot.TopWeek = week
+	// Build the top links in each category, if links are being classified.
+	// Titles read while clustering are reused, and any missing titles are fetched, so links are classified by title.
+	var categories *categorizer
+	if app.Classifier != nil {
+		categories = newCategorizer(app)
+		snapshot.TopCategories, snapshot.Diversity.CategoryDemoted = categoryLinks(app, categories, aggregation.Bounds().Windows, candidates, titles)
+	}
 
 	// Hydrate the snapshot with metadata from storage, as well as the cache
-	snapshot, err = hydrateLinks(app, &aggregation, snapshot)
+	snapshot, err := hydrateLinks(app, aggregation, categories, snapshot)
 	if err != nil {
 		return links.Snapshot{}, util.WrapErr("failed to hydrate links", err)
 	}
 
-	jobDuration := time.Since(jobStart)
-	slog.Info("aggregation complete", "seconds", jobDuration.Seconds())
+	// Compare each list to the previous snapshot, to track how links have moved.
+	// This is not critical to the report, so continue without it on failure.
+	if app.History != nil {
+		previous, err := app.History.PreviousLinkSnapshot(LinkSnapshotKind(app.Config, language), time.Now().UTC())
+		if err != nil {
+			slog.Warn(util.WrapErr("failed to load previous snapshot", err).Error())
+		} else {
+			snapshot.TrackMovement(previous)
+		}
+	}
+
+	// Populate the hour, day, and week lists from their matching windows, if configured
+	snapshot.TopHour = windowLinks(snapshot, aggregation.Bounds(), time.Hour)
+	snapshot.TopDay = windowLinks(snapshot, aggregation.Bounds(), 24*time.Hour)
+	snapshot.TopWeek = windowLinks(snapshot, aggregation.Bounds(), 7*24*time.Hour)
+
 	return snapshot, nil
 }
 
-func aggregateLinksWorker(id int, st Storage, chunks []string, agg *links.Aggregation, trans map[string]string, wg *sync.WaitGroup, errs chan error) {
+func windowLinks(snapshot links.Snapshot, bounds links.TimeBounds, duration time.Duration) []links.Link {
+	window, ok := bounds.Find(duration)
+	if !ok {
+		return []links.Link{}
+	}
+	return snapshot.TopLinks[window.Name]
+}
+
+func aggregateLinksWorker(id int, st Storage, chunks []string, aggs map[string]*links.Aggregation, trans map[string]string, verdicts *integrity.Verdicts, policy labels.Policy, wg *sync.WaitGroup, errs chan error) {
 	defer wg.Done()
 
 	for _, chunk := range chunks {
 		slog.Debug("processing chunk", "worker", id, "chunk", chunk)
 
-		records, err := st.ReadEvents(chunk, EventBufferSize)
+		// Each chunk is only reduced once, after which its partial aggregate is reused by later runs
+		partial, err := loadPartial(st, chunk)
 		if err != nil {
-			errs <- util.WrapErr("failed to read events", err)
+			errs <- util.WrapErr("failed to load partial", err)
 			return
 		}
 
-		for _, record := range records {
-			// URLs stored in events should already be filtered and normalized.
-			// However, as rules change, past events may need to be re-processed.
-			// This ensures the most up-to-date rules are applied.
-			if urltools.Ignore(record.URL) {
+		for _, link := range partial.Links {
+			// Skip languages that are not configured
+			agg, ok := aggs[link.Language]
+			if !ok {
 				continue
 			}
-			cleanedURL := urltools.Clean(record.URL)
 
 			// Determine if there is a known translation (i.e. redirect) for this URL.
 			// If so, use the translated URL instead.
-			if translated, ok := trans[cleanedURL]; ok {
-				cleanedURL = translated
+			url := link.URL
+			if translated, ok := trans[url]; ok {
+				url = translated
 			}
 
-			// Count the event. This is thread safe.
-			agg.CountEvent(record.Type, cleanedURL, record.Post, record.DID, record.Timestamp)
+			// Count each interaction, skipping those from flagged accounts, and those with labels excluded by the policy. This is thread safe.
+			for i := 0; i < link.Len(); i++ {
+				if !verdicts.Counted(link.Users[i], url) || !policy.Counted(link.Labels[uint32(i)]) {
+					continue
+				}
+				agg.CountInteraction(int(link.Types[i]), url, link.Posts[link.PostRefs[i]], link.Users[i], time.Unix(link.Times[i], 0).UTC())
+			}
 		}
-
-		records = nil // Help the garbage collector
 	}
 }
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/labels"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// Test that posts labelled by the labeler are found among the top posts of candidate links, and their interactions are removed
+func TestAggregateLinksLabelled(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.Config.DedupeMode = dedupe.ExactMode
+	app.Config.LabelPolicy, _ = labels.ParsePolicy(labels.DefaultPolicy)
+	app.Bluesky = fakeBluesky{applied: map[string][]string{"at://did:plc:user3/app.bsky.feed.post/3": {"porn"}}}
+
+	events := partialTestEvents()
+	for i := range events {
+		events[i].Language = "en"
+	}
+	key := storage.ChunkKey{Start: events[0].Timestamp, Instance: "intake", Worker: 1}
+	if err := app.Storage.FlushEvents(key, events); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := app.Storage.ListEventChunks(time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := links.NewTimeBounds(events[0].Timestamp.Add(time.Hour), []links.Window{{Name: "24h", Duration: 24 * time.Hour}})
+
+	aggregations, err := aggregateLinks(app, chunks, bounds, links.DefaultLinearScorer(), map[string]string{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := aggregations["en"].Get("https://www.example.com/other")
+	if other.Total().Posts != 1 {
+		t.Fatal("expected labelled post to be counted before querying the labeler")
+	}
+
+	excludeLabelledPosts(app, aggregations)
+	other = aggregations["en"].Get("https://www.example.com/other")
+	if other.Total() != (links.Counts{}) {
+		t.Errorf("expected labelled post to be excluded, got %v", other.Total())
+	}
+	article := aggregations["en"].Get("https://www.example.com/article")
+	if article.Total().Posts != 1 || article.Total().Likes != 1 {
+		t.Errorf("unexpected counts for unlabelled link: %v", article.Total())
+	}
+
+	// Posts already checked are not queried again
+	checked := map[string]bool{"at://did:plc:user3/app.bsky.feed.post/3": true}
+	if excluded, _ := labelledPosts(app, aggregations, checked); len(excluded) != 0 {
+		t.Errorf("unexpected excluded posts: %v", excluded)
+	}
+}
@@ -3,43 +3,60 @@ package app
 import (
 	"errors"
 	"log/slog"
+	"maps"
+	"slices"
 
 	"github.com/aws/aws-sdk-go-v2/service/sso/types"
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/georgemblack/blue-report/pkg/labels"
 	"github.com/georgemblack/blue-report/pkg/links"
 	"github.com/georgemblack/blue-report/pkg/storage"
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
-func hydrateLinks(app App, agg *links.Aggregation, snapshot links.Snapshot) (links.Snapshot, error) {
-	for i := range snapshot.TopHour {
-		link, err := hydrateLink(app, agg, i, snapshot.TopHour[i])
-		if err != nil {
-			return links.Snapshot{}, util.WrapErr("failed to hydrate link", err)
-		}
-		snapshot.TopHour[i] = link
-	}
+// Hydrate the top links in each window, as well as the top links in each category.
+// Categories are only assigned if a categorizer is given.
+func hydrateLinks(app App, agg *links.Aggregation, categories *categorizer, snapshot links.Snapshot) (links.Snapshot, error) {
+	// The same link often appears in several windows (and categories), so only hydrate it once
+	hydrated := make(map[string]links.Link)
 
-	for i := range snapshot.TopDay {
-		link, err := hydrateLink(app, agg, i, snapshot.TopDay[i])
-		if err != nil {
-			return links.Snapshot{}, util.WrapErr("failed to hydrate link", err)
+	lists := make([][]links.Link, 0)
+	for _, window := range snapshot.Windows {
+		lists = append(lists, snapshot.TopLinks[window])
+	}
+	for _, category := range slices.Sorted(maps.Keys(snapshot.TopCategories)) {
+		for _, window := range snapshot.Windows {
+			lists = append(lists, snapshot.TopCategories[category][window])
 		}
-		snapshot.TopDay[i] = link
 	}
 
-	for i := range snapshot.TopWeek {
-		link, err := hydrateLink(app, agg, i, snapshot.TopWeek[i])
-		if err != nil {
-			return links.Snapshot{}, util.WrapErr("failed to hydrate link", err)
+	for _, list := range lists {
+		for i := range list {
+			// Stories are grouped separately in each window, so keep the coverage from the list
+			coverage := list[i].AlsoCoveredBy
+			if coverage == nil {
+				coverage = []links.Coverage{}
+			}
+
+			link, ok := hydrated[list[i].URL]
+			if !ok {
+				var err error
+				link, err = hydrateLink(app, agg, snapshot.Language, categories, list[i])
+				if err != nil {
+					return links.Snapshot{}, util.WrapErr("failed to hydrate link", err)
+				}
+				hydrated[link.URL] = link
+			}
+			link.Rank = i + 1
+			link.AlsoCoveredBy = coverage
+			list[i] = link
 		}
-		snapshot.TopWeek[i] = link
 	}
 
 	return snapshot, nil
 }
 
-func hydrateLink(app App, agg *links.Aggregation, index int, link links.Link) (links.Link, error) {
+func hydrateLink(app App, agg *links.Aggregation, language string, categories *categorizer, link links.Link) (links.Link, error) {
 	hashedURL := util.Hash(link.URL)
 	stats := agg.Get(link.URL)
 
@@ -72,48 +89,100 @@ func hydrateLink(app App, agg *links.Aggregation, index int, link links.Link) (l
 		}
 	}
 
+	if categories != nil {
+		link.Category = categories.category(link.URL, link.Title)
+	}
 	if link.Title == "" {
 		link.Title = "(No Title)"
 	}
-	link.Rank = index + 1
-	link.PostCount = stats.WeekCount.Posts
-	link.RepostCount = stats.WeekCount.Reposts
-	link.LikeCount = stats.WeekCount.Likes
-	link.RecommendedPosts = recommendedPosts(app.Bluesky, stats.TopPosts())
+	link.PostCount = stats.Total().Posts
+	link.RepostCount = stats.Total().Reposts
+	link.LikeCount = stats.Total().Likes
+	link.RecommendedPosts = recommendedPosts(app.Bluesky, app.Config.LabelPolicy, stats.TopPosts(), language)
+	link.Trend = stats.Trend(agg.Bounds())
 
 	slog.Debug("hydrated", "record", link)
 	return link, nil
 }
 
+// Resolve a link's title from URL metadata, fetching (and saving) it if the link has never been hydrated.
+// Titles are cached in 'titles' for the duration of a run. If no title is found, an empty string is returned.
+func resolveTitle(app App, titles map[string]string, url string) string {
+	if title := titles[url]; title != "" {
+		return title
+	}
+
+	title := getTitle(app.Storage, url)
+	if title == "" {
+		metadata := GetCardMetadata(app.Config, url)
+		if metadata.Title != "" {
+			title = formatTitle(metadata.Title)
+			updateTitle(app.Storage, url, title)
+		}
+	}
+	titles[url] = title
+	return title
+}
+
 func getTitle(stg Storage, url string) string {
+	return getURLMetadata(stg, url).Title
+}
+
+func getURLMetadata(stg Storage, url string) storage.URLMetadata {
 	metadata, err := stg.GetURLMetadata(url)
 	if err != nil {
 		var notFoundEx *types.ResourceNotFoundException
 		if errors.As(err, &notFoundEx) {
-			return ""
+			return storage.URLMetadata{}
 		} else {
 			slog.Warn(util.WrapErr("failed to get url metadata", err).Error(), "url", url)
 		}
 	}
 
-	return metadata.Title
+	return metadata
 }
 
 func updateTitle(stg Storage, url string, title string) {
-	err := stg.SaveURLMetadata(storage.URLMetadata{
-		URL:   url,
-		Title: title,
+	updateURLMetadata(stg, url, func(metadata *storage.URLMetadata) {
+		metadata.Title = title
+	})
+}
+
+func updateCategory(stg Storage, url string, category string) {
+	updateURLMetadata(stg, url, func(metadata *storage.URLMetadata) {
+		metadata.Category = category
 	})
+}
+
+// Titles and categories are saved separately, so read the existing metadata to avoid overwriting either.
+func updateURLMetadata(stg Storage, url string, update func(metadata *storage.URLMetadata)) {
+	metadata := getURLMetadata(stg, url)
+	metadata.URL = url
+	update(&metadata)
+
+	err := stg.SaveURLMetadata(metadata)
 	if err != nil {
 		slog.Warn(util.WrapErr("failed to save url metadata", err).Error(), "url", url)
 	}
 }
 
-// Given the AT URIs of the top posts referencing a URL, return a list of recommended posts to display to the user.
-func recommendedPosts(bs Bluesky, uris []string) []links.Post {
+// Given the AT URIs of the top posts referencing a URL, return a list of recommended posts (in the report's language) to display to the user.
+// Posts with labels in the policy are never recommended.
+func recommendedPosts(bs Bluesky, policy labels.Policy, uris []string, language string) []links.Post {
 	posts := make([]links.Post, 0)
 	authors := mapset.NewSet[string]() // Track authors that already have a reocommended post
 
+	// Labels applied by the labeler are fetched up front, in a single query.
+	// If the labeler is unavailable, only the labels returned with each post are checked.
+	var applied map[string][]string
+	if len(policy) > 0 {
+		var err error
+		applied, err = bs.GetLabels(uris)
+		if err != nil {
+			slog.Warn(util.WrapErr("failed to get labels", err).Error())
+		}
+	}
+
 	// For each AT URI, fetch the post from the Bluesky API.
 	// If the post has enough text/commentary, add it to the list of recommended posts.
 	for _, uri := range uris {
@@ -130,11 +199,13 @@ func recommendedPosts(bs Bluesky, uris []string) []links.Post {
 
 		// In order for the post to be recommended:
 		//   - It must not be empty (after formatted & removing URLs, etc)
-		//   - Post must be in English (until there's multi language/region support)
+		//   - Post must be in the report's language
 		//   - Post must have at >=50 likes (to avoid junk)
 		//	 - Post cannot be from an author who already has a recommended post for this link
+		//   - Post cannot have a label in the policy
 		formatted := formatPost(postData.Record.Text)
-		if formatted != "" && postData.IsEnglish() && postData.LikeCount > 50 && !authors.Contains(postData.Author.Handle) {
+		postLabels := labels.Merge(postData.LabelValues(), applied[uri])
+		if formatted != "" && postData.HasLanguage(language) && postData.LikeCount > 50 && !authors.Contains(postData.Author.Handle) && policy.Recommended(postLabels) {
 			posts = append(posts, links.Post{
 				Rank:     len(posts) + 1,
 				AtURI:    uri,
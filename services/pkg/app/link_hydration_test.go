@@ -0,0 +1,60 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/bluesky"
+	"github.com/georgemblack/blue-report/pkg/labels"
+)
+
+// fakeBluesky returns posts and labeler labels from memory
+type fakeBluesky struct {
+	posts   map[string]bluesky.Post
+	applied map[string][]string
+}
+
+func (f fakeBluesky) GetPost(atURI string) (bluesky.Post, error) {
+	post, ok := f.posts[atURI]
+	if !ok {
+		return bluesky.Post{}, fmt.Errorf("post not found")
+	}
+	return post, nil
+}
+
+func (f fakeBluesky) GetLabels(atURIs []string) (map[string][]string, error) {
+	applied := make(map[string][]string)
+	for _, uri := range atURIs {
+		if values, ok := f.applied[uri]; ok {
+			applied[uri] = values
+		}
+	}
+	return applied, nil
+}
+
+// Test that posts labelled by their author, or by the labeler, are not recommended
+func TestRecommendedPostsLabels(t *testing.T) {
+	bs := fakeBluesky{posts: make(map[string]bluesky.Post), applied: map[string][]string{"at://c": {"spam"}}}
+	for i, uri := range []string{"at://a", "at://b", "at://c", "at://d"} {
+		bs.posts[uri] = bluesky.Post{
+			URI:       uri,
+			Author:    bluesky.Author{Handle: fmt.Sprintf("user%d.bsky.social", i)},
+			Record:    bluesky.Record{Languages: []string{"en"}, Text: "Some commentary on this link"},
+			LikeCount: 100,
+		}
+	}
+	post := bs.posts["at://a"]
+	post.Labels = []bluesky.Label{{URI: "at://a", Val: "!warn"}}
+	bs.posts["at://a"] = post
+
+	policy, _ := labels.ParsePolicy(labels.DefaultPolicy)
+	posts := recommendedPosts(bs, policy, []string{"at://a", "at://b", "at://c", "at://d"}, "en")
+	if len(posts) != 2 || posts[0].AtURI != "at://b" || posts[1].AtURI != "at://d" {
+		t.Errorf("unexpected recommended posts: %v", posts)
+	}
+
+	// Without a policy, labels are ignored
+	if posts := recommendedPosts(bs, nil, []string{"at://a", "at://b", "at://c", "at://d"}, "en"); len(posts) != 3 {
+		t.Errorf("expected 3 recommended posts, got %d", len(posts))
+	}
+}
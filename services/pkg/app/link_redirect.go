@@ -12,7 +12,7 @@ import (
 )
 
 // ResolveLinkRedirects pulls URLs from an SQS queue that need to be normalized.
-// URLs are normalized by checking for redirects. Translation rules are written to storage.
+// URLs are normalized by checking for redirects, and the canonical URL declared by the destination page. Translation rules are written to storage.
 func ResolveLinkRedirects() error {
 	slog.Info("starting link redirect")
 
@@ -48,6 +48,7 @@ func ResolveLinkRedirects() error {
 
 func resolveLink(app App, msg queue.Message, wg *sync.WaitGroup) {
 	redirect := ""
+	translation := storage.URLTranslation{Source: msg.URL}
 	defer wg.Done()
 
 	// If the URL is an Apple News URL, HTTP redirects are not used.
@@ -69,8 +70,23 @@ func resolveLink(app App, msg queue.Message, wg *sync.WaitGroup) {
 			}
 		}
 	} else {
-		// Check for normal redirects
-		redirect = urltools.FindRedirect(msg.URL)
+		// Check for normal redirects. The chain is saved with the translation, so merged URLs can be audited.
+		chain := urltools.NewResolver(app.Config.RedirectMaxHops, app.Config.RedirectTimeout).Resolve(msg.URL)
+		slog.Debug("resolved redirect chain", "url", msg.URL, "outcome", chain.Outcome, "hops", len(chain.Hops))
+		redirect = chain.Redirected()
+		translation.Outcome = chain.Outcome
+		translation.Chain = redirectHops(chain.Hops)
+
+		// Check the destination page for a canonical URL. This catches variants that are served without a redirect (i.e. AMP pages, or tracking paths).
+		// Only chains that ended at a page are checked, as any other page (i.e. a honeypot, or a block page) can't be trusted.
+		// Chains that are too deep are also checked, as their destination hasn't been requested. Redirects aren't followed, so a destination that redirects again has no canonical URL.
+		if chain.Outcome == urltools.ResolvedOutcome || chain.Outcome == urltools.TooDeepOutcome {
+			if canonical := urltools.FindCanonical(chain.Destination); canonical != "" {
+				slog.Info("found canonical url", "original", msg.URL, "page", chain.Destination, "canonical", canonical)
+				redirect = canonical
+				translation.Canonical = canonical
+			}
+		}
 	}
 
 	if redirect == "" {
@@ -80,14 +96,30 @@ func resolveLink(app App, msg queue.Message, wg *sync.WaitGroup) {
 
 	// Clean the redirect URL (i.e. junk like query params may have been added)
 	cleaned := urltools.Clean(redirect)
+	if cleaned == msg.URL {
+		slog.Debug("url is already canonical", "url", msg.URL)
+		return
+	}
 
 	// Write the translation to storage
-	slog.Info("saving translated url", "url", cleaned)
-	err := app.Storage.SaveURLTranslation(storage.URLTranslation{
-		Source:      msg.URL,
-		Destination: cleaned,
-	})
+	slog.Info("saving translated url", "url", cleaned, "outcome", translation.Outcome)
+	translation.Destination = cleaned
+	err := app.Storage.SaveURLTranslation(translation)
 	if err != nil {
 		slog.Error("failed to save url translation", "url", msg.URL, "error", err)
 	}
 }
+
+func redirectHops(hops []urltools.Hop) []storage.RedirectHop {
+	result := make([]storage.RedirectHop, 0, len(hops))
+	for _, hop := range hops {
+		result = append(result, storage.RedirectHop{
+			URL:        hop.URL,
+			Status:     hop.Status,
+			Location:   hop.Location,
+			DurationMS: hop.Duration.Milliseconds(),
+			Error:      hop.Error,
+		})
+	}
+	return result
+}
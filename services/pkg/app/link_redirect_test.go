@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/queue"
+)
+
+// Test the canonical URL declared by a page is saved as a translation, including pages reached by a redirect.
+// Pages that are already canonical, or weren't resolved (i.e. a block page), are not saved.
+func TestResolveLinkCanonical(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/short" {
+			w.Header().Set("Location", "/amp/article")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="/article"></head><body></body></html>`)
+	}))
+	defer ms.Close()
+
+	app, _ := newTestApp(t)
+	for _, url := range []string{ms.URL + "/amp/article", ms.URL + "/short", ms.URL + "/article", ms.URL + "/forbidden"} {
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		resolveLink(app, queue.Message{URL: url}, &wg)
+		wg.Wait()
+	}
+
+	translations, err := app.Storage.GetURLTranslations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(translations) != 2 {
+		t.Errorf("unexpected translations: %v", translations)
+	}
+	for _, source := range []string{ms.URL + "/amp/article", ms.URL + "/short"} {
+		if translations[source] != ms.URL+"/article" {
+			t.Errorf("unexpected translation for %s: %s", source, translations[source])
+		}
+	}
+}
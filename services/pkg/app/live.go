@@ -0,0 +1,105 @@
+package app
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/classify"
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// AggregateLiveLinks generates a snapshot from the live aggregation maintained by intake, rather than reading event chunks from storage.
+// This takes seconds rather than minutes, so rankings (i.e. the top hour) can be refreshed on demand.
+func AggregateLiveLinks() (links.Snapshot, error) {
+	slog.Info("starting live snapshot generation")
+	jobStart := time.Now()
+
+	app, err := NewApp()
+	if err != nil {
+		return links.Snapshot{}, util.WrapErr("failed to create app", err)
+	}
+	defer app.Close()
+
+	app.Blocklist, err = newBlocklist(app.Storage)
+	if err != nil {
+		return links.Snapshot{}, err
+	}
+	app.Classifier, err = classify.New(app.Config)
+	if err != nil {
+		return links.Snapshot{}, util.WrapErr("failed to create classifier", err)
+	}
+
+	// The live aggregation is read regardless of whether this process would update it
+	if app.Live == nil {
+		app.Live, err = newLive(app.Config)
+		if err != nil {
+			return links.Snapshot{}, util.WrapErr("failed to create live aggregation", err)
+		}
+	}
+	if app.Config.CacheBackend == config.CacheBackendMemory {
+		slog.Warn("live aggregation is in memory, and is not shared with intake")
+	}
+
+	windows, err := links.ParseWindows(app.Config.LinkWindows)
+	if err != nil {
+		return links.Snapshot{}, util.WrapErr("failed to parse link windows", err)
+	}
+	bounds := links.NewTimeBounds(time.Now().UTC(), windows)
+	scorer, err := links.NewScorer(app.Config.ScoringModel, app.Config.ScoringParams)
+	if err != nil {
+		return links.Snapshot{}, util.WrapErr("failed to create scorer", err)
+	}
+
+	translations, err := app.Storage.GetURLTranslations()
+	if err != nil {
+		return links.Snapshot{}, util.WrapErr("failed to get url translations", err)
+	}
+
+	aggregation, err := liveAggregation(app.Live, bounds, scorer, translations)
+	if err != nil {
+		return links.Snapshot{}, err
+	}
+	slog.Info("processed live buckets", "count", aggregation.Total())
+
+	// Intake only updates the live aggregation for the default report's language
+	snapshot, err := newLinkSnapshot(app, &aggregation, app.Config.PrimaryLanguage())
+	if err != nil {
+		return links.Snapshot{}, err
+	}
+
+	jobDuration := time.Since(jobStart)
+	slog.Info("live aggregation complete", "seconds", jobDuration.Seconds())
+	return snapshot, nil
+}
+
+// Build an aggregation from the live buckets within the longest window.
+// Events were deduplicated as they were recorded, so the aggregation's own filter is unused.
+//
+// Results match batch aggregation, with three exceptions:
+//   - Each bucket is counted at the start of its minute, so events within a minute of a window's start may be counted differently
+//   - A user repeating an interaction shortly after their original interaction left the longest window is not counted again
+//   - Interactions are deduplicated by their original URL, as translations are only known at aggregation.
+//     A user interacting with two URLs that translate to the same URL (i.e. a short link, and its destination) is counted twice.
+func liveAggregation(lv Live, bounds links.TimeBounds, scorer links.Scorer, trans map[string]string) (links.Aggregation, error) {
+	buckets, err := lv.Buckets(bounds)
+	if err != nil {
+		return links.Aggregation{}, util.WrapErr("failed to read live buckets", err)
+	}
+
+	aggregation := links.NewAggregation(bounds, scorer, dedupe.NewExact())
+	for _, bucket := range buckets {
+		for url, link := range bucket.Links {
+			// Determine if there is a known translation (i.e. redirect) for this URL.
+			// If so, use the translated URL instead.
+			if translated, ok := trans[url]; ok {
+				url = translated
+			}
+			aggregation.CountBucket(url, link.Counts, link.Posts, link.FirstSeen, bucket.Start)
+		}
+	}
+
+	return aggregation, nil
+}
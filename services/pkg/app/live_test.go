@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/live"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// Test that the live aggregation ranks links the same as batch aggregation, given the same events
+func TestLiveMatchesBatch(t *testing.T) {
+	end := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	windows, _ := links.ParseWindows("15m,1h,6h,24h,3d,7d")
+	bounds := links.NewTimeBounds(end, windows)
+
+	// Generate events over the past week, including duplicates.
+	// Events are kept away from the start of each window, where live buckets are less precise.
+	random := rand.New(rand.NewSource(1))
+	events := make([]storage.EventRecord, 0)
+	for i := 0; i < 20000; i++ {
+		ts := end.Add(-time.Duration(random.Int63n(int64(7 * 24 * time.Hour))))
+		if slices.ContainsFunc(bounds.Windows, func(w links.Window) bool { return ts.Sub(bounds.Start(w)).Abs() < 2*time.Minute }) {
+			continue
+		}
+		url := fmt.Sprintf("https://www.example.com/%d", random.Intn(200))
+		events = append(events, storage.EventRecord{
+			Type:      random.Intn(3),
+			URL:       url,
+			DID:       fmt.Sprintf("did:plc:user%d", random.Intn(100)),
+			Timestamp: ts,
+			Post:      fmt.Sprintf("at://did:plc:user%d/app.bsky.feed.post/%d", random.Intn(100), random.Intn(5)),
+		})
+	}
+	slices.SortFunc(events, func(a, b storage.EventRecord) int { return a.Timestamp.Compare(b.Timestamp) })
+
+	// Batch: reduce the events to a partial, and merge it
+	batch := links.NewAggregation(bounds, links.DefaultLinearScorer(), dedupe.NewExact())
+	for _, link := range reduceChunk("chunk", events).Links {
+		for i := 0; i < link.Len(); i++ {
+			batch.CountInteraction(int(link.Types[i]), link.URL, link.Posts[link.PostRefs[i]], link.Users[i], time.Unix(link.Times[i], 0).UTC())
+		}
+	}
+
+	// Live: record the events as intake would, as they arrive
+	now := time.Time{}
+	lv := live.NewMemoryWithClock(bounds.Longest().Duration, func() time.Time { return now })
+	for _, event := range events {
+		now = event.Timestamp
+		lv.Record(event)
+	}
+	now = end
+	streamed, err := liveAggregation(lv, bounds, links.DefaultLinearScorer(), map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if streamed.Total() != batch.Total() {
+		t.Errorf("expected %d total, got %d", batch.Total(), streamed.Total())
+	}
+	for _, window := range bounds.Windows {
+		expected := batch.TopLinks(window.Name, ListSize)
+		actual := streamed.TopLinks(window.Name, ListSize)
+		if !slices.Equal(expected, actual) {
+			t.Errorf("unexpected top links for window %s: expected %v, got %v", window.Name, expected, actual)
+		}
+
+		for _, url := range expected {
+			expectedItem, actualItem := batch.Get(url), streamed.Get(url)
+			if !slices.Equal(expectedItem.Counts, actualItem.Counts) || !expectedItem.FirstSeen.Equal(actualItem.FirstSeen) {
+				t.Errorf("unexpected counts for %s: expected %v, got %v", url, expectedItem.Counts, actualItem.Counts)
+			}
+			if !maps.Equal(expectedItem.Posts, actualItem.Posts) {
+				t.Errorf("unexpected posts for %s", url)
+			}
+		}
+	}
+}
+
+// Test that intake updates the live aggregation, when enabled
+func TestIntakeUpdatesLive(t *testing.T) {
+	app, _ := newTestApp(t)
+	lv := live.NewMemory(7 * 24 * time.Hour)
+	app.Live = lv
+
+	_, err := ReplayStream(t.Context(), app, testRecordingPath(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounds := links.NewTimeBounds(time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC), []links.Window{{Name: "24h", Duration: 24 * time.Hour}})
+	buckets, err := lv.Buckets(bounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicate events are stored, but not counted
+	expected := 0
+	fingerprints := dedupe.NewExact()
+	for _, event := range readTestEvents(t, app) {
+		if !fingerprints.TestAndAdd(dedupe.Fingerprint(event.URL, event.Type, dedupe.HashDID(event.DID))) {
+			expected++
+		}
+	}
+
+	total := 0
+	for _, bucket := range buckets {
+		for _, link := range bucket.Links {
+			total += link.Counts.Posts + link.Counts.Reposts + link.Counts.Likes
+		}
+	}
+	if total == 0 || total != expected {
+		t.Errorf("expected %d events in live aggregation, got %d", expected, total)
+	}
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/georgemblack/blue-report/pkg/links"
+)
+
+// Remove links matching the blocklist from a window's ranked candidates.
+// Removed links that would have been listed (i.e. within the top 'ListSize') are returned, along with the rule that matched.
+func moderateLinks(blocklist Blocklist, window string, urls []string) ([]string, []links.RemovedLink) {
+	removed := make([]links.RemovedLink, 0)
+	if blocklist == nil {
+		return urls, removed
+	}
+
+	allowed := make([]string, 0, len(urls))
+	for i, url := range urls {
+		rule, blocked := blocklist.Match(url)
+		if !blocked {
+			allowed = append(allowed, url)
+			continue
+		}
+		if i < ListSize {
+			removed = append(removed, links.RemovedLink{URL: url, Window: window, Rank: i + 1, Rule: rule.ID, Reason: rule.Reason})
+			slog.Info("removed top link", "url", url, "window", window, "rank", i+1, "rule", rule.ID)
+		}
+	}
+	return allowed, removed
+}
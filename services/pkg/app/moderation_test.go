@@ -0,0 +1,47 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/moderation"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// staticBlocklist matches URLs against a fixed set of rules
+type staticBlocklist struct {
+	blocklist moderation.Blocklist
+}
+
+func (s staticBlocklist) Match(url string) (storage.BlockRule, bool) {
+	return s.blocklist.Match(url, time.Now())
+}
+
+func (s staticBlocklist) Close() {}
+
+// Test that blocked links are removed, and only recorded if they would have been listed
+func TestModerateLinks(t *testing.T) {
+	blocklist, _ := moderation.NewBlocklist([]storage.BlockRule{{ID: "spam", Type: moderation.HostRule, Value: "spam.com", Reason: "spam"}})
+
+	urls := make([]string, 0)
+	for i := 0; i < ListSize+5; i++ {
+		urls = append(urls, fmt.Sprintf("https://example.com/%d", i))
+	}
+	urls[1] = "https://spam.com/a"
+	urls[ListSize+1] = "https://spam.com/b"
+
+	allowed, removed := moderateLinks(staticBlocklist{blocklist}, "24h", urls)
+	if len(allowed) != len(urls)-2 || allowed[1] != "https://example.com/2" {
+		t.Errorf("unexpected allowed links: %v", allowed)
+	}
+	if len(removed) != 1 || removed[0].URL != "https://spam.com/a" || removed[0].Rank != 2 || removed[0].Rule != "spam" || removed[0].Window != "24h" {
+		t.Errorf("unexpected removed links: %v", removed)
+	}
+
+	// Without a blocklist, nothing is removed
+	allowed, removed = moderateLinks(nil, "24h", urls)
+	if len(allowed) != len(urls) || len(removed) != 0 {
+		t.Errorf("expected no links removed, got %v", removed)
+	}
+}
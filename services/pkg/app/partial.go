@@ -0,0 +1,91 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/urltools"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// loadPartial returns the partial aggregate of a chunk.
+// If the chunk has not been reduced yet (or was reduced by an older version), read its events, reduce them, and save the result.
+// Saving is best effort, as the partial can always be rebuilt from the chunk.
+func loadPartial(st Storage, chunk string) (storage.Partial, error) {
+	partial, err := st.ReadPartial(chunk)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to read partial", err).Error(), "chunk", chunk)
+	}
+	if partial.Valid() {
+		return partial, nil
+	}
+
+	records, err := st.ReadEvents(chunk, EventBufferSize)
+	if err != nil {
+		return storage.Partial{}, util.WrapErr("failed to read events", err)
+	}
+	partial = reduceChunk(chunk, records)
+
+	err = st.SavePartial(partial)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to save partial", err).Error(), "chunk", chunk)
+	}
+	return partial, nil
+}
+
+// reduceChunk groups a chunk's events by URL and language, keeping only what is needed to aggregate them later.
+//
+// URLs stored in events should already be filtered and normalized.
+// However, as rules change, past events may need to be re-processed.
+// This ensures the most up-to-date rules are applied. (Partials are keyed by the cleaning rules, and 'storage.PartialVersion' must be incremented when the reduce logic changes.)
+// Translations (i.e. redirects) change frequently, so they are applied when merging partials, rather than here.
+func reduceChunk(chunk string, records []storage.EventRecord) storage.Partial {
+	partial := storage.Partial{Chunk: chunk, Events: len(records)}
+	indexes := make(map[[2]string]int)    // Index of each URL and language in 'partial.Links'
+	posts := make([]map[string]uint32, 0) // Index of each post in 'partial.Links[i].Posts'
+	accounts := make(map[string]bool)
+
+	for _, record := range records {
+		if urltools.Ignore(record.URL) {
+			continue
+		}
+		cleanedURL := urltools.Clean(record.URL)
+
+		key := [2]string{cleanedURL, record.Language}
+		i, ok := indexes[key]
+		if !ok {
+			i = len(partial.Links)
+			indexes[key] = i
+			partial.Links = append(partial.Links, storage.PartialLink{URL: cleanedURL, Language: record.Language})
+			posts = append(posts, make(map[string]uint32))
+		}
+
+		link := &partial.Links[i]
+		ref, ok := posts[i][record.Post]
+		if !ok {
+			ref = uint32(len(link.Posts))
+			posts[i][record.Post] = ref
+			link.Posts = append(link.Posts, record.Post)
+		}
+
+		if !accounts[record.DID] {
+			accounts[record.DID] = true
+			partial.Accounts = append(partial.Accounts, record.DID)
+		}
+
+		if len(record.Labels) > 0 {
+			if link.Labels == nil {
+				link.Labels = make(map[uint32][]string)
+			}
+			link.Labels[uint32(link.Len())] = record.Labels
+		}
+
+		link.Types = append(link.Types, byte(record.Type))
+		link.Users = append(link.Users, dedupe.HashDID(record.DID))
+		link.Times = append(link.Times, record.Timestamp.Unix())
+		link.PostRefs = append(link.PostRefs, ref)
+	}
+
+	return partial
+}
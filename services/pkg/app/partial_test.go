@@ -0,0 +1,139 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+func partialTestEvents() []storage.EventRecord {
+	ts := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	return []storage.EventRecord{
+		{Type: 0, URL: "https://www.example.com/article?utm_source=bsky", DID: "did:plc:user1", Timestamp: ts, Post: "at://did:plc:user1/app.bsky.feed.post/1"},
+		{Type: 2, URL: "https://www.example.com/article", DID: "did:plc:user2", Timestamp: ts.Add(time.Second), Post: "at://did:plc:user1/app.bsky.feed.post/1"},
+		{Type: 2, URL: "https://www.example.com/article", DID: "did:plc:user2", Timestamp: ts.Add(2 * time.Second), Post: "at://did:plc:user1/app.bsky.feed.post/1"},
+		{Type: 0, URL: "https://www.example.com/other", DID: "did:plc:user3", Timestamp: ts.Add(3 * time.Second), Post: "at://did:plc:user3/app.bsky.feed.post/3"},
+		{Type: 0, URL: "https://bsky.app/profile/someone", DID: "did:plc:user3", Timestamp: ts.Add(4 * time.Second), Post: "at://did:plc:user3/app.bsky.feed.post/4"},
+	}
+}
+
+// Test that events are grouped by cleaned URL, and ignored URLs are dropped
+func TestReduceChunk(t *testing.T) {
+	partial := reduceChunk("chunk", partialTestEvents())
+
+	if partial.Chunk != "chunk" || partial.Events != 5 {
+		t.Errorf("unexpected partial: %+v", partial)
+	}
+	if len(partial.Links) != 2 {
+		t.Fatalf("expected 2 links, got %d", len(partial.Links))
+	}
+
+	article := partial.Links[0]
+	if article.URL != "https://www.example.com/article" || article.Len() != 3 {
+		t.Errorf("unexpected link: %+v", article)
+	}
+	if len(article.Posts) != 1 || article.PostRefs[2] != 0 {
+		t.Errorf("expected posts to be stored once, got %v", article.Posts)
+	}
+	if article.Users[1] != dedupe.HashDID("did:plc:user2") || article.Times[1] != partialTestEvents()[1].Timestamp.Unix() {
+		t.Errorf("unexpected interaction: %d, %d", article.Users[1], article.Times[1])
+	}
+	if !slices.Equal(partial.Accounts, []string{"did:plc:user1", "did:plc:user2", "did:plc:user3"}) {
+		t.Errorf("unexpected accounts: %v", partial.Accounts)
+	}
+}
+
+// Test that interactions in different languages are kept separate, so each language can be aggregated on its own
+func TestReduceChunkLanguages(t *testing.T) {
+	events := partialTestEvents()
+	for i := range events {
+		events[i].Language = "en"
+	}
+	events[2].Language = "ja"
+
+	partial := reduceChunk("chunk", events)
+	if len(partial.Links) != 3 {
+		t.Fatalf("expected 3 links, got %d", len(partial.Links))
+	}
+	for _, link := range partial.Links {
+		if link.URL == "https://www.example.com/article" && link.Language == "ja" && link.Len() != 1 {
+			t.Errorf("unexpected link: %+v", link)
+		}
+	}
+}
+
+// Test that labels are kept for each labelled interaction only
+func TestReduceChunkLabels(t *testing.T) {
+	events := partialTestEvents()
+	events[2].Labels = []string{"porn"}
+
+	partial := reduceChunk("chunk", events)
+	article := partial.Links[0]
+	if len(article.Labels) != 1 || !slices.Equal(article.Labels[2], []string{"porn"}) {
+		t.Errorf("unexpected labels: %v", article.Labels)
+	}
+	if partial.Links[1].Labels != nil {
+		t.Errorf("expected no labels, got %v", partial.Links[1].Labels)
+	}
+}
+
+// Test that a chunk is reduced once, and its partial is reused even after the chunk's events are gone
+func TestLoadPartial(t *testing.T) {
+	app, _ := newTestApp(t)
+	key := storage.ChunkKey{Start: time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC), Instance: "intake", Worker: 1}
+	err := app.Storage.FlushEvents(key, partialTestEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := key.String() + ".msgpack.zst"
+
+	partial, err := loadPartial(app.Storage, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(app.Config.LocalStorageDir, "events", chunk))
+
+	reused, err := loadPartial(app.Storage, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reused.Links) != len(partial.Links) || reused.Links[0].Len() != 3 {
+		t.Errorf("unexpected partial: %+v", reused)
+	}
+}
+
+// Test that merging partials produces the same counts as counting events directly
+func TestMergePartials(t *testing.T) {
+	events := partialTestEvents()
+	bounds := links.NewTimeBounds(events[0].Timestamp.Add(time.Hour), []links.Window{{Name: "24h", Duration: 24 * time.Hour}})
+
+	direct := links.NewAggregation(bounds, links.DefaultLinearScorer(), dedupe.NewExact())
+	direct.CountEvent(0, "https://www.example.com/article", "at://did:plc:user1/app.bsky.feed.post/1", "did:plc:user1", events[0].Timestamp)
+	direct.CountEvent(2, "https://www.example.com/article", "at://did:plc:user1/app.bsky.feed.post/1", "did:plc:user2", events[1].Timestamp)
+	direct.CountEvent(2, "https://www.example.com/article", "at://did:plc:user1/app.bsky.feed.post/1", "did:plc:user2", events[2].Timestamp)
+	direct.CountEvent(0, "https://www.example.com/other", "at://did:plc:user3/app.bsky.feed.post/3", "did:plc:user3", events[3].Timestamp)
+
+	merged := links.NewAggregation(bounds, links.DefaultLinearScorer(), dedupe.NewExact())
+	partial := reduceChunk("chunk", events)
+	for _, link := range partial.Links {
+		for i := 0; i < link.Len(); i++ {
+			merged.CountInteraction(int(link.Types[i]), link.URL, link.Posts[link.PostRefs[i]], link.Users[i], time.Unix(link.Times[i], 0).UTC())
+		}
+	}
+
+	if merged.Total() != direct.Total() || merged.Skipped() != direct.Skipped() {
+		t.Errorf("expected %d total and %d skipped, got %d and %d", direct.Total(), direct.Skipped(), merged.Total(), merged.Skipped())
+	}
+	for _, url := range []string{"https://www.example.com/article", "https://www.example.com/other"} {
+		expected, actual := direct.Get(url), merged.Get(url)
+		if actual.Total() != expected.Total() {
+			t.Errorf("unexpected counts for %s: %v", url, actual.Total())
+		}
+	}
+}
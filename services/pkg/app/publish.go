@@ -15,7 +15,7 @@ import (
 )
 
 // PublishLinkSnapshot publishes data for the 'top links' report to storage, where it is then read by a static site generator.
-// It also updates the feed of top posts, which is used by Atom/JSON generator.
+// For the default report's language, it also updates the feed of top posts, which is used by Atom/JSON generator.
 func PublishLinkSnapshot(snapshot links.Snapshot) error {
 	slog.Info("publishing snapshot")
 	start := time.Now()
@@ -24,16 +24,22 @@ func PublishLinkSnapshot(snapshot links.Snapshot) error {
 	if err != nil {
 		return util.WrapErr("failed to create app", err)
 	}
+	defer app.Close()
 
 	// Save data to storage as JSON
 	data, err := json.Marshal(snapshot)
 	if err != nil {
 		return util.WrapErr("failed to marshal snapshot", err)
 	}
-	err = app.Storage.PublishLinkSnapshot(data)
+	kind := LinkSnapshotKind(app.Config, snapshot.Language)
+	err = app.Storage.PublishLinkSnapshot(kind, data)
 	if err != nil {
 		return util.WrapErr("failed to publish snapshot", err)
 	}
+	if kind != storage.LinkSnapshot {
+		slog.Info("publish complete", "language", snapshot.Language, "seconds", time.Since(start).Seconds())
+		return nil
+	}
 
 	if os.Getenv("DEBUG") == "true" {
 		os.WriteFile("dist/snapshot.json", data, 0644)
@@ -95,6 +101,7 @@ func PublishSiteSnapshot(snapshot sites.Snapshot) error {
 	if err != nil {
 		return util.WrapErr("failed to create app", err)
 	}
+	defer app.Close()
 
 	// Save snapshot to storage as JSON
 	data, err := json.Marshal(snapshot)
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/cache"
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/util"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ReplayInstance = "replay" // Instance name used for chunks written during a replay
+	MaxFrameSize   = 1 << 20  // Maximum size of a single recorded Jetstream frame
+)
+
+// RecordStream reads raw frames from the Jetstream at the given endpoint and writes them to a file, one per line.
+// Recording stops once the context is cancelled, or once 'limit' frames have been recorded (if non-zero).
+// Files ending in '.zst' are compressed.
+func RecordStream(ctx context.Context, endpoint string, path string, limit int) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, util.WrapErr("failed to create recording", err)
+	}
+	defer file.Close()
+
+	var out io.Writer = file
+	var enc *zstd.Encoder
+	if strings.HasSuffix(path, ".zst") {
+		enc, err = zstd.NewWriter(file)
+		if err != nil {
+			return 0, util.WrapErr("failed to create zstd writer", err)
+		}
+		out = enc
+	}
+	writer := bufio.NewWriter(out)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The cursor is kept in memory, so recording does not affect the cursor used by intake
+	recorded := 0
+	var writeErr error
+	jetstream := NewJetstream(endpoint, cache.NewMemory())
+	streamErr := jetstream.run(ctx, func(message []byte, _ StreamEvent) {
+		if writeErr != nil || (limit > 0 && recorded >= limit) {
+			return
+		}
+
+		// Frames are written one per line, so ensure they contain no newlines
+		var frame bytes.Buffer
+		if err := json.Compact(&frame, message); err != nil {
+			return
+		}
+		frame.WriteByte('\n')
+		if _, writeErr = writer.Write(frame.Bytes()); writeErr != nil {
+			cancel()
+			return
+		}
+
+		recorded++
+		if limit > 0 && recorded >= limit {
+			cancel()
+		}
+	})
+
+	err = writer.Flush()
+	if enc != nil && err == nil {
+		err = enc.Close()
+	}
+	if writeErr != nil {
+		err = writeErr
+	}
+	if err != nil {
+		return recorded, util.WrapErr("failed to write recording", err)
+	}
+	return recorded, streamErr
+}
+
+// ReplayStream pushes a recorded file through the intake worker logic, writing chunks to the app's storage.
+// Events are replayed by a single worker, in order, so the resulting chunks are deterministic.
+//
+// Time is driven by the recording: events are timestamped with their 'time_us', and an in-memory cache expires records based on the time of the event being replayed.
+// A 'speed' of zero replays as fast as possible. Otherwise, the original pacing between events is preserved, sped up by the given factor.
+//
+// Replayed events are otherwise indistinguishable from those written by intake, so only local storage and the in-memory cache are allowed.
+// As with intake, links matching the blocklist in local storage (or the default rules, if none have been saved) are skipped.
+func ReplayStream(ctx context.Context, app App, path string, speed float64) (int, error) {
+	if app.Config.StorageBackend != config.StorageBackendLocal || app.Config.CacheBackend != config.CacheBackendMemory {
+		return 0, fmt.Errorf("replay requires local storage and the in-memory cache, got %s storage and %s cache", app.Config.StorageBackend, app.Config.CacheBackend)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, util.WrapErr("failed to open recording", err)
+	}
+	defer file.Close()
+
+	var in io.Reader = file
+	if strings.HasSuffix(path, ".zst") {
+		dec, err := zstd.NewReader(file)
+		if err != nil {
+			return 0, util.WrapErr("failed to create zstd reader", err)
+		}
+		defer dec.Close()
+		in = dec
+	}
+
+	blocklist, err := newBlocklist(app.Storage)
+	if err != nil {
+		return 0, err
+	}
+	defer blocklist.Close()
+	app.Blocklist = blocklist
+
+	clock := &replayClock{}
+	app.Cache = cache.NewMemoryWithClock(clock.now)
+	app.Config.InstanceID = ReplayInstance
+
+	w := newWorker(1, app, nil, nil)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxFrameSize)
+
+	replayed := 0
+	var first time.Time // Time of the first event in the recording
+	started := time.Now()
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		event := StreamEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			slog.Warn(util.WrapErr("failed to read json", err).Error())
+			continue
+		}
+
+		// Sleep until the event is due, relative to the start of the replay
+		if event.TimeUS != 0 {
+			if first.IsZero() {
+				first = event.Time()
+			}
+			if speed > 0 {
+				due := started.Add(time.Duration(float64(event.Time().Sub(first)) / speed))
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Until(due)):
+				}
+			}
+			clock.set(event.Time())
+		}
+
+		err := w.handle(event)
+		if err != nil {
+			w.close()
+			return replayed, err
+		}
+		replayed++
+	}
+
+	w.close()
+	if err := scanner.Err(); err != nil {
+		return replayed, util.WrapErr("failed to read recording", err)
+	}
+
+	slog.Info("replay complete", "events", replayed, "seconds", time.Since(started).Seconds())
+	return replayed, nil
+}
+
+// replayClock reports the time of the event currently being replayed.
+// Events are replayed from a single goroutine, so no locking is required.
+type replayClock struct {
+	t time.Time
+}
+
+func (c *replayClock) now() time.Time {
+	return c.t
+}
+
+func (c *replayClock) set(t time.Time) {
+	c.t = t
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/moderation"
+	"github.com/georgemblack/blue-report/pkg/testutil"
+)
+
+// Replay a recording into local storage, returning the contents of each chunk written
+func replayTestRecording(t *testing.T, path string) map[string][]byte {
+	app, _ := newTestApp(t)
+	app.Config.CacheBackend = config.CacheBackendMemory
+
+	_, err := ReplayStream(context.Background(), app, path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(app.Config.LocalStorageDir, "events")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := make(map[string][]byte)
+	for _, entry := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+		chunks[entry.Name()] = data
+	}
+	return chunks
+}
+
+func testRecordingPath(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	os.WriteFile(path, testutil.GetTestData("recording.jsonl"), 0644)
+	return path
+}
+
+// Test that replaying the same recording twice produces identical chunks
+func TestReplayIsDeterministic(t *testing.T) {
+	path := testRecordingPath(t)
+
+	first := replayTestRecording(t, path)
+	second := replayTestRecording(t, path)
+
+	if len(first) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(first))
+	}
+	for name, data := range first {
+		if name != "2025-01-04-19-40-00_replay_01_000000.msgpack.zst" {
+			t.Errorf("unexpected chunk name: %s", name)
+		}
+		if !bytes.Equal(data, second[name]) {
+			t.Errorf("chunk %s differs between replays", name)
+		}
+	}
+}
+
+// Test the events written by a replay. Posts without URLs, and likes of unknown posts, are skipped.
+func TestReplayEvents(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.Config.CacheBackend = config.CacheBackendMemory
+
+	replayed, err := ReplayStream(context.Background(), app, testRecordingPath(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 12 {
+		t.Errorf("expected 12 frames replayed, got %d", replayed)
+	}
+
+	records := readTestEvents(t, app)
+	if len(records) != 8 {
+		t.Fatalf("expected 8 events, got %d", len(records))
+	}
+
+	// Events are timestamped with their 'time_us'
+	if !records[0].Timestamp.Equal(time.UnixMicro(1736019600000000)) {
+		t.Errorf("unexpected timestamp: %s", records[0].Timestamp)
+	}
+	likes, reposts := 0, 0
+	for _, record := range records {
+		if record.IsLike() {
+			likes++
+		}
+		if record.IsRepost() {
+			reposts++
+		}
+	}
+	if likes != 5 || reposts != 1 {
+		t.Errorf("unexpected likes (%d) or reposts (%d)", likes, reposts)
+	}
+}
+
+// Test that links matching the blocklist saved to local storage are skipped, as they are by intake
+func TestReplayBlocklist(t *testing.T) {
+	recording := testutil.GetTestData("recording.jsonl")
+	first, _, _ := bytes.Cut(recording, []byte("\n"))
+	blocked := bytes.ReplaceAll(first, []byte("https://tylervigen.com/the-mystery-of-the-bloomfield-bridge"), []byte("https://www.example.com/article"))
+	blocked = bytes.ReplaceAll(blocked, []byte("3ldkcy6xjvc2l"), []byte("3ldkcy6xjvc3m"))
+
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	if err := os.WriteFile(path, append(append(blocked, '\n'), first...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app, _ := newTestApp(t)
+	rule, err := moderation.NewRule(moderation.HostRule, "www.example.com", "test", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := moderation.AddRule(app.Storage, rule); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplayStream(context.Background(), app, path, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readTestEvents(t, app)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(records))
+	}
+	if records[0].URL != "https://tylervigen.com/the-mystery-of-the-bloomfield-bridge" {
+		t.Errorf("unexpected url: %s", records[0].URL)
+	}
+}
+
+// Test that pacing follows the recording. The recording spans 12ms, so replaying at 0.1x should take at least 120ms.
+func TestReplaySpeed(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.Config.CacheBackend = config.CacheBackendMemory
+
+	start := time.Now()
+	_, err := ReplayStream(context.Background(), app, testRecordingPath(t), 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("replay finished too quickly: %s", elapsed)
+	}
+}
+
+// Test recording frames from the Jetstream to a compressed file, then replaying them
+func TestRecordAndReplay(t *testing.T) {
+	standIn := newJetstreamStandIn([][]string{
+		{testJetstreamMessage(1736019684000001), testJetstreamMessage(1736019684000002), testJetstreamMessage(1736019684000003)},
+	}, true)
+	defer standIn.server.Close()
+
+	path := filepath.Join(t.TempDir(), "recording.jsonl.zst")
+	recorded, err := RecordStream(context.Background(), standIn.url(), path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 2 {
+		t.Errorf("expected 2 frames recorded, got %d", recorded)
+	}
+
+	app, _ := newTestApp(t)
+	replayed, err := ReplayStream(context.Background(), app, path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 2 {
+		t.Errorf("expected 2 frames replayed, got %d", replayed)
+	}
+}
+
+// Test that replays refuse to run against anything other than local storage and the in-memory cache
+func TestReplayRequiresLocalBackends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.jsonl")
+	if err := os.WriteFile(path, []byte(testJetstreamMessage(1736019684000001)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app, _ := newTestApp(t)
+	app.Config.StorageBackend = config.StorageBackendAWS
+	if replayed, err := ReplayStream(context.Background(), app, path, 0); err == nil || replayed != 0 {
+		t.Error("expected error for aws storage")
+	}
+
+	app.Config.StorageBackend = config.StorageBackendLocal
+	app.Config.CacheBackend = config.CacheBackendValkey
+	if replayed, err := ReplayStream(context.Background(), app, path, 0); err == nil || replayed != 0 {
+		t.Error("expected error for valkey cache")
+	}
+}
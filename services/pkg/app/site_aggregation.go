@@ -5,8 +5,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/georgemblack/blue-report/pkg/integrity"
+	"github.com/georgemblack/blue-report/pkg/labels"
 	"github.com/georgemblack/blue-report/pkg/sites"
-	"github.com/georgemblack/blue-report/pkg/urltools"
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
@@ -24,11 +25,25 @@ func AggregateSites() (sites.Snapshot, error) {
 	}
 	defer app.Close()
 
-	// Create the aggregation.
-	// This will be used to generate all the data required to render the report.
-	aggregation := sites.NewAggregation()
+	app.Blocklist, err = newBlocklist(app.Storage)
+	if err != nil {
+		return sites.Snapshot{}, err
+	}
+
 	end := time.Now().UTC()
 	start := end.Add(-30 * 24 * time.Hour) // 30 days
+	chunks, err := app.Storage.ListEventChunks(start, end)
+	if err != nil {
+		return sites.Snapshot{}, util.WrapErr("failed to list event chunks", err)
+	}
+
+	// Create the aggregation.
+	// This will be used to generate all the data required to render the report.
+	fingerprints, err := newFilter(app.Config, len(chunks))
+	if err != nil {
+		return sites.Snapshot{}, util.WrapErr("failed to create dedupe filter", err)
+	}
+	aggregation := sites.NewAggregation(fingerprints)
 
 	// Fetch all known translations (i.e. URL redirects).
 	// Apply them as we process events.
@@ -38,10 +53,16 @@ func AggregateSites() (sites.Snapshot, error) {
 	}
 	slog.Info("loaded url translations", "count", len(translations))
 
-	chunks, err := app.Storage.ListEventChunks(start, end)
-	if err != nil {
-		return sites.Snapshot{}, util.WrapErr("failed to list event chunks", err)
+	// Detect suspicious accounts, so their interactions can be down-weighted or excluded.
+	// The report is saved by link aggregation, which runs more often.
+	var verdicts *integrity.Verdicts
+	if app.Config.IntegrityChecks {
+		verdicts, _, err = CheckIntegrity(app, end)
+		if err != nil {
+			return sites.Snapshot{}, util.WrapErr("failed to check integrity", err)
+		}
 	}
+
 	length := len(chunks)
 
 	// Start worker threads to divide the work.
@@ -58,7 +79,7 @@ func AggregateSites() (sites.Snapshot, error) {
 		if i == SiteAggregationWorkerCount-1 {
 			end = length
 		}
-		go aggregateSitesWorker(i, app.Storage, chunks[start:end], &aggregation, translations, &wg, errs)
+		go aggregateSitesWorker(i, app.Storage, chunks[start:end], &aggregation, translations, app.Blocklist, verdicts, app.Config.LabelPolicy, app.Config.PrimaryLanguage(), &wg, errs)
 	}
 
 	wg.Wait()
@@ -71,13 +92,15 @@ func AggregateSites() (sites.Snapshot, error) {
 		}
 	}
 
-	slog.Info("processed events", "count", aggregation.Total(), "skipped", aggregation.Skipped())
+	dedupeStats := aggregation.Deduplication()
+	slog.Info("processed events", "count", aggregation.Total(), "skipped", aggregation.Skipped(), "estimated_false_positives", dedupeStats.EstimatedFalsePositives)
 
 	// Sort sites based on number of interactions
 	top := aggregation.TopSites(ListSize)
 
 	// Format data into a snapshot
 	snapshot := sites.NewSnapshot()
+	snapshot.Deduplication = dedupeStats
 	for _, site := range top {
 		snapshot.AddSite(site, aggregation.Get(site))
 	}
@@ -93,37 +116,46 @@ func AggregateSites() (sites.Snapshot, error) {
 	return snapshot, nil
 }
 
-func aggregateSitesWorker(id int, st Storage, chunks []string, agg *sites.Aggregation, trans map[string]string, wg *sync.WaitGroup, errs chan error) {
+func aggregateSitesWorker(id int, st Storage, chunks []string, agg *sites.Aggregation, trans map[string]string, blocklist Blocklist, verdicts *integrity.Verdicts, policy labels.Policy, language string, wg *sync.WaitGroup, errs chan error) {
 	defer wg.Done()
 
 	for _, chunk := range chunks {
 		slog.Debug("processing chunk", "worker", id, "chunk", chunk)
 
-		records, err := st.ReadEvents(chunk, EventBufferSize)
+		// Each chunk is only reduced once, after which its partial aggregate is reused by later runs
+		partial, err := loadPartial(st, chunk)
 		if err != nil {
-			errs <- util.WrapErr("failed to read events", err)
+			errs <- util.WrapErr("failed to load partial", err)
 			return
 		}
 
-		for _, record := range records {
-			// URLs stored in events should already be filtered and normalized.
-			// However, as rules change, past events may need to be re-processed.
-			// This ensures the most up-to-date rules are applied.
-			if urltools.Ignore(record.URL) {
+		for _, link := range partial.Links {
+			// Sites are only ranked for the default report's language
+			if link.Language != language {
 				continue
 			}
-			cleanedURL := urltools.Clean(record.URL)
 
 			// Determine if there is a known translation (i.e. redirect) for this URL.
 			// If so, use the translated URL instead.
-			if translated, ok := trans[cleanedURL]; ok {
-				cleanedURL = translated
+			url := link.URL
+			if translated, ok := trans[url]; ok {
+				url = translated
 			}
 
-			// Count the event. This is thread safe.
-			agg.CountEvent(record.Type, cleanedURL, record.DID)
-		}
+			// Skip URLs excluded by the blocklist
+			if blocklist != nil {
+				if _, blocked := blocklist.Match(url); blocked {
+					continue
+				}
+			}
 
-		records = nil // Help the garbage collector
+			// Count each interaction, skipping those from flagged accounts, and those with labels excluded by the policy. This is thread safe.
+			for i := 0; i < link.Len(); i++ {
+				if !verdicts.Counted(link.Users[i], url) || !policy.Counted(link.Labels[uint32(i)]) {
+					continue
+				}
+				agg.CountInteraction(int(link.Types[i]), url, link.Users[i])
+			}
+		}
 	}
 }
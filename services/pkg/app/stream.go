@@ -2,7 +2,9 @@ package app
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/georgemblack/blue-report/pkg/labels"
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
@@ -10,6 +12,7 @@ import (
 // Fields for both posts and reposts are included.
 type StreamEvent struct {
 	DID    string `json:"did"`
+	TimeUS int64  `json:"time_us"` // Unix microseconds, used as the Jetstream cursor
 	Kind   string `json:"kind"`
 	Commit Commit `json:"commit"`
 }
@@ -27,6 +30,16 @@ type Record struct {
 	Embed     Embed    `json:"embed"`
 	Facets    []Facet  `json:"facets"`
 	Subject   Subject  `json:"subject"`
+	Labels    Labels   `json:"labels"` // Self-labels applied by the author of a post
+}
+
+type Labels struct {
+	Type   string  `json:"$type"`
+	Values []Label `json:"values"`
+}
+
+type Label struct {
+	Val string `json:"val"`
 }
 
 type Embed struct {
@@ -72,7 +85,8 @@ type Feature struct {
 }
 
 // Valid determines whether a stream event can be processed by our application.
-func (s *StreamEvent) Valid() bool {
+// Posts must be in one of the given languages. Likes and reposts are checked against the language of the post they reference later.
+func (s *StreamEvent) Valid(languages []string) bool {
 	if s.Kind != "commit" {
 		return false
 	}
@@ -82,7 +96,7 @@ func (s *StreamEvent) Valid() bool {
 	if !s.IsPost() && !s.IsRepost() && !s.IsLike() {
 		return false
 	}
-	if s.IsPost() && !s.IsEnglish() {
+	if s.IsPost() && s.Language(languages) == "" {
 		return false
 	}
 	return true
@@ -109,8 +123,26 @@ func (s *StreamEvent) IsLike() bool {
 	return s.Commit.Record.Type == "app.bsky.feed.like"
 }
 
-func (s *StreamEvent) IsEnglish() bool {
-	return util.ContainsStr(s.Commit.Record.Languages, "en")
+// Language returns the first of the post's languages that is in the given set, or an empty string if none are.
+func (s *StreamEvent) Language(languages []string) string {
+	for _, lang := range s.Commit.Record.Languages {
+		if util.ContainsStr(languages, lang) {
+			return lang
+		}
+	}
+	return ""
+}
+
+// Labels returns the values of the self-labels applied to a post, i.e. 'porn'.
+func (s *StreamEvent) Labels() []string {
+	if !s.IsPost() || len(s.Commit.Record.Labels.Values) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(s.Commit.Record.Labels.Values))
+	for _, label := range s.Commit.Record.Labels.Values {
+		values = append(values, label.Val)
+	}
+	return labels.Merge(values)
 }
 
 // Parse a post to extract the URL, title, and image.
@@ -148,6 +180,15 @@ func (s *StreamEvent) ParsePost() (string, string, string) {
 	return "", "", ""
 }
 
+// Time returns the time the event was emitted by the Jetstream (i.e. 'time_us').
+// Using the event's time, rather than the time it was processed, keeps timestamps stable when events are resumed or replayed.
+func (s *StreamEvent) Time() time.Time {
+	if s.TimeUS == 0 {
+		return time.Now().UTC()
+	}
+	return time.UnixMicro(s.TimeUS).UTC()
+}
+
 func (s *StreamEvent) TypeOf() int {
 	if s.IsPost() {
 		return 0
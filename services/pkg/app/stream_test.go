@@ -56,3 +56,13 @@ func TestParsePostWithFacetOnly(t *testing.T) {
 		t.Errorf("unexpected image url: %s", image)
 	}
 }
+
+// Test parsing the Jetstream cursor from an event.
+func TestParseTimeUS(t *testing.T) {
+	bytes := testutil.GetTestData("like.json")
+	event := toStreamEvent(bytes)
+
+	if event.TimeUS != 1736019684902212 {
+		t.Errorf("unexpected time_us: %d", event.TimeUS)
+	}
+}
@@ -4,20 +4,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/georgemblack/blue-report/pkg/config"
 )
 
+// MaxLabelQuery is the maximum number of labels returned by a single query to the labeler.
+const MaxLabelQuery = 250
+
 type Bluesky struct {
-	endpoint string
+	endpoint        string
+	labelerEndpoint string
+	labelerDID      string
 }
 
 func New(cfg config.Config) Bluesky {
-	return Bluesky{endpoint: cfg.BlueskyAPIEndpoint}
+	return Bluesky{endpoint: cfg.BlueskyAPIEndpoint, labelerEndpoint: cfg.LabelerEndpoint, labelerDID: cfg.LabelerDID}
 }
 
+// GetPost fetches a post, including its labels. If a labeler is configured, the labels it has applied are included.
 func (b Bluesky) GetPost(atURI string) (Post, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/xrpc/app.bsky.feed.getPosts?uris=%s", b.endpoint, atURI))
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/xrpc/app.bsky.feed.getPosts?uris=%s", b.endpoint, atURI), nil)
+	if err != nil {
+		return Post{}, err
+	}
+	if b.labelerDID != "" {
+		req.Header.Set("atproto-accept-labelers", b.labelerDID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return Post{}, err
 	}
@@ -34,3 +49,35 @@ func (b Bluesky) GetPost(atURI string) (Post, error) {
 
 	return posts.Posts[0], nil
 }
+
+// GetLabels queries the configured labeler for the labels applied to each of the given AT URIs.
+// URIs without labels are omitted. If no labeler is configured, no labels are returned.
+func (b Bluesky) GetLabels(atURIs []string) (map[string][]string, error) {
+	if b.labelerEndpoint == "" || len(atURIs) == 0 {
+		return map[string][]string{}, nil
+	}
+
+	params := url.Values{}
+	for _, uri := range atURIs {
+		params.Add("uriPatterns", uri)
+	}
+	if b.labelerDID != "" {
+		params.Set("sources", b.labelerDID)
+	}
+	params.Set("limit", fmt.Sprint(MaxLabelQuery))
+
+	resp, err := http.Get(fmt.Sprintf("%s/xrpc/com.atproto.label.queryLabels?%s", b.labelerEndpoint, params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from labeler: %d", resp.StatusCode)
+	}
+
+	var result QueryLabels
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return Values(result.Labels), nil
+}
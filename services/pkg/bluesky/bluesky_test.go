@@ -0,0 +1,40 @@
+package bluesky
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+)
+
+// Test that labels are queried from the labeler, and that negated labels are removed
+func TestGetLabels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/com.atproto.label.queryLabels" || len(r.URL.Query()["uriPatterns"]) != 2 || r.URL.Query().Get("sources") != "did:plc:labeler" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		w.Write([]byte(`{"labels": [
+			{"uri": "at://a", "val": "porn", "cts": "2025-03-03T12:00:00Z"},
+			{"uri": "at://a", "val": "spam", "cts": "2025-03-03T12:01:00Z"},
+			{"uri": "at://a", "val": "porn", "neg": true, "cts": "2025-03-03T12:02:00Z"},
+			{"uri": "at://b", "val": "gore", "cts": "2025-03-03T12:00:00Z", "exp": "2025-03-04T12:00:00Z"}
+		]}`))
+	}))
+	defer server.Close()
+
+	bs := New(config.Config{LabelerEndpoint: server.URL, LabelerDID: "did:plc:labeler"})
+	result, err := bs.GetLabels([]string{"at://a", "at://b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || len(result["at://a"]) != 1 || result["at://a"][0] != "spam" {
+		t.Errorf("unexpected labels: %v", result)
+	}
+
+	// Without a labeler, nothing is queried
+	result, err = New(config.Config{}).GetLabels([]string{"at://a"})
+	if err != nil || len(result) != 0 {
+		t.Errorf("expected no labels, got %v (%v)", result, err)
+	}
+}
@@ -1,14 +1,22 @@
 package bluesky
 
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
 type Posts struct {
 	Posts []Post `json:"posts"`
 }
 
 type Post struct {
-	URI       string `json:"uri"`
-	Author    Author `json:"author"`
-	Record    Record `json:"record"`
-	LikeCount int    `json:"likeCount"`
+	URI       string  `json:"uri"`
+	CID       string  `json:"cid"`
+	Author    Author  `json:"author"`
+	Record    Record  `json:"record"`
+	LikeCount int     `json:"likeCount"`
+	Labels    []Label `json:"labels"`
 }
 
 type Author struct {
@@ -22,11 +30,59 @@ type Record struct {
 	Text      string   `json:"text"`
 }
 
-func (p Post) IsEnglish() bool {
+// Label is a moderation label applied to a post (or account) by a labeler, or self-applied by the author.
+type Label struct {
+	Src string `json:"src"` // DID of the labeler (or author)
+	URI string `json:"uri"` // AT URI of the labelled post or account
+	Val string `json:"val"` // Value of the label, i.e. 'porn'
+	Neg bool   `json:"neg"` // Whether this removes a previously applied label
+	Cts string `json:"cts"` // Time the label was created
+	Exp string `json:"exp"` // Time the label expires, if any
+}
+
+type QueryLabels struct {
+	Labels []Label `json:"labels"`
+}
+
+func (p Post) HasLanguage(language string) bool {
 	for _, lang := range p.Record.Languages {
-		if lang == "en" {
+		if lang == language {
 			return true
 		}
 	}
 	return false
 }
+
+// LabelValues returns the values of the labels applied to the post itself.
+func (p Post) LabelValues() []string {
+	return Values(p.Labels)[p.URI]
+}
+
+// Values returns the active label values for each labelled URI.
+// Labels are applied in the order they were created, so a negation removes any earlier label with the same value. Expired labels are skipped.
+func Values(labels []Label) map[string][]string {
+	sorted := slices.Clone(labels)
+	slices.SortStableFunc(sorted, func(a, b Label) int {
+		return strings.Compare(a.Cts, b.Cts)
+	})
+
+	now := time.Now()
+	result := make(map[string][]string)
+	for _, label := range sorted {
+		if label.Exp != "" {
+			if exp, err := time.Parse(time.RFC3339, label.Exp); err == nil && exp.Before(now) {
+				continue
+			}
+		}
+		values := slices.DeleteFunc(result[label.URI], func(v string) bool { return v == label.Val })
+		if !label.Neg {
+			values = append(values, label.Val)
+		}
+		if len(values) == 0 {
+			delete(result, label.URI)
+			continue
+		}
+		result[label.URI] = values
+	}
+	return result
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/georgemblack/blue-report/pkg/config"
@@ -16,8 +17,12 @@ import (
 // If a record is 'refreshed', the TTL is reset to this value.
 const TTLSeconds = 43200 // 12 hours
 
+// CursorKey is the key used to store the Jetstream cursor. Unlike post and URL records, the cursor does not expire.
+const CursorKey = "jetstream:cursor"
+
 type Valkey struct {
 	client valkey.Client
+	ttl    time.Duration
 }
 
 // New creates a new Valkey client.
@@ -37,7 +42,7 @@ func New(cfg config.Config) (Valkey, error) {
 		return Valkey{}, util.WrapErr("failed to create valkey client", err)
 	}
 
-	return Valkey{client: client}, nil
+	return Valkey{client: client, ttl: time.Second * TTLSeconds}, nil
 }
 
 // SaveURL saves a URL record to the cache.
@@ -48,7 +53,7 @@ func (v Valkey) SaveURL(hash string, url URLRecord) error {
 	}
 
 	key := fmt.Sprintf("url:%s", hash)
-	cmd := v.client.B().Set().Key(key).Value(string(bytes)).Ex(time.Second * TTLSeconds).Build()
+	cmd := v.client.B().Set().Key(key).Value(string(bytes)).Ex(v.ttl).Build()
 	err = v.client.Do(context.Background(), cmd).Error()
 	if err != nil {
 		return util.WrapErr("failed to set key", err)
@@ -86,7 +91,7 @@ func (v Valkey) ReadURL(hash string) (URLRecord, error) {
 // RefreshURL refreshes the TTL of a URL record in the cache.
 func (v Valkey) RefreshURL(hash string) error {
 	key := fmt.Sprintf("url:%s", hash)
-	cmd := v.client.B().Expire().Key(key).Seconds(TTLSeconds).Build()
+	cmd := v.client.B().Expire().Key(key).Seconds(int64(v.ttl.Seconds())).Build()
 	err := v.client.Do(context.Background(), cmd).Error()
 	if err != nil {
 		return util.WrapErr("failed to expire key", err)
@@ -103,7 +108,7 @@ func (v Valkey) SavePost(hash string, post PostRecord) error {
 	}
 
 	key := fmt.Sprintf("post:%s", hash)
-	cmd := v.client.B().Set().Key(key).Value(string(bytes)).Ex(time.Second * TTLSeconds).Build()
+	cmd := v.client.B().Set().Key(key).Value(string(bytes)).Ex(v.ttl).Build()
 	err = v.client.Do(context.Background(), cmd).Error()
 	if err != nil {
 		return util.WrapErr("failed to set key", err)
@@ -141,7 +146,7 @@ func (v Valkey) ReadPost(hash string) (PostRecord, error) {
 // RefreshPost refreshes the TTL of a post record in the cache.
 func (v Valkey) RefreshPost(hash string) error {
 	key := fmt.Sprintf("post:%s", hash)
-	cmd := v.client.B().Expire().Key(key).Seconds(TTLSeconds).Build()
+	cmd := v.client.B().Expire().Key(key).Seconds(int64(v.ttl.Seconds())).Build()
 	err := v.client.Do(context.Background(), cmd).Error()
 	if err != nil {
 		return util.WrapErr("failed to expire key", err)
@@ -150,6 +155,36 @@ func (v Valkey) RefreshPost(hash string) error {
 	return nil
 }
 
+// SaveCursor saves the Jetstream cursor (i.e. the 'time_us' of the most recently received event) to the cache.
+func (v Valkey) SaveCursor(cursor int64) error {
+	cmd := v.client.B().Set().Key(CursorKey).Value(strconv.FormatInt(cursor, 10)).Build()
+	err := v.client.Do(context.Background(), cmd).Error()
+	if err != nil {
+		return util.WrapErr("failed to set key", err)
+	}
+
+	return nil
+}
+
+// ReadCursor reads the Jetstream cursor from the cache. If no cursor has been saved, return zero.
+func (v Valkey) ReadCursor() (int64, error) {
+	cmd := v.client.B().Get().Key(CursorKey).Build()
+	resp := v.client.Do(context.Background(), cmd)
+	if err := resp.Error(); err != nil {
+		if err == valkey.Nil {
+			return 0, nil
+		}
+		return 0, util.WrapErr("failed to execute get command", err)
+	}
+
+	cursor, err := resp.AsInt64()
+	if err != nil {
+		return 0, util.WrapErr("failed to convert response to int", err)
+	}
+
+	return cursor, nil
+}
+
 func (v Valkey) Close() {
 	v.client.Close()
 }
@@ -160,7 +195,9 @@ type URLRecord struct {
 }
 
 type PostRecord struct {
-	URL string `msgpack:"u"`
+	URL      string   `msgpack:"u"`
+	Language string   `msgpack:"l"`           // Inherited by likes and reposts of the post
+	Labels   []string `msgpack:"b,omitempty"` // Self-labels of the post, also inherited by likes and reposts
 }
 
 func (p PostRecord) Valid() bool {
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+)
+
+// cacheUnderTest mirrors the cache interface used by the app
+type cacheUnderTest interface {
+	SaveURL(hash string, url URLRecord) error
+	ReadURL(hash string) (URLRecord, error)
+	RefreshURL(hash string) error
+	SavePost(hash string, post PostRecord) error
+	ReadPost(hash string) (PostRecord, error)
+	RefreshPost(hash string) error
+	SaveCursor(cursor int64) error
+	ReadCursor() (int64, error)
+	Close()
+}
+
+// harness provides a cache with a short TTL, and a way to advance time past it
+type harness struct {
+	cache   cacheUnderTest
+	ttl     time.Duration
+	advance func(d time.Duration)
+}
+
+// Test the in-memory cache against the conformance suite, using a fake clock
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) harness {
+		var mu sync.Mutex
+		now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+		cache := NewMemoryWithClock(func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		})
+		cache.ttl = 10 * time.Second
+		return harness{
+			cache: cache,
+			ttl:   cache.ttl,
+			advance: func(d time.Duration) {
+				mu.Lock()
+				defer mu.Unlock()
+				now = now.Add(d)
+			},
+		}
+	})
+}
+
+// Test Valkey against the conformance suite. Only runs if 'VALKEY_TEST_ADDRESS' is set.
+// Expiry is tested in real time, and the cursor is overwritten, so use a disposable instance.
+func TestValkeyConformance(t *testing.T) {
+	address := os.Getenv("VALKEY_TEST_ADDRESS")
+	if address == "" {
+		t.Skip("VALKEY_TEST_ADDRESS not set")
+	}
+
+	runConformance(t, func(t *testing.T) harness {
+		cache, err := New(config.Config{ValkeyAddress: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.ttl = 2 * time.Second
+		return harness{
+			cache:   cache,
+			ttl:     cache.ttl,
+			advance: time.Sleep,
+		}
+	})
+}
+
+func runConformance(t *testing.T, newHarness func(t *testing.T) harness) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, h harness, prefix string)
+	}{
+		{"ReadMissing", testReadMissing},
+		{"SaveAndRead", testSaveAndRead},
+		{"Overwrite", testOverwrite},
+		{"Expiry", testExpiry},
+		{"Refresh", testRefresh},
+		{"RefreshMissing", testRefreshMissing},
+		{"SaveResetsTTL", testSaveResetsTTL},
+		{"Cursor", testCursor},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newHarness(t)
+			defer h.cache.Close()
+
+			// Keys are unique per run, so tests against a shared instance do not interfere
+			prefix := fmt.Sprintf("conformance-%s-%d", test.name, time.Now().UnixNano())
+			test.run(t, h, prefix)
+		})
+	}
+}
+
+func testReadMissing(t *testing.T, h harness, prefix string) {
+	url, err := h.cache.ReadURL(prefix + "url")
+	if err != nil || url != (URLRecord{}) {
+		t.Errorf("expected empty url record, got %v (%v)", url, err)
+	}
+	post, err := h.cache.ReadPost(prefix + "post")
+	if err != nil || post.Valid() {
+		t.Errorf("expected empty post record, got %v (%v)", post, err)
+	}
+}
+
+func testSaveAndRead(t *testing.T, h harness, prefix string) {
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 5, Normalized: true})
+	h.cache.SavePost(prefix, PostRecord{URL: "https://example.com"})
+
+	// URLs and posts with the same hash are stored separately
+	url, err := h.cache.ReadURL(prefix)
+	if err != nil || url.Interactions != 5 || !url.Normalized {
+		t.Errorf("unexpected url record: %v (%v)", url, err)
+	}
+	post, err := h.cache.ReadPost(prefix)
+	if err != nil || post.URL != "https://example.com" {
+		t.Errorf("unexpected post record: %v (%v)", post, err)
+	}
+}
+
+func testOverwrite(t *testing.T, h harness, prefix string) {
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 1})
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 2})
+
+	url, _ := h.cache.ReadURL(prefix)
+	if url.Interactions != 2 {
+		t.Errorf("expected 2 interactions, got %d", url.Interactions)
+	}
+}
+
+func testExpiry(t *testing.T, h harness, prefix string) {
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 1})
+	h.cache.SavePost(prefix, PostRecord{URL: "https://example.com"})
+
+	h.advance(h.ttl / 2)
+	if url, _ := h.cache.ReadURL(prefix); url.Interactions != 1 {
+		t.Error("expected url record to exist before ttl")
+	}
+
+	h.advance(h.ttl)
+	if url, _ := h.cache.ReadURL(prefix); url.Interactions != 0 {
+		t.Error("expected url record to expire after ttl")
+	}
+	if post, _ := h.cache.ReadPost(prefix); post.Valid() {
+		t.Error("expected post record to expire after ttl")
+	}
+}
+
+func testRefresh(t *testing.T, h harness, prefix string) {
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 1})
+	h.cache.SavePost(prefix, PostRecord{URL: "https://example.com"})
+
+	// Refreshing part way through the ttl should extend the record's life
+	step := h.ttl * 3 / 5
+	h.advance(step)
+	h.cache.RefreshURL(prefix)
+	h.cache.RefreshPost(prefix)
+	h.advance(step)
+
+	if url, _ := h.cache.ReadURL(prefix); url.Interactions != 1 {
+		t.Error("expected refreshed url record to exist")
+	}
+	if post, _ := h.cache.ReadPost(prefix); !post.Valid() {
+		t.Error("expected refreshed post record to exist")
+	}
+
+	h.advance(step)
+	if url, _ := h.cache.ReadURL(prefix); url.Interactions != 0 {
+		t.Error("expected refreshed url record to expire")
+	}
+}
+
+func testRefreshMissing(t *testing.T, h harness, prefix string) {
+	if err := h.cache.RefreshURL(prefix); err != nil {
+		t.Errorf("unexpected error refreshing missing url: %s", err)
+	}
+	if err := h.cache.RefreshPost(prefix); err != nil {
+		t.Errorf("unexpected error refreshing missing post: %s", err)
+	}
+
+	// Refreshing does not create a record
+	if post, _ := h.cache.ReadPost(prefix); post.Valid() {
+		t.Error("expected post record to not exist")
+	}
+}
+
+func testSaveResetsTTL(t *testing.T, h harness, prefix string) {
+	step := h.ttl * 3 / 5
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 1})
+	h.advance(step)
+	h.cache.SaveURL(prefix, URLRecord{Interactions: 2})
+	h.advance(step)
+
+	if url, _ := h.cache.ReadURL(prefix); url.Interactions != 2 {
+		t.Errorf("expected saved url record to exist, got %v", url)
+	}
+}
+
+func testCursor(t *testing.T, h harness, prefix string) {
+	h.cache.SaveCursor(1736019684902212)
+
+	// The cursor never expires
+	h.advance(h.ttl * 2)
+	cursor, err := h.cache.ReadCursor()
+	if err != nil || cursor != 1736019684902212 {
+		t.Errorf("unexpected cursor: %d (%v)", cursor, err)
+	}
+}
+
+// Test that expired records are eventually removed from memory, not just hidden
+func TestMemorySweep(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	cache := NewMemoryWithClock(func() time.Time { return now })
+
+	for i := 0; i < 100; i++ {
+		cache.SavePost(fmt.Sprintf("%d", i), PostRecord{URL: "https://example.com"})
+	}
+
+	now = now.Add(time.Second*TTLSeconds + SweepInterval)
+	cache.SavePost("new", PostRecord{URL: "https://example.com"})
+
+	if len(cache.posts) != 1 {
+		t.Errorf("expected 1 post after sweep, got %d", len(cache.posts))
+	}
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// SweepInterval is how often expired records are removed from the in-memory cache.
+// Expired records are never returned, regardless of whether they have been swept.
+const SweepInterval = time.Minute
+
+// Memory is an in-process cache, with the same TTL semantics as Valkey.
+// Time is read from an injectable clock, so expiry can be tested (or replayed) deterministically.
+type Memory struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	ttl     time.Duration
+	urls    map[string]entry[URLRecord]
+	posts   map[string]entry[PostRecord]
+	cursor  int64
+	sweptAt time.Time
+}
+
+type entry[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+// NewMemory creates an in-memory cache using the system clock.
+func NewMemory() *Memory {
+	return NewMemoryWithClock(time.Now)
+}
+
+// NewMemoryWithClock creates an in-memory cache that reads the current time from the given clock.
+func NewMemoryWithClock(now func() time.Time) *Memory {
+	return &Memory{
+		now:     now,
+		ttl:     time.Second * TTLSeconds,
+		urls:    make(map[string]entry[URLRecord]),
+		posts:   make(map[string]entry[PostRecord]),
+		sweptAt: now(),
+	}
+}
+
+// SaveURL saves a URL record to the cache.
+func (m *Memory) SaveURL(hash string, url URLRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.urls[hash] = entry[URLRecord]{value: url, expiresAt: m.expiry()}
+	return nil
+}
+
+// ReadURL reads a URL record from the cache. If the record does not exist, return an empty record.
+func (m *Memory) ReadURL(hash string) (URLRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return read(m, m.urls, hash), nil
+}
+
+// RefreshURL refreshes the TTL of a URL record in the cache.
+func (m *Memory) RefreshURL(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refresh(m, m.urls, hash)
+	return nil
+}
+
+// SavePost saves a post record to the cache.
+func (m *Memory) SavePost(hash string, post PostRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.posts[hash] = entry[PostRecord]{value: post, expiresAt: m.expiry()}
+	return nil
+}
+
+// ReadPost reads a post record from the cache. If the record does not exist, return an empty record.
+func (m *Memory) ReadPost(hash string) (PostRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return read(m, m.posts, hash), nil
+}
+
+// RefreshPost refreshes the TTL of a post record in the cache.
+func (m *Memory) RefreshPost(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refresh(m, m.posts, hash)
+	return nil
+}
+
+// SaveCursor saves the Jetstream cursor. Like Valkey, the cursor does not expire.
+func (m *Memory) SaveCursor(cursor int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cursor = cursor
+	return nil
+}
+
+// ReadCursor reads the Jetstream cursor. If no cursor has been saved, return zero.
+func (m *Memory) ReadCursor() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cursor, nil
+}
+
+func (m *Memory) Close() {}
+
+// Return the expiry time of a record saved (or refreshed) now.
+// This also sweeps expired records, at most once per 'SweepInterval'.
+func (m *Memory) expiry() time.Time {
+	now := m.now()
+	if now.Sub(m.sweptAt) >= SweepInterval {
+		sweep(m.urls, now)
+		sweep(m.posts, now)
+		m.sweptAt = now
+	}
+	return now.Add(m.ttl)
+}
+
+func read[T any](m *Memory, records map[string]entry[T], hash string) T {
+	record, ok := records[hash]
+	if !ok || !m.now().Before(record.expiresAt) {
+		var empty T
+		return empty
+	}
+	return record.value
+}
+
+// Refreshing a missing (or expired) record has no effect, matching Valkey's 'EXPIRE'.
+func refresh[T any](m *Memory, records map[string]entry[T], hash string) {
+	record, ok := records[hash]
+	if !ok || !m.now().Before(record.expiresAt) {
+		return
+	}
+	record.expiresAt = m.expiry()
+	records[hash] = record
+}
+
+func sweep[T any](records map[string]entry[T], now time.Time) {
+	for hash, record := range records {
+		if !now.Before(record.expiresAt) {
+			delete(records, hash)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package classify
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/llm"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// Categories assigned to links. Links that can't be classified are 'other'.
+const (
+	Politics      = "politics"
+	Science       = "science"
+	Sports        = "sports"
+	Tech          = "tech"
+	Entertainment = "entertainment"
+	Other         = "other"
+)
+
+// Categories lists every category (other than 'other'), in the order they are published.
+var Categories = []string{Politics, Science, Sports, Tech, Entertainment}
+
+const (
+	RulesClassifier = "rules"
+	LLMClassifier   = "llm"
+)
+
+// Classifier assigns a category to a link, based on its URL and title.
+// An empty category is returned if the classifier can't decide, so another classifier (or 'other') can be used instead.
+type Classifier interface {
+	Classify(linkURL, title string) (string, error)
+}
+
+// New creates the classifier selected by the config.
+// The 'llm' classifier still applies the rules first, and only asks the LLM about links the rules can't classify.
+func New(cfg config.Config) (Classifier, error) {
+	switch cfg.Classifier {
+	case RulesClassifier, "":
+		return DefaultRules(), nil
+	case LLMClassifier:
+		if cfg.OpenAIAPIKey == "" {
+			return nil, fmt.Errorf("llm classifier requires an openai api key")
+		}
+		return Chain{DefaultRules(), LLM{APIKey: cfg.OpenAIAPIKey}}, nil
+	default:
+		return nil, fmt.Errorf("unknown classifier: %s", cfg.Classifier)
+	}
+}
+
+// Chain tries each classifier in order, returning the first category found.
+type Chain []Classifier
+
+func (c Chain) Classify(linkURL, title string) (string, error) {
+	for _, classifier := range c {
+		category, err := classifier.Classify(linkURL, title)
+		if err != nil {
+			return "", err
+		}
+		if category != "" {
+			return category, nil
+		}
+	}
+	return "", nil
+}
+
+// LLM asks an LLM to pick the category of a link from its title.
+// Links without a title are not classified, as the URL alone is rarely enough.
+type LLM struct {
+	APIKey string
+}
+
+func (l LLM) Classify(linkURL, title string) (string, error) {
+	if title == "" {
+		return "", nil
+	}
+
+	options := append(append([]string{}, Categories...), Other)
+	category, err := llm.ClassifyLink(l.APIKey, linkURL, title, options)
+	if err != nil {
+		return "", util.WrapErr("failed to classify link", err)
+	}
+
+	// Responses outside the given categories are treated as 'other'
+	category = strings.ToLower(strings.Trim(strings.TrimSpace(category), ".\"'"))
+	if !util.ContainsStr(options, category) {
+		slog.Debug("unexpected category from llm", "url", linkURL, "category", category)
+		return Other, nil
+	}
+	return category, nil
+}
+
+// Return the host of the URL, without 'www.'.
+func host(linkURL string) string {
+	parsed, err := url.Parse(linkURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
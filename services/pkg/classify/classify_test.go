@@ -0,0 +1,76 @@
+package classify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+)
+
+// Test classifying links by domain and title keywords
+func TestRules(t *testing.T) {
+	rules := DefaultRules()
+	tests := []struct {
+		url      string
+		title    string
+		expected string
+	}{
+		{"https://www.theverge.com/2025/3/3/some-story", "Trump signs executive order", Tech},   // Domains win over keywords
+		{"https://sports.espn.com/story", "", Sports},                                           // Subdomains match
+		{"https://notespn.com/story", "", ""},                                                   // Only whole domains match
+		{"https://example.com/a", "Senate votes on the President's budget", Politics},           // Keywords match
+		{"https://example.com/b", "Scientists find new species; NASA telescope helps", Science}, // Most matches win
+		{"https://example.com/c", "The new Apple movie", Tech},                                  // Ties are broken by category order
+		{"https://example.com/d", "Maintaining a garden", ""},                                   // Keywords only match whole words ('ai')
+		{"https://example.com/e", "Box office: the weekend's top films", Entertainment},         // Phrases match
+		{"https://example.com/f", "", ""},
+	}
+
+	for _, test := range tests {
+		category, err := rules.Classify(test.url, test.title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if category != test.expected {
+			t.Errorf("unexpected category for '%s' (%s): expected '%s', got '%s'", test.title, test.url, test.expected, category)
+		}
+	}
+}
+
+type fixedClassifier struct {
+	category string
+	err      error
+}
+
+func (f fixedClassifier) Classify(linkURL, title string) (string, error) {
+	return f.category, f.err
+}
+
+// Test that a chain returns the first category found, and stops on errors
+func TestChain(t *testing.T) {
+	chain := Chain{fixedClassifier{}, fixedClassifier{category: Sports}, fixedClassifier{category: Tech}}
+	if category, _ := chain.Classify("https://example.com", ""); category != Sports {
+		t.Errorf("expected sports, got '%s'", category)
+	}
+
+	chain = Chain{fixedClassifier{err: errors.New("failed")}, fixedClassifier{category: Tech}}
+	if _, err := chain.Classify("https://example.com", ""); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// Test creating the classifier selected by the config
+func TestNew(t *testing.T) {
+	if _, err := New(config.Config{Classifier: RulesClassifier}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := New(config.Config{Classifier: LLMClassifier}); err == nil {
+		t.Error("expected error for llm classifier without api key")
+	}
+	if _, err := New(config.Config{Classifier: LLMClassifier, OpenAIAPIKey: "key"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := New(config.Config{Classifier: "unknown"}); err == nil {
+		t.Error("expected error for unknown classifier")
+	}
+}
@@ -0,0 +1,76 @@
+package classify
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Rules classify links by domain, falling back to keywords in the link's title.
+// Domains are more reliable than keywords, so a matching domain always wins.
+type Rules struct {
+	Domains  map[string][]string // Category -> domains, matching the domain and its subdomains
+	Keywords map[string][]string // Category -> lowercase words or phrases found in titles
+}
+
+// Classify returns the category with a matching domain, otherwise the category with the most matching keywords.
+// Ties between keywords are broken by the order of 'Categories'. If nothing matches, return an empty category.
+func (r Rules) Classify(linkURL, title string) (string, error) {
+	h := host(linkURL)
+	for _, category := range Categories {
+		for _, domain := range r.Domains[category] {
+			if h == domain || strings.HasSuffix(h, "."+domain) {
+				return category, nil
+			}
+		}
+	}
+
+	words := normalizeTitle(title)
+	if words == "" {
+		return "", nil
+	}
+
+	best, bestMatches := "", 0
+	for _, category := range Categories {
+		matches := 0
+		for _, keyword := range r.Keywords[category] {
+			if strings.Contains(words, " "+keyword+" ") {
+				matches++
+			}
+		}
+		if matches > bestMatches {
+			best, bestMatches = category, matches
+		}
+	}
+	return best, nil
+}
+
+// Lowercase the title and replace punctuation with spaces, padding with spaces so keywords only match whole words.
+func normalizeTitle(title string) string {
+	fields := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(fields) == 0 {
+		return ""
+	}
+	return " " + strings.Join(fields, " ") + " "
+}
+
+// DefaultRules contains domains and keywords for the sites and stories most commonly shared on Bluesky.
+func DefaultRules() Rules {
+	return Rules{
+		Domains: map[string][]string{
+			Politics:      {"politico.com", "thehill.com", "rollcall.com", "talkingpointsmemo.com", "whitehouse.gov", "congress.gov", "fivethirtyeight.com"},
+			Science:       {"nature.com", "science.org", "scientificamerican.com", "newscientist.com", "phys.org", "quantamagazine.org", "arxiv.org", "nasa.gov", "sciencealert.com"},
+			Sports:        {"espn.com", "theathletic.com", "si.com", "bleacherreport.com", "nfl.com", "nba.com", "mlb.com", "nhl.com", "sbnation.com"},
+			Tech:          {"theverge.com", "arstechnica.com", "techcrunch.com", "wired.com", "404media.co", "github.com", "engadget.com", "9to5mac.com", "macrumors.com"},
+			Entertainment: {"variety.com", "hollywoodreporter.com", "deadline.com", "ew.com", "pitchfork.com", "imdb.com", "avclub.com", "vulture.com"},
+		},
+		Keywords: map[string][]string{
+			Politics:      {"trump", "biden", "congress", "senate", "senator", "election", "democrats", "republicans", "gop", "supreme court", "white house", "governor", "president", "vote", "voters", "impeachment", "doge", "lawmakers"},
+			Science:       {"study", "scientists", "researchers", "nasa", "climate", "species", "physics", "astronomers", "telescope", "fossil", "vaccine", "genome", "dinosaur", "asteroid"},
+			Sports:        {"nfl", "nba", "mlb", "nhl", "wnba", "super bowl", "world cup", "olympics", "playoffs", "quarterback", "touchdown", "championship", "coach", "season"},
+			Tech:          {"ai", "openai", "chatgpt", "apple", "google", "microsoft", "software", "iphone", "android", "startup", "bluesky", "encryption", "hackers", "chip"},
+			Entertainment: {"movie", "film", "album", "netflix", "box office", "oscars", "oscar", "grammy", "trailer", "actor", "actress", "singer", "tv series", "sitcom"},
+		},
+	}
+}
@@ -2,9 +2,19 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/labels"
+	"github.com/georgemblack/blue-report/pkg/links"
 	"github.com/georgemblack/blue-report/pkg/secrets"
+	"github.com/georgemblack/blue-report/pkg/urltools"
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
@@ -18,43 +28,105 @@ type Config struct {
 	FeedTableName               string // Name of the DynamoDB table used to store items that get posted by Atom/JSON feeds
 	ValkeyAddress               string
 	ValkeyTLSEnabled            bool
+	CacheBackend                string // Either 'valkey' or 'memory'. The in-memory cache is only shared by workers in a single process.
 	NoralizationQueueName       string
 	CloudflareAccountID         string
 	CloudflareAPIToken          string
 	CloudflareR2AccessKeyID     string
 	CloudflareR2SecretAccessKey string
 	OpenAIAPIKey                string
+	InstanceID                  string             // Identifies the running intake instance, used to prevent collisions between event chunks
+	IntakeWorkers               int                // Number of intake workers processing events from the Jetstream
+	EventCodec                  string             // Format used to write event chunks, i.e. 'json' (the original format) or 'columnar'
+	StorageBackend              string             // Either 'aws' (S3, R2, DynamoDB, and SQS) or 'local' (files under 'LocalStorageDir')
+	LocalStorageDir             string             // Directory used to store all data when using the 'local' storage backend
+	ScoringModel                string             // Model used to rank links, i.e. 'linear', 'gravity', or 'velocity'
+	ScoringParams               map[string]float64 // Overrides for the scoring model's default params, i.e. 'gravity=1.5,offset=2'
+	LinkWindows                 string             // Windows to rank links within, i.e. '1h,24h,7d'. Each window gets its own top list.
+	DedupeMode                  string             // Either 'bloom' (approximate, fixed memory) or 'exact' (memory grows with unique events)
+	LiveAggregation             bool               // Whether intake also updates the live aggregation, stored in the cache backend
+	Languages                   []string           // Languages of posts to count, i.e. 'en,ja'. Each gets its own report, and the first is the default report.
+	Classifier                  string             // Classifier used to assign categories to links, either 'rules' or 'llm' (rules, falling back to OpenAI)
+	MaxLinksPerHost             int                // Maximum number of links from the same host in each top list, or zero for no limit
+	IntegrityChecks             bool               // Whether suspicious accounts (i.e. bots) are detected, and their interactions down-weighted or excluded
+	PLCDirectoryEndpoint        string             // Used by integrity checks to look up the age of accounts
+	LabelPolicy                 labels.Policy      // Action taken on posts with each moderation label, i.e. 'porn=exclude,!warn=hide'
+	LabelerEndpoint             string             // Labeler queried for labels on recommended posts, or empty to only use labels returned with posts
+	LabelerDID                  string             // DID of the labeler, requested when fetching posts
+	RedirectMaxHops             int                // Maximum number of redirects followed when normalizing a URL
+	RedirectTimeout             time.Duration      // Timeout for each request made when following redirects
+}
+
+const (
+	DefaultLanguages    = "en"
+	CacheBackendValkey  = "valkey"
+	CacheBackendMemory  = "memory"
+	StorageBackendAWS   = "aws"
+	StorageBackendLocal = "local"
+)
+
+// Secrets fetched from AWS Secrets Manager, or the environment when running locally
+type secretValues struct {
+	cloudflareAccountID         string
+	cloudflareAPIToken          string
+	cloudflareR2AccessKeyID     string
+	cloudflareR2SecretAccessKey string
+	openAIAPIKey                string
 }
 
 func New() (Config, error) {
-	sm, err := secrets.New()
-	if err != nil {
-		return Config{}, util.WrapErr("failed to create secrets manager", err)
+	backend := util.GetEnvStr("STORAGE_BACKEND", StorageBackendAWS)
+	if backend != StorageBackendAWS && backend != StorageBackendLocal {
+		return Config{}, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+	cacheBackend := util.GetEnvStr("CACHE_BACKEND", CacheBackendValkey)
+	if cacheBackend != CacheBackendValkey && cacheBackend != CacheBackendMemory {
+		return Config{}, fmt.Errorf("unknown cache backend: %s", cacheBackend)
 	}
 
-	accountID, err := sm.GetCloudflareAccountID()
-	if err != nil {
-		return Config{}, util.WrapErr("failed to get cloudflare account id", err)
+	dedupeMode := util.GetEnvStr("DEDUPE_MODE", dedupe.BloomMode)
+	if dedupeMode != dedupe.BloomMode && dedupeMode != dedupe.ExactMode {
+		return Config{}, fmt.Errorf("unknown dedupe mode: %s", dedupeMode)
 	}
 
-	apiToken, err := sm.GetCloudflareAPIToken()
+	scoringParams, err := parseParams(util.GetEnvStr("SCORING_PARAMS", ""))
 	if err != nil {
-		return Config{}, util.WrapErr("failed to get cloudflare api token", err)
+		return Config{}, util.WrapErr("failed to parse scoring params", err)
 	}
 
-	r2AccessKeyID, err := sm.GetCloudflareR2AccessKeyID()
+	labelPolicy, err := labels.ParsePolicy(util.GetEnvStr("LABEL_POLICY", labels.DefaultPolicy))
 	if err != nil {
-		return Config{}, util.WrapErr("failed to get cloudflare r2 access key id", err)
+		return Config{}, util.WrapErr("failed to parse label policy", err)
 	}
 
-	r2SecretAccessKey, err := sm.GetCloudflareR2SecretAccessKey()
-	if err != nil {
-		return Config{}, util.WrapErr("failed to get cloudflare r2 secret access key", err)
+	intakeWorkers := util.GetEnvInt("INTAKE_WORKERS", 1)
+	if intakeWorkers < 1 {
+		return Config{}, fmt.Errorf("intake workers must be positive: %d", intakeWorkers)
 	}
 
-	aiAPIKey, err := sm.GetOpenAIAPIKey()
+	redirectMaxHops := util.GetEnvInt("REDIRECT_MAX_HOPS", urltools.DefaultMaxHops)
+	if redirectMaxHops < 1 {
+		return Config{}, fmt.Errorf("redirect max hops must be positive: %d", redirectMaxHops)
+	}
+	redirectTimeout, err := time.ParseDuration(util.GetEnvStr("REDIRECT_TIMEOUT", urltools.DefaultHopTimeout.String()))
 	if err != nil {
-		return Config{}, util.WrapErr("failed to get openai api key", err)
+		return Config{}, util.WrapErr("failed to parse redirect timeout", err)
+	}
+
+	languages := parseList(util.GetEnvStr("LANGUAGES", DefaultLanguages))
+	if len(languages) == 0 {
+		return Config{}, fmt.Errorf("no languages configured")
+	}
+
+	// When running locally, AWS is not available. Secrets are optionally read from the environment instead.
+	var values secretValues
+	if backend == StorageBackendLocal {
+		values = envSecrets()
+	} else {
+		values, err = awsSecrets()
+		if err != nil {
+			return Config{}, err
+		}
 	}
 
 	result := Config{
@@ -67,12 +139,33 @@ func New() (Config, error) {
 		FeedTableName:               util.GetEnvStr("DYNAMO_FEED_TABLE", "blue-report-feed-test"),
 		ValkeyAddress:               util.GetEnvStr("VALKEY_ADDRESS", "127.0.0.1:6379"),
 		ValkeyTLSEnabled:            util.GetEnvBool("VALKEY_TLS_ENABLED", false),
+		CacheBackend:                cacheBackend,
 		NoralizationQueueName:       util.GetEnvStr("SQS_NORMALIZATION_QUEUE_NAME", "blue-report-normalization-test"),
-		CloudflareAccountID:         accountID,
-		CloudflareAPIToken:          apiToken,
-		CloudflareR2AccessKeyID:     r2AccessKeyID,
-		CloudflareR2SecretAccessKey: r2SecretAccessKey,
-		OpenAIAPIKey:                aiAPIKey,
+		CloudflareAccountID:         values.cloudflareAccountID,
+		CloudflareAPIToken:          values.cloudflareAPIToken,
+		CloudflareR2AccessKeyID:     values.cloudflareR2AccessKeyID,
+		CloudflareR2SecretAccessKey: values.cloudflareR2SecretAccessKey,
+		OpenAIAPIKey:                values.openAIAPIKey,
+		InstanceID:                  util.GetEnvStr("INSTANCE_ID", hostname()),
+		IntakeWorkers:               intakeWorkers,
+		EventCodec:                  util.GetEnvStr("EVENT_CODEC", "json"),
+		StorageBackend:              backend,
+		LocalStorageDir:             util.GetEnvStr("LOCAL_STORAGE_DIR", "local"),
+		ScoringModel:                util.GetEnvStr("SCORING_MODEL", "linear"),
+		ScoringParams:               scoringParams,
+		LinkWindows:                 util.GetEnvStr("LINK_WINDOWS", links.DefaultWindows),
+		DedupeMode:                  dedupeMode,
+		LiveAggregation:             util.GetEnvBool("LIVE_AGGREGATION", false),
+		Languages:                   languages,
+		Classifier:                  util.GetEnvStr("CLASSIFIER", "rules"),
+		MaxLinksPerHost:             util.GetEnvInt("MAX_LINKS_PER_HOST", 0),
+		IntegrityChecks:             util.GetEnvBool("INTEGRITY_CHECKS", false),
+		PLCDirectoryEndpoint:        util.GetEnvStr("PLC_DIRECTORY_ENDPOINT", "https://plc.directory"),
+		LabelPolicy:                 labelPolicy,
+		LabelerEndpoint:             util.GetEnvStr("LABELER_ENDPOINT", "https://mod.bsky.app"),
+		LabelerDID:                  util.GetEnvStr("LABELER_DID", "did:plc:ar7c4by46qjdydhdevvrndac"),
+		RedirectMaxHops:             redirectMaxHops,
+		RedirectTimeout:             redirectTimeout,
 	}
 
 	// Marshal to JSON and print if debug is enabled
@@ -84,3 +177,108 @@ func New() (Config, error) {
 
 	return result, nil
 }
+
+func awsSecrets() (secretValues, error) {
+	sm, err := secrets.New()
+	if err != nil {
+		return secretValues{}, util.WrapErr("failed to create secrets manager", err)
+	}
+
+	accountID, err := sm.GetCloudflareAccountID()
+	if err != nil {
+		return secretValues{}, util.WrapErr("failed to get cloudflare account id", err)
+	}
+
+	apiToken, err := sm.GetCloudflareAPIToken()
+	if err != nil {
+		return secretValues{}, util.WrapErr("failed to get cloudflare api token", err)
+	}
+
+	r2AccessKeyID, err := sm.GetCloudflareR2AccessKeyID()
+	if err != nil {
+		return secretValues{}, util.WrapErr("failed to get cloudflare r2 access key id", err)
+	}
+
+	r2SecretAccessKey, err := sm.GetCloudflareR2SecretAccessKey()
+	if err != nil {
+		return secretValues{}, util.WrapErr("failed to get cloudflare r2 secret access key", err)
+	}
+
+	aiAPIKey, err := sm.GetOpenAIAPIKey()
+	if err != nil {
+		return secretValues{}, util.WrapErr("failed to get openai api key", err)
+	}
+
+	return secretValues{
+		cloudflareAccountID:         accountID,
+		cloudflareAPIToken:          apiToken,
+		cloudflareR2AccessKeyID:     r2AccessKeyID,
+		cloudflareR2SecretAccessKey: r2SecretAccessKey,
+		openAIAPIKey:                aiAPIKey,
+	}, nil
+}
+
+func envSecrets() secretValues {
+	return secretValues{
+		cloudflareAccountID:         util.GetEnvStr("CLOUDFLARE_ACCOUNT_ID", ""),
+		cloudflareAPIToken:          util.GetEnvStr("CLOUDFLARE_API_TOKEN", ""),
+		cloudflareR2AccessKeyID:     util.GetEnvStr("CLOUDFLARE_R2_ACCESS_KEY_ID", ""),
+		cloudflareR2SecretAccessKey: util.GetEnvStr("CLOUDFLARE_R2_SECRET_ACCESS_KEY", ""),
+		openAIAPIKey:                util.GetEnvStr("OPENAI_API_KEY", ""),
+	}
+}
+
+// ReportLanguages returns the configured languages, defaulting to English if none are set.
+func (c Config) ReportLanguages() []string {
+	if len(c.Languages) == 0 {
+		return parseList(DefaultLanguages)
+	}
+	return c.Languages
+}
+
+// PrimaryLanguage returns the language of the default report.
+func (c Config) PrimaryLanguage() string {
+	return c.ReportLanguages()[0]
+}
+
+// Parse a comma-separated list, i.e. 'en,ja', ignoring empty values.
+func parseList(value string) []string {
+	values := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !slices.Contains(values, item) {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// Parse a list of numeric params, i.e. 'a=1,b=2.5'.
+func parseParams(value string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	if strings.TrimSpace(value) == "" {
+		return params, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid param: %s", pair)
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, util.WrapErr(fmt.Sprintf("invalid value for param %s", key), err)
+		}
+		params[strings.TrimSpace(key)] = parsed
+	}
+	return params, nil
+}
+
+// Default instance ID. On ECS, each task has a unique hostname.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "intake"
+	}
+	return name
+}
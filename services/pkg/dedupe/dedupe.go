@@ -0,0 +1,174 @@
+package dedupe
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+const (
+	BloomMode = "bloom" // Approximate, with a fixed memory footprint. Some unique fingerprints are reported as duplicates.
+	ExactMode = "exact" // Exact (up to 64-bit hash collisions), with memory proportional to the number of unique fingerprints
+
+	Precision = 0.001 // 0.1% target false-positive rate for bloom filters
+	MinEvents = 10000 // Bloom filters are never sized for fewer events than this
+	NumShards = 256   // Number of shards used by the exact filter
+)
+
+// Filter detects duplicate fingerprints, i.e. the same user liking the same link twice. Filters are thread safe.
+type Filter interface {
+	// TestAndAdd reports whether the fingerprint has already been seen, and adds it if not.
+	TestAndAdd(fingerprint string) bool
+	Stats() Stats
+}
+
+// Stats describes a filter, so the accuracy of an aggregation can be reported alongside it.
+type Stats struct {
+	Mode                    string `json:"mode"`
+	Capacity                uint   `json:"capacity,omitempty"`        // Number of events the bloom filter was sized for
+	Duplicates              int64  `json:"duplicates"`                // Number of events reported as duplicates
+	EstimatedFalsePositives int64  `json:"estimated_false_positives"` // Estimated number of unique events incorrectly reported as duplicates
+}
+
+// New creates a filter using the given mode. Bloom filters are sized for 'events' fingerprints.
+func New(mode string, events uint) (Filter, error) {
+	switch mode {
+	case BloomMode:
+		return NewBloom(events), nil
+	case ExactMode:
+		return NewExact(), nil
+	default:
+		return nil, fmt.Errorf("unknown dedupe mode: %s", mode)
+	}
+}
+
+// HashDID returns a 64-bit hash of a user's DID, used in place of the DID when building fingerprints.
+func HashDID(did string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(did))
+	return hash.Sum64()
+}
+
+// Fingerprint identifies a single user's interaction of a given type with a URL.
+// At most, a single user can like, post, and repost a link once.
+func Fingerprint(url string, eventType int, user uint64) string {
+	return fmt.Sprintf("%s%d%x", url, eventType, user)
+}
+
+// Bloom wraps a bloom filter, tracking the expected number of false positives as it fills.
+type Bloom struct {
+	lock       sync.Mutex
+	filter     *bloom.BloomFilter
+	capacity   uint
+	tested     uint    // Number of fingerprints tested
+	duplicates int64   // Number of fingerprints reported as duplicates
+	rate       float64 // Current false-positive rate
+	expected   float64 // Expected number of false positives
+}
+
+func NewBloom(events uint) *Bloom {
+	events = max(events, MinEvents)
+	return &Bloom{
+		filter:   bloom.NewWithEstimates(events, Precision),
+		capacity: events,
+	}
+}
+
+func (b *Bloom) TestAndAdd(fingerprint string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Each unique fingerprint collides with those already added with probability 'rate'.
+	// This grows as the filter fills, and may far exceed 'Precision' if the filter is undersized.
+	// For every fingerprint that is added, roughly rate/(1-rate) others were dropped as false positives.
+	b.tested++
+	if b.tested%b.refreshInterval() == 0 {
+		b.rate = b.falsePositiveRate()
+	}
+	if b.filter.TestAndAddString(fingerprint) {
+		b.duplicates++
+		return true
+	}
+	b.expected += b.rate / max(1-b.rate, Precision)
+	return false
+}
+
+func (b *Bloom) Stats() Stats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return Stats{
+		Mode:                    BloomMode,
+		Capacity:                b.capacity,
+		Duplicates:              b.duplicates,
+		EstimatedFalsePositives: int64(math.Round(b.expected)),
+	}
+}
+
+// Counting the bits set is relatively expensive, so the rate is only refreshed every 1% of the filter's capacity.
+func (b *Bloom) refreshInterval() uint {
+	return max(b.capacity/100, 1)
+}
+
+// Probability that a fingerprint not yet added is reported as a duplicate, based on the fraction of bits set: fill^k
+func (b *Bloom) falsePositiveRate() float64 {
+	fill := float64(b.filter.BitSet().Count()) / float64(b.filter.Cap())
+	return math.Pow(fill, float64(b.filter.K()))
+}
+
+// Exact stores a 64-bit hash of every fingerprint, split across shards to reduce lock contention.
+type Exact struct {
+	shards []exactShard
+}
+
+type exactShard struct {
+	lock         sync.Mutex
+	fingerprints map[uint64]struct{}
+	duplicates   int64
+}
+
+func NewExact() *Exact {
+	shards := make([]exactShard, NumShards)
+	for i := range shards {
+		shards[i] = exactShard{fingerprints: make(map[uint64]struct{})}
+	}
+	return &Exact{shards: shards}
+}
+
+func (e *Exact) TestAndAdd(fingerprint string) bool {
+	hash := fnv.New64a()
+	hash.Write([]byte(fingerprint))
+	sum := hash.Sum64()
+
+	shard := &e.shards[sum%NumShards]
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+	if _, ok := shard.fingerprints[sum]; ok {
+		shard.duplicates++
+		return true
+	}
+	shard.fingerprints[sum] = struct{}{}
+	return false
+}
+
+func (e *Exact) Stats() Stats {
+	var unique, duplicates int64
+	for i := range e.shards {
+		shard := &e.shards[i]
+		shard.lock.Lock()
+		unique += int64(len(shard.fingerprints))
+		duplicates += shard.duplicates
+		shard.lock.Unlock()
+	}
+
+	// Only fingerprints with colliding hashes are incorrectly reported as duplicates.
+	// The expected number of collisions is roughly n^2 / 2^65, which is negligible at any realistic volume.
+	n := float64(unique)
+	return Stats{
+		Mode:                    ExactMode,
+		Duplicates:              duplicates,
+		EstimatedFalsePositives: int64(math.Round(n * n / math.Pow(2, 65))),
+	}
+}
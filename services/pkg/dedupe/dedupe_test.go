@@ -0,0 +1,97 @@
+package dedupe
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	for _, mode := range []string{BloomMode, ExactMode} {
+		filter, err := New(mode, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filter.TestAndAdd("https://example.com0did1") {
+			t.Errorf("%s: expected first fingerprint to be unique", mode)
+		}
+		if filter.TestAndAdd("https://example.com1did1") {
+			t.Errorf("%s: expected fingerprint with different type to be unique", mode)
+		}
+		if !filter.TestAndAdd("https://example.com0did1") {
+			t.Errorf("%s: expected repeated fingerprint to be a duplicate", mode)
+		}
+
+		stats := filter.Stats()
+		if stats.Mode != mode || stats.Duplicates != 1 || stats.EstimatedFalsePositives != 0 {
+			t.Errorf("%s: unexpected stats: %+v", mode, stats)
+		}
+	}
+
+	if _, err := New("bogus", 1000); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+// Test that the exact filter never drops unique fingerprints, including under concurrent use
+func TestExactConcurrency(t *testing.T) {
+	filter := NewExact()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	duplicates := 0
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50000; i++ {
+				// Every worker adds the same fingerprints, so each is unique exactly once
+				if filter.TestAndAdd(fmt.Sprintf("https://example.com/%d", i)) {
+					mu.Lock()
+					duplicates++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if duplicates != 150000 {
+		t.Errorf("expected 150000 duplicates, got %d", duplicates)
+	}
+	if stats := filter.Stats(); stats.Duplicates != 150000 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+// Test that an undersized bloom filter reports false positives, and that the estimate is close to the actual count
+func TestBloomEstimate(t *testing.T) {
+	filter := NewBloom(MinEvents)
+
+	actual := 0
+	for i := 0; i < 10*MinEvents; i++ {
+		if filter.TestAndAdd(fmt.Sprintf("https://example.com/%d", i)) {
+			actual++
+		}
+	}
+
+	stats := filter.Stats()
+	if stats.Capacity != MinEvents || stats.Duplicates != int64(actual) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if actual == 0 {
+		t.Fatal("expected false positives from undersized filter")
+	}
+	estimated := float64(stats.EstimatedFalsePositives)
+	if estimated < float64(actual)*0.8 || estimated > float64(actual)*1.2 {
+		t.Errorf("expected estimate close to %d, got %d", actual, stats.EstimatedFalsePositives)
+	}
+}
+
+// Test that bloom filters are never sized below the minimum
+func TestBloomMinimum(t *testing.T) {
+	if stats := NewBloom(0).Stats(); stats.Capacity != MinEvents {
+		t.Errorf("expected capacity %d, got %d", MinEvents, stats.Capacity)
+	}
+}
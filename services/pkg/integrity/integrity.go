@@ -0,0 +1,347 @@
+package integrity
+
+import (
+	"cmp"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+const (
+	Window         = 24 * time.Hour // Only activity within this window (ending at the time of the check) is analyzed
+	BurstInterval  = time.Minute    // Interactions with the same URL within the same interval form a burst
+	MinBurstSize   = 5              // Minimum number of users in a burst
+	MaxBurstSize   = 50             // Larger bursts are likely organic (i.e. a popular post), and are not compared
+	MinSharedURLs  = 3              // Number of distinct URLs two users must burst together on to be considered synchronized
+	HighRate       = 40             // Interactions per hour, averaged over the window, considered automated
+	HighBreadth    = 300            // Distinct URLs within the window considered automated
+	NewAccountAge  = 7 * 24 * time.Hour
+	MaxAgeLookups  = 1000 // Maximum number of accounts to look up in the PLC directory per check
+	FlagScore      = 0.5  // Accounts with at least this score are down-weighted
+	ExcludeScore   = 0.8  // Accounts with at least this score are excluded
+	DownWeight     = 0.25 // Fraction of a down-weighted account's interactions that are counted
+	ReportURLLimit = 10   // Maximum number of URLs listed for each flagged cluster
+)
+
+// Reasons an account may be flagged. Each adds to the account's score.
+const (
+	RateReason       = "rate"        // Interacts more often than a person plausibly could
+	BreadthReason    = "breadth"     // Interacts with an unusually large number of URLs
+	SynchronyReason  = "synchrony"   // Repeatedly interacts with the same URLs, at the same time, as other accounts
+	NewAccountReason = "new_account" // Created within 'NewAccountAge'
+)
+
+var reasonScores = map[string]float64{
+	RateReason:       0.4,
+	BreadthReason:    0.3,
+	SynchronyReason:  0.5,
+	NewAccountReason: 0.3,
+}
+
+// Detector accumulates the behaviour of each account from partials, in order to flag suspicious accounts.
+// Accounts are identified by the hash of their DID, as stored in partials. Adding partials is thread safe.
+type Detector struct {
+	mu       sync.Mutex
+	start    time.Time
+	end      time.Time
+	accounts map[uint64]*activity
+	bursts   map[burstKey][]uint64 // Users interacting with each URL, within each burst interval
+	urls     map[uint64]string     // URL of each hash, used in the report
+	dids     map[uint64]string     // DID of each user, used to look up account age
+}
+
+type activity struct {
+	interactions int
+	urls         map[uint64]bool
+}
+
+type burstKey struct {
+	url      uint64
+	interval int64
+}
+
+// NewDetector creates a detector for activity within 'Window' of the given time.
+func NewDetector(end time.Time) *Detector {
+	return &Detector{
+		start:    end.Add(-Window),
+		end:      end,
+		accounts: make(map[uint64]*activity),
+		bursts:   make(map[burstKey][]uint64),
+		urls:     make(map[uint64]string),
+		dids:     make(map[uint64]string),
+	}
+}
+
+// Add counts every interaction in the partial within the detector's window.
+func (d *Detector) Add(partial storage.Partial) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, did := range partial.Accounts {
+		d.dids[dedupe.HashDID(did)] = did
+	}
+
+	for _, link := range partial.Links {
+		url := hashURL(link.URL)
+		for i := 0; i < link.Len(); i++ {
+			ts := time.Unix(link.Times[i], 0)
+			if ts.Before(d.start) || ts.After(d.end) {
+				continue
+			}
+			d.urls[url] = link.URL
+
+			user := link.Users[i]
+			account, ok := d.accounts[user]
+			if !ok {
+				account = &activity{urls: make(map[uint64]bool)}
+				d.accounts[user] = account
+			}
+			account.interactions++
+			account.urls[url] = true
+
+			key := burstKey{url: url, interval: ts.Unix() / int64(BurstInterval.Seconds())}
+			d.bursts[key] = append(d.bursts[key], user)
+		}
+	}
+}
+
+// Evaluate scores every account, returning the verdicts used by aggregation, and a report of flagged accounts.
+// Account ages are only looked up for accounts that already behave suspiciously, and only if 'ages' is set.
+func (d *Detector) Evaluate(ages AccountAges) (*Verdicts, Report) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Score each account based on its own behaviour
+	reasons := make(map[uint64][]string)
+	for user, account := range d.accounts {
+		if float64(account.interactions)/Window.Hours() >= HighRate {
+			reasons[user] = append(reasons[user], RateReason)
+		}
+		if len(account.urls) >= HighBreadth {
+			reasons[user] = append(reasons[user], BreadthReason)
+		}
+	}
+
+	// Find accounts that repeatedly burst together, and group them into clusters
+	shared := d.synchronized()
+	clusters := newUnionFind()
+	for pair := range shared {
+		clusters.union(pair[0], pair[1])
+	}
+	for user := range clusters.parents {
+		reasons[user] = append(reasons[user], SynchronyReason)
+	}
+
+	created := d.lookupAges(ages, reasons)
+
+	verdicts := &Verdicts{weights: make(map[uint64]float64)}
+	report := Report{
+		GeneratedAt: d.end.UTC().Format(time.RFC3339),
+		Accounts:    len(d.accounts),
+		Clusters:    make([]Cluster, 0),
+		Flagged:     make([]Account, 0),
+	}
+	flagged := make(map[uint64]Account)
+	for user, rs := range reasons {
+		s := score(rs)
+		if s < FlagScore {
+			continue
+		}
+		account := Account{
+			DID:          d.dids[user],
+			Score:        s,
+			Reasons:      rs,
+			Interactions: d.accounts[user].interactions,
+			URLs:         len(d.accounts[user].urls),
+			Excluded:     s >= ExcludeScore,
+		}
+		if createdAt, ok := created[user]; ok {
+			account.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		}
+		flagged[user] = account
+
+		if account.Excluded {
+			verdicts.weights[user] = 0
+			report.Excluded++
+		} else {
+			verdicts.weights[user] = DownWeight
+			report.DownWeighted++
+		}
+	}
+
+	// Flagged accounts that burst together are reported as a cluster, along with the URLs they amplified.
+	// Other flagged accounts are reported individually.
+	groups := make(map[uint64][]uint64)
+	for user, account := range flagged {
+		if _, ok := clusters.parents[user]; !ok {
+			report.Flagged = append(report.Flagged, account)
+			continue
+		}
+		root := clusters.find(user)
+		groups[root] = append(groups[root], user)
+	}
+	for _, members := range groups {
+		report.Clusters = append(report.Clusters, d.cluster(members, flagged, shared))
+	}
+	report.sort()
+
+	return verdicts, report
+}
+
+// Find pairs of users that burst together on at least 'MinSharedURLs' distinct URLs, and the URLs they shared.
+func (d *Detector) synchronized() map[[2]uint64][]uint64 {
+	pairs := make(map[[2]uint64]map[uint64]bool)
+	for key, users := range d.bursts {
+		users = slices.Compact(slices.Sorted(slices.Values(users)))
+		if len(users) < MinBurstSize || len(users) > MaxBurstSize {
+			continue
+		}
+		for i := range users {
+			for j := i + 1; j < len(users); j++ {
+				pair := [2]uint64{users[i], users[j]}
+				if pairs[pair] == nil {
+					pairs[pair] = make(map[uint64]bool)
+				}
+				pairs[pair][key.url] = true
+			}
+		}
+	}
+
+	result := make(map[[2]uint64][]uint64)
+	for pair, urls := range pairs {
+		if len(urls) >= MinSharedURLs {
+			for url := range urls {
+				result[pair] = append(result[pair], url)
+			}
+		}
+	}
+	return result
+}
+
+// Look up the creation time of suspicious accounts, starting with the most suspicious.
+// Accounts created recently are flagged as new. Only 'did:plc' accounts are listed in the PLC directory.
+func (d *Detector) lookupAges(ages AccountAges, reasons map[uint64][]string) map[uint64]time.Time {
+	created := make(map[uint64]time.Time)
+	if ages == nil {
+		return created
+	}
+
+	candidates := make([]uint64, 0, len(reasons))
+	for user := range reasons {
+		if strings.HasPrefix(d.dids[user], "did:plc:") {
+			candidates = append(candidates, user)
+		}
+	}
+	slices.SortFunc(candidates, func(a, b uint64) int {
+		if c := cmp.Compare(score(reasons[b]), score(reasons[a])); c != 0 {
+			return c
+		}
+		return strings.Compare(d.dids[a], d.dids[b])
+	})
+
+	for _, user := range candidates[:min(len(candidates), MaxAgeLookups)] {
+		createdAt, err := ages.CreatedAt(d.dids[user])
+		if err != nil {
+			slog.Warn(util.WrapErr("failed to look up account age", err).Error(), "did", d.dids[user])
+			continue
+		}
+		if createdAt.IsZero() {
+			continue
+		}
+		created[user] = createdAt
+		if d.end.Sub(createdAt) < NewAccountAge {
+			reasons[user] = append(reasons[user], NewAccountReason)
+		}
+	}
+	return created
+}
+
+// Build the report for a cluster, listing the URLs its members burst together on most often.
+func (d *Detector) cluster(members []uint64, flagged map[uint64]Account, shared map[[2]uint64][]uint64) Cluster {
+	inCluster := make(map[uint64]bool, len(members))
+	for _, user := range members {
+		inCluster[user] = true
+	}
+
+	counts := make(map[uint64]int)
+	for pair, urls := range shared {
+		if !inCluster[pair[0]] || !inCluster[pair[1]] {
+			continue
+		}
+		for _, url := range urls {
+			counts[url]++
+		}
+	}
+
+	hashes := make([]uint64, 0, len(counts))
+	for url := range counts {
+		hashes = append(hashes, url)
+	}
+	slices.SortFunc(hashes, func(a, b uint64) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(d.urls[a], d.urls[b])
+	})
+
+	cluster := Cluster{Accounts: make([]Account, 0, len(members)), URLs: make([]string, 0, ReportURLLimit)}
+	for _, user := range members {
+		cluster.Accounts = append(cluster.Accounts, flagged[user])
+	}
+	for _, url := range hashes[:min(len(hashes), ReportURLLimit)] {
+		cluster.URLs = append(cluster.URLs, d.urls[url])
+	}
+	return cluster
+}
+
+// Return the total score of the reasons an account was flagged, up to 1.
+func score(reasons []string) float64 {
+	total := 0.0
+	for _, reason := range reasons {
+		total += reasonScores[reason]
+	}
+	return min(total, 1)
+}
+
+func hashURL(url string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(url))
+	return hash.Sum64()
+}
+
+// unionFind groups users connected by synchronized bursts.
+type unionFind struct {
+	parents map[uint64]uint64
+}
+
+func newUnionFind() *unionFind {
+	return &unionFind{parents: make(map[uint64]uint64)}
+}
+
+func (u *unionFind) find(user uint64) uint64 {
+	parent, ok := u.parents[user]
+	if !ok {
+		u.parents[user] = user
+		return user
+	}
+	if parent == user {
+		return user
+	}
+	root := u.find(parent)
+	u.parents[user] = root
+	return root
+}
+
+func (u *unionFind) union(a, b uint64) {
+	rootA, rootB := u.find(a), u.find(b)
+	if rootA != rootB {
+		u.parents[rootB] = rootA
+	}
+}
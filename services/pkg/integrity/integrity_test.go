@@ -0,0 +1,138 @@
+package integrity
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+type fakeAges map[string]time.Time
+
+func (f fakeAges) CreatedAt(did string) (time.Time, error) {
+	return f[did], nil
+}
+
+// Build a partial where each DID interacts with the given URLs at the given times
+type interaction struct {
+	did string
+	url string
+	ts  time.Time
+}
+
+func testPartial(interactions []interaction) storage.Partial {
+	partial := storage.Partial{Chunk: "test"}
+	indexes := make(map[string]int)
+	for _, in := range interactions {
+		i, ok := indexes[in.url]
+		if !ok {
+			i = len(partial.Links)
+			indexes[in.url] = i
+			partial.Links = append(partial.Links, storage.PartialLink{URL: in.url, Posts: []string{"at://post"}})
+		}
+		link := &partial.Links[i]
+		link.Types = append(link.Types, 2)
+		link.Users = append(link.Users, dedupe.HashDID(in.did))
+		link.Times = append(link.Times, in.ts.Unix())
+		link.PostRefs = append(link.PostRefs, 0)
+		if !slices.Contains(partial.Accounts, in.did) {
+			partial.Accounts = append(partial.Accounts, in.did)
+		}
+	}
+	return partial
+}
+
+// Test that accounts are flagged by rate, breadth, synchrony, and age, and that organic accounts are not
+func TestEvaluate(t *testing.T) {
+	end := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	interactions := make([]interaction, 0)
+
+	// A fast, broad account, liking a new URL every minute
+	for i := 0; i < 1200; i++ {
+		interactions = append(interactions, interaction{"did:plc:fast", fmt.Sprintf("https://example.com/%d", i), end.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	// A network of six accounts that like the same three URLs within the same minute.
+	// Half of the network was created recently.
+	for i := 0; i < 3; i++ {
+		ts := end.Add(-time.Duration(i+1) * time.Hour)
+		for j := 0; j < 6; j++ {
+			interactions = append(interactions, interaction{fmt.Sprintf("did:plc:bot%d", j), fmt.Sprintf("https://spam.com/%d", i), ts.Add(time.Duration(j) * time.Second)})
+		}
+	}
+
+	// Organic accounts liking the same URL, spread over the day
+	for j := 0; j < 6; j++ {
+		interactions = append(interactions, interaction{fmt.Sprintf("did:plc:person%d", j), "https://news.com/story", end.Add(-time.Duration(j) * time.Hour)})
+	}
+
+	// Activity outside the window is ignored
+	interactions = append(interactions, interaction{"did:plc:old", "https://example.com/old", end.Add(-2 * Window)})
+
+	ages := fakeAges{
+		"did:plc:bot0": end.Add(-24 * time.Hour),
+		"did:plc:bot1": end.Add(-48 * time.Hour),
+		"did:plc:bot2": end.Add(-72 * time.Hour),
+		"did:plc:bot3": end.AddDate(-1, 0, 0),
+	}
+
+	detector := NewDetector(end)
+	detector.Add(testPartial(interactions))
+	verdicts, report := detector.Evaluate(ages)
+
+	if report.Accounts != 13 {
+		t.Errorf("expected 13 accounts, got %d", report.Accounts)
+	}
+	if verdicts.Weight(dedupe.HashDID("did:plc:fast")) != DownWeight {
+		t.Errorf("expected fast account to be down-weighted")
+	}
+	if verdicts.Weight(dedupe.HashDID("did:plc:bot0")) != 0 || verdicts.Weight(dedupe.HashDID("did:plc:bot5")) != DownWeight {
+		t.Errorf("expected new bots to be excluded, and old bots to be down-weighted")
+	}
+	if verdicts.Weight(dedupe.HashDID("did:plc:person0")) != 1 {
+		t.Errorf("expected organic account to be counted")
+	}
+	if report.Excluded != 3 || report.DownWeighted != 4 {
+		t.Errorf("unexpected counts: %d excluded, %d down-weighted", report.Excluded, report.DownWeighted)
+	}
+
+	if len(report.Clusters) != 1 || len(report.Clusters[0].Accounts) != 6 || len(report.Clusters[0].URLs) != 3 {
+		t.Fatalf("unexpected clusters: %v", report.Clusters)
+	}
+	if first := report.Clusters[0].Accounts[0]; first.DID != "did:plc:bot0" || !first.Excluded || first.CreatedAt == "" {
+		t.Errorf("unexpected first account in cluster: %v", first)
+	}
+	if len(report.Flagged) != 1 || report.Flagged[0].DID != "did:plc:fast" || !slices.Equal(report.Flagged[0].Reasons, []string{RateReason, BreadthReason}) {
+		t.Errorf("unexpected flagged accounts: %v", report.Flagged)
+	}
+}
+
+// Test that down-weighted accounts have roughly the expected fraction of interactions counted, consistently
+func TestVerdictsCounted(t *testing.T) {
+	verdicts := &Verdicts{weights: map[uint64]float64{1: DownWeight, 2: 0}}
+
+	counted := 0
+	for i := 0; i < 1000; i++ {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		if verdicts.Counted(1, url) {
+			counted++
+		}
+		if verdicts.Counted(1, url) != verdicts.Counted(1, url) {
+			t.Fatal("expected consistent result")
+		}
+		if verdicts.Counted(2, url) || !verdicts.Counted(3, url) {
+			t.Fatal("unexpected result for excluded or unflagged account")
+		}
+	}
+	if counted < 200 || counted > 300 {
+		t.Errorf("expected about 250 interactions counted, got %d", counted)
+	}
+
+	var none *Verdicts
+	if !none.Counted(1, "https://example.com") {
+		t.Error("expected nil verdicts to count everything")
+	}
+}
@@ -0,0 +1,59 @@
+package integrity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// AccountAges looks up when accounts were created.
+type AccountAges interface {
+	CreatedAt(did string) (time.Time, error)
+}
+
+// PLC looks up the creation time of 'did:plc' accounts from the PLC directory, using the time of each account's first operation.
+type PLC struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewPLC(cfg config.Config) PLC {
+	return PLC{endpoint: cfg.PLCDirectoryEndpoint, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+type plcOperation struct {
+	CreatedAt string `json:"createdAt"`
+}
+
+// CreatedAt returns the time the account was created. If the account is not found, return a zero time.
+func (p PLC) CreatedAt(did string) (time.Time, error) {
+	resp, err := p.client.Get(fmt.Sprintf("%s/%s/log/audit", p.endpoint, did))
+	if err != nil {
+		return time.Time{}, util.WrapErr("failed to get plc audit log", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return time.Time{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("failed to get plc audit log: status code %s", resp.Status)
+	}
+
+	var operations []plcOperation
+	if err := json.NewDecoder(resp.Body).Decode(&operations); err != nil {
+		return time.Time{}, util.WrapErr("failed to decode plc audit log", err)
+	}
+	if len(operations) == 0 {
+		return time.Time{}, nil
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, operations[0].CreatedAt)
+	if err != nil {
+		return time.Time{}, util.WrapErr("failed to parse plc operation time", err)
+	}
+	return createdAt, nil
+}
@@ -0,0 +1,54 @@
+package integrity
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Report lists the accounts flagged by an integrity check, so they can be reviewed.
+type Report struct {
+	GeneratedAt  string    `json:"generated_at"`
+	Accounts     int       `json:"accounts"`      // Number of accounts analyzed
+	DownWeighted int       `json:"down_weighted"` // Number of accounts with only part of their interactions counted
+	Excluded     int       `json:"excluded"`      // Number of accounts with none of their interactions counted
+	Clusters     []Cluster `json:"clusters"`      // Groups of flagged accounts that interact with the same URLs at the same time
+	Flagged      []Account `json:"flagged"`       // Flagged accounts that are not part of a cluster
+}
+
+// Cluster is a group of flagged accounts that repeatedly burst together.
+type Cluster struct {
+	Accounts []Account `json:"accounts"`
+	URLs     []string  `json:"urls"` // URLs the accounts burst together on most often
+}
+
+type Account struct {
+	DID          string   `json:"did"`
+	Score        float64  `json:"score"`
+	Reasons      []string `json:"reasons"`
+	Interactions int      `json:"interactions"` // Number of interactions within the window
+	URLs         int      `json:"urls"`         // Number of distinct URLs within the window
+	CreatedAt    string   `json:"created_at"`   // Empty if the account's age was not looked up
+	Excluded     bool     `json:"excluded"`
+}
+
+// Sort clusters by size, and accounts by score, so the most significant are listed first.
+func (r *Report) sort() {
+	for i := range r.Clusters {
+		slices.SortFunc(r.Clusters[i].Accounts, compareAccounts)
+	}
+	slices.SortFunc(r.Clusters, func(a, b Cluster) int {
+		if c := cmp.Compare(len(b.Accounts), len(a.Accounts)); c != 0 {
+			return c
+		}
+		return compareAccounts(a.Accounts[0], b.Accounts[0])
+	})
+	slices.SortFunc(r.Flagged, compareAccounts)
+}
+
+func compareAccounts(a, b Account) int {
+	if c := cmp.Compare(b.Score, a.Score); c != 0 {
+		return c
+	}
+	return strings.Compare(a.DID, b.DID)
+}
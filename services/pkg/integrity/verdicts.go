@@ -0,0 +1,57 @@
+package integrity
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// Verdicts decide how much each account's interactions count towards aggregation.
+// Accounts that were not flagged are counted in full. A nil set of verdicts counts everything.
+type Verdicts struct {
+	weights map[uint64]float64 // Hash of each flagged account's DID -> fraction of its interactions counted
+}
+
+// Weight returns the fraction of the account's interactions that are counted.
+func (v *Verdicts) Weight(user uint64) float64 {
+	if v == nil {
+		return 1
+	}
+	weight, ok := v.weights[user]
+	if !ok {
+		return 1
+	}
+	return weight
+}
+
+// Counted returns whether the account's interaction with the URL should be counted.
+// Down-weighted accounts have a fixed sample of their interactions counted, chosen by hashing the account and URL,
+// so the same interactions are counted every time, and counts remain whole numbers.
+func (v *Verdicts) Counted(user uint64, url string) bool {
+	weight := v.Weight(user)
+	if weight >= 1 {
+		return true
+	}
+	if weight <= 0 {
+		return false
+	}
+
+	hash := fnv.New64a()
+	hash.Write(binary.BigEndian.AppendUint64(nil, user))
+	hash.Write([]byte(url))
+
+	// FNV is poorly distributed for similar inputs, so mix the hash before using it as a fraction
+	h := hash.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return float64(h)/math.MaxUint64 < weight
+}
+
+// Len returns the number of flagged accounts.
+func (v *Verdicts) Len() int {
+	if v == nil {
+		return 0
+	}
+	return len(v.weights)
+}
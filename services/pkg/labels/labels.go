@@ -0,0 +1,72 @@
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Actions taken on posts with a label
+const (
+	ExcludeAction = "exclude" // The post, and likes & reposts of it, are not counted, and the post is not recommended
+	HideAction    = "hide"    // The post is counted, but not recommended
+)
+
+// DefaultPolicy excludes adult content, graphic content, and spam, which are labelled by Bluesky's moderation service (or self-labelled by the author).
+// Posts Bluesky hides or warns about for other reasons are not recommended.
+const DefaultPolicy = "porn=exclude,sexual=exclude,nudity=exclude,graphic-media=exclude,gore=exclude,spam=exclude,!hide=exclude,!warn=hide"
+
+// Policy maps label values (i.e. 'porn') to the action taken on posts with that label.
+// Labels not in the policy have no effect. A nil policy counts and recommends every post.
+type Policy map[string]string
+
+// ParsePolicy parses a comma-separated list of labels and actions, i.e. 'porn=exclude,!warn=hide'.
+func ParsePolicy(value string) (Policy, error) {
+	policy := make(Policy)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		label, action, ok := strings.Cut(pair, "=")
+		label, action = strings.TrimSpace(label), strings.TrimSpace(action)
+		if !ok || label == "" {
+			return nil, fmt.Errorf("invalid label policy: %s", pair)
+		}
+		if action != ExcludeAction && action != HideAction {
+			return nil, fmt.Errorf("unknown action for label %s: %s", label, action)
+		}
+		policy[label] = action
+	}
+	return policy, nil
+}
+
+// Counted returns whether interactions with a post carrying the given labels are counted.
+func (p Policy) Counted(labels []string) bool {
+	for _, label := range labels {
+		if p[label] == ExcludeAction {
+			return false
+		}
+	}
+	return true
+}
+
+// Recommended returns whether a post carrying the given labels may be recommended.
+func (p Policy) Recommended(labels []string) bool {
+	for _, label := range labels {
+		if _, ok := p[label]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge combines sets of labels, removing duplicates. The result is sorted, so equal sets can be compared.
+func Merge(sets ...[]string) []string {
+	merged := make([]string, 0)
+	for _, set := range sets {
+		merged = append(merged, set...)
+	}
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
@@ -0,0 +1,49 @@
+package labels
+
+import "testing"
+
+// Test that the default policy parses, and applies each action
+func TestPolicy(t *testing.T) {
+	policy, err := ParsePolicy(DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		labels      []string
+		counted     bool
+		recommended bool
+	}{
+		{nil, true, true},
+		{[]string{"!no-unauthenticated"}, true, true},
+		{[]string{"porn"}, false, false},
+		{[]string{"!warn"}, true, false},
+		{[]string{"!warn", "spam"}, false, false},
+	}
+	for _, test := range tests {
+		if policy.Counted(test.labels) != test.counted || policy.Recommended(test.labels) != test.recommended {
+			t.Errorf("unexpected result for %v", test.labels)
+		}
+	}
+
+	// A nil policy allows everything
+	var empty Policy
+	if !empty.Counted([]string{"porn"}) || !empty.Recommended([]string{"porn"}) {
+		t.Error("expected empty policy to allow all labels")
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, value := range []string{"porn", "=exclude", "porn=delete"} {
+		if _, err := ParsePolicy(value); err == nil {
+			t.Errorf("expected error for '%s'", value)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	merged := Merge([]string{"spam", "porn"}, nil, []string{"porn"})
+	if len(merged) != 2 || merged[0] != "porn" || merged[1] != "spam" {
+		t.Errorf("unexpected labels: %v", merged)
+	}
+}
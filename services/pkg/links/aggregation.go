@@ -1,27 +1,25 @@
 package links
 
 import (
-	"fmt"
 	"hash/fnv"
 	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/georgemblack/blue-report/pkg/dedupe"
 )
 
-const EstimatedTotalEvents = 25000000 // 25 million. Estimate is used to create bloom filter used for duplicate detection.
-const DuplicatePrecision = 0.001      // 0.1% precision for duplicate detection
-const NumShards = 1024                // Number of shards to use for parallel processing
+const NumShards = 1024 // Number of shards to use for parallel processing
 
 type Aggregation struct {
-	shards           []Shard
-	fingerprints     *bloom.BloomFilter
-	fingerprintsLock sync.Mutex
-	bounds           TimeBounds
-	total            int64 // Number of events processed
-	skipped          int64 // Number of events skipped due to suspected duplicate
+	shards       []Shard
+	fingerprints dedupe.Filter // Detects duplicate url/event/did combinations
+	bounds       TimeBounds
+	scorer       Scorer
+	total        int64 // Number of events processed
+	skipped      int64 // Number of events skipped due to suspected duplicate
 }
 
 type Shard struct {
@@ -29,13 +27,7 @@ type Shard struct {
 	items map[string]*AggregationItem
 }
 
-type TimeBounds struct {
-	HourStart time.Time // Start of the 'past hour' report
-	DayStart  time.Time // Start of the 'past day' report
-	WeekStart time.Time // Start of the 'past week' report
-}
-
-func NewAggregation(bounds TimeBounds) Aggregation {
+func NewAggregation(bounds TimeBounds, scorer Scorer, fingerprints dedupe.Filter) Aggregation {
 	shards := make([]Shard, NumShards)
 	for i := range shards {
 		shards[i] = Shard{
@@ -45,12 +37,12 @@ func NewAggregation(bounds TimeBounds) Aggregation {
 	}
 
 	return Aggregation{
-		shards:           shards,
-		fingerprints:     bloom.NewWithEstimates(EstimatedTotalEvents, DuplicatePrecision),
-		fingerprintsLock: sync.Mutex{},
-		bounds:           bounds,
-		total:            0,
-		skipped:          0,
+		shards:       shards,
+		fingerprints: fingerprints,
+		bounds:       bounds,
+		scorer:       scorer,
+		total:        0,
+		skipped:      0,
 	}
 }
 
@@ -72,22 +64,22 @@ func (a *Aggregation) Skipped() int64 {
 }
 
 func (a *Aggregation) CountEvent(eventType int, linkURL string, post string, did string, ts time.Time) {
+	a.CountInteraction(eventType, linkURL, post, dedupe.HashDID(did), ts)
+}
+
+// CountInteraction counts an event where the user's DID has already been hashed (i.e. when merging partials).
+func (a *Aggregation) CountInteraction(eventType int, linkURL string, post string, user uint64, ts time.Time) {
 	// Skip event if it is not within a time boundary for any report
-	if ts.Before(a.bounds.WeekStart) {
+	if !ts.After(a.bounds.Earliest()) {
 		return
 	}
 
 	// Check for a duplicate url/event/did combination to prevent spam.
 	// i.e. at most, a single user can like, post, and repost a link once.
-	// Ensure only one worker is able to update 'fingerprints' at a given moment.
-	a.fingerprintsLock.Lock()
-	fingerprint := fmt.Sprintf("%s%d%s", linkURL, eventType, did)
-	if a.fingerprints.TestAndAddString(fingerprint) {
-		a.skipped++
-		a.fingerprintsLock.Unlock()
+	if a.fingerprints.TestAndAdd(dedupe.Fingerprint(linkURL, eventType, user)) {
+		atomic.AddInt64(&a.skipped, 1)
 		return
 	}
-	a.fingerprintsLock.Unlock()
 
 	// Find the shard associated with the given URL
 	shard := a.getShard(linkURL)
@@ -102,88 +94,97 @@ func (a *Aggregation) CountEvent(eventType int, linkURL string, post string, did
 	atomic.AddInt64(&a.total, 1)
 }
 
-func (a *Aggregation) TopHourLinks(n int) []string {
-	kvs := a.toKV()
+// CountBucket counts interactions with a URL that have already been deduplicated (i.e. by a live aggregation).
+// All interactions are counted as if they occurred at 'ts', the start of the bucket.
+func (a *Aggregation) CountBucket(linkURL string, counts Counts, posts map[string]int, firstSeen time.Time, ts time.Time) {
+	if !ts.After(a.bounds.Earliest()) {
+		return
+	}
 
-	// Sort by score
-	slices.SortFunc(kvs, func(a, b kv) int {
-		scoreA := a.AggregationItem.HourScore()
-		scoreB := b.AggregationItem.HourScore()
+	shard := a.getShard(linkURL)
 
-		if scoreA > scoreB {
-			return -1
-		}
-		if scoreA < scoreB {
-			return 1
-		}
-		return 0
-	})
+	shard.lock.Lock()
+	if shard.items[linkURL] == nil {
+		shard.items[linkURL] = &AggregationItem{}
+	}
+	shard.items[linkURL].CountBucket(counts, posts, firstSeen, ts, a.bounds)
+	shard.lock.Unlock()
 
-	// Find top N items
-	urls := make([]string, 0, n)
-	for i := range kvs {
-		if len(urls) >= n {
-			break
+	atomic.AddInt64(&a.total, int64(counts.Posts+counts.Reposts+counts.Likes))
+}
+
+// Exclude removes interactions with the given posts from every item, so items are scored without them.
+// Returns the number of interactions removed. See 'AggregationItem.Exclude'.
+func (a *Aggregation) Exclude(posts map[string]bool) int {
+	removed := 0
+	for i := range a.shards {
+		shard := &a.shards[i]
+		shard.lock.Lock()
+		for _, item := range shard.items {
+			removed += item.Exclude(posts)
 		}
-		urls = append(urls, kvs[i].URL)
+		shard.lock.Unlock()
 	}
+	return removed
+}
 
-	return urls
+// Deduplication describes the accuracy of duplicate detection, including the estimated number of events incorrectly skipped.
+func (a *Aggregation) Deduplication() dedupe.Stats {
+	return a.fingerprints.Stats()
 }
 
-func (a *Aggregation) TopDayLinks(n int) []string {
-	kvs := a.toKV()
+// Scoring returns the model used to rank links.
+func (a *Aggregation) Scoring() ScoringModel {
+	return a.scorer.Model()
+}
 
-	// Sort by score
-	slices.SortFunc(kvs, func(a, b kv) int {
-		scoreA := a.AggregationItem.DayScore()
-		scoreB := b.AggregationItem.DayScore()
+// Bounds returns the windows counted by the aggregation.
+func (a *Aggregation) Bounds() TimeBounds {
+	return a.bounds
+}
 
-		if scoreA > scoreB {
-			return -1
-		}
-		if scoreA < scoreB {
-			return 1
-		}
-		return 0
+// TopLinks returns the top N URLs within the named window.
+func (a *Aggregation) TopLinks(window string, n int) []string {
+	i := a.bounds.Index(window)
+	if i < 0 {
+		return []string{}
+	}
+	return a.topLinks(n, func(item *AggregationItem) float64 {
+		return item.Score(a.scorer, a.bounds, i)
 	})
+}
 
-	// Find top N items
-	urls := make([]string, 0, n)
-	for i := range kvs {
-		if len(urls) >= n {
-			break
-		}
-		urls = append(urls, kvs[i].URL)
+// Find the top N URLs based on the given score. Ties are broken by URL, so results are stable.
+func (a *Aggregation) topLinks(n int, score func(item *AggregationItem) float64) []string {
+	type scored struct {
+		URL   string
+		Score float64
 	}
 
-	return urls
-}
-
-func (a *Aggregation) TopWeekLinks(n int) []string {
 	kvs := a.toKV()
+	scores := make([]scored, 0, len(kvs))
+	for _, kv := range kvs {
+		scores = append(scores, scored{URL: kv.URL, Score: score(kv.AggregationItem)})
+	}
 
 	// Sort by score
-	slices.SortFunc(kvs, func(a, b kv) int {
-		scoreA := a.AggregationItem.WeekScore()
-		scoreB := b.AggregationItem.WeekScore()
-
-		if scoreA > scoreB {
+	slices.SortFunc(scores, func(a, b scored) int {
+		if a.Score > b.Score {
 			return -1
 		}
-		if scoreA < scoreB {
+		if a.Score < b.Score {
 			return 1
 		}
-		return 0
+		return strings.Compare(a.URL, b.URL)
 	})
 
 	// Find top N items
 	urls := make([]string, 0, n)
-	for i := range kvs {
+	for i := range scores {
 		if len(urls) >= n {
 			break
 		}
-		urls = append(urls, kvs[i].URL)
+		urls = append(urls, scores[i].URL)
 	}
 
 	return urls
@@ -3,22 +3,21 @@ package links
 import (
 	"testing"
 	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
 )
 
 func TestAggregationBasics(t *testing.T) {
 	now := time.Now().UTC()
-	bounds := TimeBounds{
-		DayStart:  now.Add(-24 * time.Hour),
-		WeekStart: now.Add(-24 * 7 * time.Hour),
-	}
-	aggregation := NewAggregation(bounds)
+	bounds := NewTimeBounds(now, testWindows())
+	aggregation := NewAggregation(bounds, DefaultLinearScorer(), dedupe.NewExact())
 
 	ts := now.Add(-1 * time.Minute)
 	aggregation.CountEvent(0, "https://www.example.com/some-page", "abc", "did1", ts)
 	item := aggregation.Get("https://www.example.com/some-page")
 
-	if item.DayCount.Posts != 1 {
-		t.Errorf("expected 1 post, got %d", item.DayCount.Posts)
+	if item.Count(bounds.Index("24h")).Posts != 1 {
+		t.Errorf("expected 1 post, got %d", item.Count(bounds.Index("24h")).Posts)
 	}
 	if aggregation.Total() != 1 {
 		t.Errorf("expected 1 total, got %d", aggregation.Total())
@@ -27,7 +26,7 @@ func TestAggregationBasics(t *testing.T) {
 		t.Errorf("expected 0 skipped, got %d", aggregation.Skipped())
 	}
 
-	top := aggregation.TopDayLinks(1)
+	top := aggregation.TopLinks("24h", 1)
 	if len(top) != 1 {
 		t.Errorf("expected 1 top link, got %d", len(top))
 	}
@@ -38,11 +37,8 @@ func TestAggregationBasics(t *testing.T) {
 
 func TestAggregationDuplicateHandling(t *testing.T) {
 	now := time.Now().UTC()
-	bounds := TimeBounds{
-		DayStart:  now.Add(-24 * time.Hour),
-		WeekStart: now.Add(-24 * 7 * time.Hour),
-	}
-	aggregation := NewAggregation(bounds)
+	bounds := NewTimeBounds(now, testWindows())
+	aggregation := NewAggregation(bounds, DefaultLinearScorer(), dedupe.NewExact())
 
 	ts := now.Add(-1 * time.Minute)
 	aggregation.CountEvent(0, "https://www.example.com/some-page", "abc", "did1", ts)
@@ -57,7 +53,7 @@ func TestAggregationDuplicateHandling(t *testing.T) {
 		t.Errorf("expected 1 skipped, got %d", aggregation.Skipped())
 	}
 
-	top := aggregation.TopDayLinks(2)
+	top := aggregation.TopLinks("24h", 2)
 	if len(top) != 2 {
 		t.Errorf("expected 2 top links, got %d", len(top))
 	}
@@ -71,11 +67,8 @@ func TestAggregationDuplicateHandling(t *testing.T) {
 
 func TestAggregationTimeHandling(t *testing.T) {
 	now := time.Now().UTC()
-	bounds := TimeBounds{
-		DayStart:  now.Add(-24 * time.Hour),
-		WeekStart: now.Add(-24 * 7 * time.Hour),
-	}
-	aggregation := NewAggregation(bounds)
+	bounds := NewTimeBounds(now, testWindows())
+	aggregation := NewAggregation(bounds, DefaultLinearScorer(), dedupe.NewExact())
 
 	inDayBounds := now.Add(-100 * time.Minute)
 	inWeekBounds := now.Add(-24 * 2 * time.Hour)
@@ -96,7 +89,7 @@ func TestAggregationTimeHandling(t *testing.T) {
 		t.Errorf("expected 0 skipped, got %d", aggregation.Skipped())
 	}
 
-	top := aggregation.TopDayLinks(1)
+	top := aggregation.TopLinks("24h", 1)
 	if len(top) != 1 {
 		t.Errorf("expected 1 top links, got %d", len(top))
 	}
@@ -104,7 +97,7 @@ func TestAggregationTimeHandling(t *testing.T) {
 		t.Errorf("expected top link to be https://www.example.com/some-page, got %s", top[0])
 	}
 
-	top = aggregation.TopWeekLinks(2)
+	top = aggregation.TopLinks("7d", 2)
 	if len(top) != 2 {
 		t.Errorf("expected 2 top links, got %d", len(top))
 	}
@@ -0,0 +1,161 @@
+package links
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	MinHashSize         = 64  // Number of hashes in each title's signature
+	TitleSimilarity     = 0.4 // Estimated similarity of two titles' words for the links to cover the same story
+	CoSharingSimilarity = 0.3 // Overlap between the authors sharing two links for the links to cover the same story
+	MinCoSharingAuthors = 5   // Links shared by fewer authors are not clustered by co-sharing, as any overlap is likely chance
+)
+
+// Common words are ignored when comparing titles, as they say little about the story.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true, "for": true,
+	"from": true, "has": true, "have": true, "he": true, "her": true, "his": true, "in": true, "is": true, "it": true,
+	"its": true, "of": true, "on": true, "or": true, "over": true, "says": true, "she": true, "that": true, "the": true,
+	"their": true, "they": true, "this": true, "to": true, "was": true, "were": true, "what": true, "who": true,
+	"will": true, "with": true, "after": true, "new": true, "how": true, "why": true, "s": true,
+}
+
+// Coverage is another link covering the same story as a listed link.
+type Coverage struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// Story is a candidate for clustering: a link's title, and the authors of posts referencing it.
+type Story struct {
+	URL     string
+	Title   string
+	Authors map[string]bool
+}
+
+// Cluster is a group of links covering the same story. The primary link is the highest ranked.
+type Cluster struct {
+	Primary Story
+	Others  []Story
+}
+
+// ClusterStories groups stories covering the same news event, preserving the order of the given stories.
+// Two stories are the same if their titles are similar, or if many of the same authors shared both.
+// Each story joins the first cluster containing a similar story, otherwise it starts a new cluster.
+func ClusterStories(stories []Story) []Cluster {
+	type candidate struct {
+		story     Story
+		signature Signature
+	}
+
+	clusters := make([]Cluster, 0, len(stories))
+	members := make([][]candidate, 0, len(stories))
+	for _, story := range stories {
+		c := candidate{story: story, signature: NewSignature(story.Title)}
+
+		joined := false
+		for i := range clusters {
+			for _, member := range members[i] {
+				if c.signature.Similarity(member.signature) >= TitleSimilarity || coSharing(story.Authors, member.story.Authors) >= CoSharingSimilarity {
+					clusters[i].Others = append(clusters[i].Others, story)
+					members[i] = append(members[i], c)
+					joined = true
+					break
+				}
+			}
+			if joined {
+				break
+			}
+		}
+
+		if !joined {
+			clusters = append(clusters, Cluster{Primary: story})
+			members = append(members, []candidate{c})
+		}
+	}
+
+	return clusters
+}
+
+// Return the Jaccard similarity of the authors sharing two links.
+func coSharing(a, b map[string]bool) float64 {
+	if len(a) < MinCoSharingAuthors || len(b) < MinCoSharingAuthors {
+		return 0
+	}
+	shared := 0
+	for author := range a {
+		if b[author] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Signature is a MinHash signature of a title's words, used to estimate the similarity of two titles.
+type Signature []uint64
+
+// NewSignature creates the signature of a title. Titles without any meaningful words have an empty signature.
+func NewSignature(title string) Signature {
+	shingles := titleShingles(title)
+	if len(shingles) == 0 {
+		return nil
+	}
+
+	signature := make(Signature, MinHashSize)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for _, shingle := range shingles {
+		hash := fnv.New64a()
+		hash.Write([]byte(shingle))
+		base := hash.Sum64()
+		for i := range signature {
+			signature[i] = min(signature[i], mix(base, uint64(i)))
+		}
+	}
+	return signature
+}
+
+// Similarity estimates the Jaccard similarity of the titles' words, as the fraction of matching hashes.
+// Empty signatures are not similar to anything.
+func (s Signature) Similarity(other Signature) float64 {
+	if len(s) == 0 || len(s) != len(other) {
+		return 0
+	}
+	matches := 0
+	for i := range s {
+		if s[i] == other[i] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(s))
+}
+
+// Return the distinct words in a title, lowercased and without punctuation or common words.
+func titleShingles(title string) []string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := make(map[string]bool)
+	shingles := make([]string, 0, len(words))
+	for _, word := range words {
+		if stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		shingles = append(shingles, word)
+	}
+	return shingles
+}
+
+// Derive the hash for the given seed, using the finalizer from SplitMix64.
+func mix(hash uint64, seed uint64) uint64 {
+	z := hash ^ (seed+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,70 @@
+package links
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test that similar titles have similar signatures, ignoring case, punctuation, and common words
+func TestSignatureSimilarity(t *testing.T) {
+	a := NewSignature("Supreme Court strikes down Trump's tariffs")
+	b := NewSignature("The Supreme Court Strikes Down Trump Tariffs!")
+	c := NewSignature("Scientists discover a new species of frog in the Amazon")
+
+	if similarity := a.Similarity(b); similarity < 0.7 {
+		t.Errorf("expected similar titles, got %f", similarity)
+	}
+	if similarity := a.Similarity(c); similarity > 0.2 {
+		t.Errorf("expected different titles, got %f", similarity)
+	}
+	if similarity := a.Similarity(NewSignature("The")); similarity != 0 {
+		t.Errorf("expected empty signature to match nothing, got %f", similarity)
+	}
+}
+
+func testAuthors(start, n int) map[string]bool {
+	authors := make(map[string]bool)
+	for i := start; i < start+n; i++ {
+		authors[fmt.Sprintf("did:plc:%d", i)] = true
+	}
+	return authors
+}
+
+// Test that stories are grouped by title and co-sharing, keeping the highest ranked link as the primary
+func TestClusterStories(t *testing.T) {
+	stories := []Story{
+		{URL: "https://a.com/tariffs", Title: "Supreme Court strikes down Trump tariffs", Authors: testAuthors(0, 10)},
+		{URL: "https://b.com/frogs", Title: "Scientists discover new frog species", Authors: testAuthors(100, 10)},
+		{URL: "https://c.com/ruling", Title: "Trump tariffs struck down by Supreme Court", Authors: testAuthors(200, 10)},
+		{URL: "https://d.com/untitled", Title: "", Authors: testAuthors(103, 10)}, // Shared by most of the same authors as 'frogs'
+		{URL: "https://e.com/few", Title: "", Authors: testAuthors(100, 2)},       // Too few authors to compare
+	}
+
+	clusters := ClusterStories(stories)
+	if len(clusters) != 3 {
+		t.Fatalf("expected 3 clusters, got %d", len(clusters))
+	}
+	if clusters[0].Primary.URL != "https://a.com/tariffs" || len(clusters[0].Others) != 1 || clusters[0].Others[0].URL != "https://c.com/ruling" {
+		t.Errorf("unexpected first cluster: %v", clusters[0])
+	}
+	if clusters[1].Primary.URL != "https://b.com/frogs" || len(clusters[1].Others) != 1 || clusters[1].Others[0].URL != "https://d.com/untitled" {
+		t.Errorf("unexpected second cluster: %v", clusters[1])
+	}
+	if clusters[2].Primary.URL != "https://e.com/few" || len(clusters[2].Others) != 0 {
+		t.Errorf("unexpected third cluster: %v", clusters[2])
+	}
+}
+
+// Test that authors are parsed from the AT URIs of posts
+func TestAuthors(t *testing.T) {
+	item := AggregationItem{Posts: map[string]int{
+		"at://did:plc:abc/app.bsky.feed.post/1": 3,
+		"at://did:plc:abc/app.bsky.feed.post/2": 1,
+		"at://did:plc:def/app.bsky.feed.post/3": 1,
+		"invalid":                               1,
+	}}
+	authors := item.Authors()
+	if len(authors) != 2 || !authors["did:plc:abc"] || !authors["did:plc:def"] {
+		t.Errorf("unexpected authors: %v", authors)
+	}
+}
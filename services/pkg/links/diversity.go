@@ -0,0 +1,49 @@
+package links
+
+import "github.com/georgemblack/blue-report/pkg/urltools"
+
+// Diversity limits the number of links from the same host in each list, so a single site can't fill a list.
+type Diversity struct {
+	MaxPerHost int // Maximum number of links from each host, or zero for no limit
+}
+
+// DiversityStats records how many links were demoted by the diversity policy, for transparency.
+type DiversityStats struct {
+	MaxPerHost int            `json:"max_per_host"` // Zero if there is no limit
+	Demoted    map[string]int `json:"demoted"`      // Name of each window -> number of links demoted from its list
+
+	// Name of each category -> name of each window -> number of links demoted from the category's list
+	CategoryDemoted map[string]map[string]int `json:"category_demoted"`
+}
+
+// Select returns up to 'n' of the given URLs, in order, skipping URLs from hosts that have reached the limit.
+// Skipped URLs are backfilled from further down the ranking. Hosts are compared using 'urltools.Hostname'.
+// Also returns the number of URLs demoted, i.e. those that would have been selected if there were no limit.
+func (d Diversity) Select(urls []string, n int) ([]string, int) {
+	selected := make([]string, 0, n)
+	listed := make(map[string]bool)
+	hosts := make(map[string]int)
+	for _, url := range urls {
+		if len(selected) >= n {
+			break
+		}
+
+		host := urltools.Hostname(url)
+		if d.MaxPerHost > 0 && hosts[host] >= d.MaxPerHost {
+			continue
+		}
+		hosts[host]++
+		selected = append(selected, url)
+		listed[url] = true
+	}
+
+	// Count the URLs that would have been listed without the limit, but were not
+	demoted := 0
+	for _, url := range urls[:min(n, len(urls))] {
+		if !listed[url] {
+			demoted++
+		}
+	}
+
+	return selected, demoted
+}
@@ -0,0 +1,40 @@
+package links
+
+import (
+	"slices"
+	"testing"
+)
+
+// Test that links beyond the per-host limit are replaced by links further down the ranking
+func TestDiversitySelect(t *testing.T) {
+	urls := []string{
+		"https://www.nytimes.com/a",
+		"https://nytimes.com/b",
+		"https://nytimes.com/c",
+		"https://example.com/a",
+		"https://nytimes.com/d",
+		"https://example.org/a",
+	}
+
+	tests := []struct {
+		limit    int
+		n        int
+		expected []string
+		demoted  int
+	}{
+		{0, 4, urls[:4], 0},
+		{1, 4, []string{urls[0], urls[3], urls[5]}, 2},
+		{2, 4, []string{urls[0], urls[1], urls[3], urls[5]}, 1},
+		{2, 10, []string{urls[0], urls[1], urls[3], urls[5]}, 2},
+	}
+
+	for _, test := range tests {
+		selected, demoted := Diversity{MaxPerHost: test.limit}.Select(urls, test.n)
+		if !slices.Equal(selected, test.expected) {
+			t.Errorf("unexpected links with limit %d: %v", test.limit, selected)
+		}
+		if demoted != test.demoted {
+			t.Errorf("expected %d demoted with limit %d, got %d", test.demoted, test.limit, demoted)
+		}
+	}
+}
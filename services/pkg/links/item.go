@@ -2,15 +2,18 @@ package links
 
 import (
 	"slices"
+	"strings"
 	"time"
 )
 
-// Keep track of two separate counts, for the 'previous day' and 'previous week' report.
+// AggregationItem keeps track of counts for each window in the aggregation's time bounds.
+// Counts are indexed in the same order as 'TimeBounds.Windows'.
 type AggregationItem struct {
-	WeekCount Counts
-	DayCount  Counts
-	HourCount Counts
-	Posts     map[string]int
+	Counts     []Counts
+	Posts      map[string]int
+	PostCounts map[string][]Counts // Counts for each window, by post, so posts can be excluded later. Not kept for bucketed interactions.
+	FirstSeen  time.Time           // Time of the earliest event referencing the URL
+	Hourly     []HourlyCount       // Interactions per hour, used for the link's trend
 }
 
 type Counts struct {
@@ -19,62 +22,133 @@ type Counts struct {
 	Likes   int
 }
 
-func (a *AggregationItem) HourScore() int {
-	return (a.HourCount.Posts * 10) + (a.HourCount.Reposts * 10) + a.HourCount.Likes
+// Add counts a single event of the given type.
+func (c *Counts) Add(eventType int) {
+	if eventType == 0 {
+		c.Posts++
+	}
+	if eventType == 1 {
+		c.Reposts++
+	}
+	if eventType == 2 {
+		c.Likes++
+	}
 }
 
-func (a *AggregationItem) DayScore() int {
-	return (a.DayCount.Posts * 10) + (a.DayCount.Reposts * 10) + a.DayCount.Likes
+// Count returns the counts for the window at the given index.
+func (a *AggregationItem) Count(window int) Counts {
+	if window < 0 || window >= len(a.Counts) {
+		return Counts{}
+	}
+	return a.Counts[window]
 }
 
-func (a *AggregationItem) WeekScore() int {
-	return (a.WeekCount.Posts * 10) + (a.WeekCount.Reposts * 10) + a.WeekCount.Likes
+// Total returns the counts for the longest window.
+func (a *AggregationItem) Total() Counts {
+	return a.Count(len(a.Counts) - 1)
 }
 
+// Score returns the score of the item within the window at the given index.
+func (a *AggregationItem) Score(scorer Scorer, bnds TimeBounds, window int) float64 {
+	recent, baseline := bnds.recentIndex(), bnds.baselineIndex()
+	return scorer.Score(ScoreInput{
+		Counts:         a.Count(window),
+		Window:         bnds.Windows[window].Duration,
+		Age:            bnds.End.Sub(a.FirstSeen),
+		Recent:         a.Count(recent),
+		RecentWindow:   bnds.Windows[recent].Duration,
+		Baseline:       a.Count(baseline),
+		BaselineWindow: bnds.Windows[baseline].Duration,
+	})
+}
+
+// CountEvent counts the event in each window it falls within.
+// Events before the start of the longest window are expected to be filtered by the caller.
 func (a *AggregationItem) CountEvent(eventType int, post string, ts time.Time, bnds TimeBounds) {
-	// Check if the event should be counted in the 'previous hour' report.
-	if ts.After(bnds.HourStart) {
-		if eventType == 0 {
-			a.HourCount.Posts++
-		}
-		if eventType == 1 {
-			a.HourCount.Reposts++
-		}
-		if eventType == 2 {
-			a.HourCount.Likes++
+	if a.Counts == nil {
+		a.Counts = make([]Counts, len(bnds.Windows))
+	}
+	if a.PostCounts == nil {
+		a.PostCounts = make(map[string][]Counts)
+	}
+	if a.PostCounts[post] == nil {
+		a.PostCounts[post] = make([]Counts, len(bnds.Windows))
+	}
+	for i, window := range bnds.Windows {
+		if ts.After(bnds.Start(window)) {
+			a.Counts[i].Add(eventType)
+			a.PostCounts[post][i].Add(eventType)
 		}
 	}
 
-	// Check if the event should be counted in the 'previous day' report.
-	if ts.After(bnds.DayStart) {
-		if eventType == 0 {
-			a.DayCount.Posts++
-		}
-		if eventType == 1 {
-			a.DayCount.Reposts++
-		}
-		if eventType == 2 {
-			a.DayCount.Likes++
-		}
+	if a.FirstSeen.IsZero() || ts.Before(a.FirstSeen) {
+		a.FirstSeen = ts
 	}
+	a.Hourly = addHourly(a.Hourly, bnds.End, ts, 1)
 
-	// Assume all events are within the 'previous week' report.
-	// This is checked in the caller.
-	if eventType == 0 {
-		a.WeekCount.Posts++
+	// Add AT URI of post to map, and increment number of interactions
+	if a.Posts == nil {
+		a.Posts = make(map[string]int)
 	}
-	if eventType == 1 {
-		a.WeekCount.Reposts++
+	a.Posts[post]++
+}
+
+// CountBucket adds counts to each window that 'ts' falls within.
+func (a *AggregationItem) CountBucket(counts Counts, posts map[string]int, firstSeen time.Time, ts time.Time, bnds TimeBounds) {
+	if a.Counts == nil {
+		a.Counts = make([]Counts, len(bnds.Windows))
 	}
-	if eventType == 2 {
-		a.WeekCount.Likes++
+	for i, window := range bnds.Windows {
+		if ts.After(bnds.Start(window)) {
+			a.Counts[i].Posts += counts.Posts
+			a.Counts[i].Reposts += counts.Reposts
+			a.Counts[i].Likes += counts.Likes
+		}
 	}
 
-	// Add AT URI of post to map, and increment number of interactions
+	if !firstSeen.IsZero() && (a.FirstSeen.IsZero() || firstSeen.Before(a.FirstSeen)) {
+		a.FirstSeen = firstSeen
+	}
+	a.Hourly = addHourly(a.Hourly, bnds.End, ts, counts.Posts+counts.Reposts+counts.Likes)
+
 	if a.Posts == nil {
 		a.Posts = make(map[string]int)
 	}
-	a.Posts[post]++
+	for post, count := range posts {
+		a.Posts[post] += count
+	}
+}
+
+// Exclude removes interactions with the given posts from the item's counts, returning the number of interactions removed.
+// Only posts with known counts (see 'PostCounts') can be removed. The item's trend and first-seen time are not changed.
+func (a *AggregationItem) Exclude(posts map[string]bool) int {
+	removed := 0
+	for post, counts := range a.PostCounts {
+		if !posts[post] {
+			continue
+		}
+		for i := range counts {
+			a.Counts[i].Posts -= counts[i].Posts
+			a.Counts[i].Reposts -= counts[i].Reposts
+			a.Counts[i].Likes -= counts[i].Likes
+		}
+		removed += a.Posts[post]
+		delete(a.Posts, post)
+		delete(a.PostCounts, post)
+	}
+	return removed
+}
+
+// Authors returns the DIDs of the authors of posts referencing the URL, parsed from each post's AT URI.
+func (a *AggregationItem) Authors() map[string]bool {
+	authors := make(map[string]bool, len(a.Posts))
+	for post := range a.Posts {
+		did, _, ok := strings.Cut(strings.TrimPrefix(post, "at://"), "/")
+		if ok && did != "" {
+			authors[did] = true
+		}
+	}
+	return authors
 }
 
 // TopPosts returns the AT URIs of the top ten posts referencing the URL, based on the number of interactions.
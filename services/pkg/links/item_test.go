@@ -7,10 +7,7 @@ import (
 
 func TestAggregationItem(t *testing.T) {
 	now := time.Now().UTC()
-	bounds := TimeBounds{
-		DayStart:  now.Add(-24 * time.Hour),
-		WeekStart: now.Add(-24 * 7 * time.Hour),
-	}
+	bounds := NewTimeBounds(now, testWindows())
 	item := AggregationItem{}
 
 	ts := now.Add(-1 * time.Minute)
@@ -20,8 +17,8 @@ func TestAggregationItem(t *testing.T) {
 	item.CountEvent(2, "xyz", ts, bounds)
 	item.CountEvent(2, "xyz", ts, bounds)
 
-	if item.DayScore() != 32 {
-		t.Errorf("unexpected score: %d", item.DayScore())
+	if score := item.Score(DefaultLinearScorer(), bounds, bounds.Index("24h")); score != 32 {
+		t.Errorf("unexpected score: %f", score)
 	}
 
 	top := item.TopPosts()
@@ -35,3 +32,27 @@ func TestAggregationItem(t *testing.T) {
 		t.Errorf("unexpected top post: %s", top[1])
 	}
 }
+
+// Test that interactions with excluded posts are removed from each window
+func TestAggregationItemExclude(t *testing.T) {
+	now := time.Now().UTC()
+	bounds := NewTimeBounds(now, testWindows())
+	item := AggregationItem{}
+
+	ts := now.Add(-1 * time.Minute)
+	item.CountEvent(0, "abc", ts, bounds)
+	item.CountEvent(1, "abc", ts, bounds)
+	item.CountEvent(2, "xyz", ts, bounds)
+
+	if removed := item.Exclude(map[string]bool{"abc": true}); removed != 2 {
+		t.Errorf("unexpected removed count: %d", removed)
+	}
+	for i := range bounds.Windows {
+		if item.Counts[i] != (Counts{Likes: 1}) {
+			t.Errorf("unexpected counts in window %d: %v", i, item.Counts[i])
+		}
+	}
+	if top := item.TopPosts(); len(top) != 1 || top[0] != "xyz" {
+		t.Errorf("unexpected top posts: %v", top)
+	}
+}
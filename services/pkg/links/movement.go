@@ -0,0 +1,81 @@
+package links
+
+import "time"
+
+// ReEntryWindow is how long a link is remembered after dropping out of a list, so it can be marked as a re-entry if it returns.
+const ReEntryWindow = 7 * 24 * time.Hour
+
+// Movement describes how a link's position in a list has changed since the previous snapshot.
+type Movement struct {
+	PreviousRank     int    `json:"previous_rank"`     // Rank in the previous snapshot, or zero if the link was not listed
+	RankChange       int    `json:"rank_change"`       // Number of positions moved since the previous snapshot, where positive values moved up
+	NewEntry         bool   `json:"new_entry"`         // Not listed in the previous snapshot, or recently before that
+	ReEntry          bool   `json:"re_entry"`          // Not listed in the previous snapshot, but listed within 'ReEntryWindow'
+	ListedSince      string `json:"listed_since"`      // Time the link entered the list, and has remained since
+	ConsecutiveHours int    `json:"consecutive_hours"` // Number of hours the link has remained in the list
+}
+
+// DepartedLinks maps the name of each window to the links that have recently dropped out of its list, and the time they were last listed.
+type DepartedLinks map[string]map[string]string
+
+// TrackMovement compares each list to the same list in the previous snapshot, and records how each link has moved.
+// If there is no previous snapshot, links are not marked as new entries, as there is nothing to compare to.
+func (s *Snapshot) TrackMovement(previous Snapshot) {
+	now, _ := time.Parse(time.RFC3339, s.GeneratedAt)
+	_, err := time.Parse(time.RFC3339, previous.GeneratedAt)
+	hasPrevious := err == nil && previous.TopLinks != nil
+
+	s.Departed = make(DepartedLinks)
+	for _, window := range s.Windows {
+		list := s.TopLinks[window]
+		listed := make(map[string]Link)
+		for _, link := range previous.TopLinks[window] {
+			listed[link.URL] = link
+		}
+
+		// Links that dropped out of the list are remembered until they are too old to count as a re-entry
+		departed := make(map[string]string)
+		for url, lastListed := range previous.Departed[window] {
+			ts, err := time.Parse(time.RFC3339, lastListed)
+			if err == nil && now.Sub(ts) <= ReEntryWindow {
+				departed[url] = lastListed
+			}
+		}
+
+		current := make(map[string]bool)
+		for i := range list {
+			current[list[i].URL] = true
+			movement := Movement{ListedSince: s.GeneratedAt}
+
+			if prev, ok := listed[list[i].URL]; ok {
+				movement.PreviousRank = prev.Rank
+				movement.RankChange = prev.Rank - list[i].Rank
+				movement.ListedSince = prev.Movement.ListedSince
+				if movement.ListedSince == "" {
+					movement.ListedSince = previous.GeneratedAt
+				}
+			} else if hasPrevious {
+				_, ok := departed[list[i].URL]
+				movement.ReEntry = ok
+				movement.NewEntry = !ok
+			}
+
+			if since, err := time.Parse(time.RFC3339, movement.ListedSince); err == nil {
+				movement.ConsecutiveHours = int(now.Sub(since).Hours())
+			}
+			list[i].Movement = movement
+		}
+
+		for url := range departed {
+			if current[url] {
+				delete(departed, url)
+			}
+		}
+		for url := range listed {
+			if !current[url] {
+				departed[url] = previous.GeneratedAt
+			}
+		}
+		s.Departed[window] = departed
+	}
+}
@@ -0,0 +1,85 @@
+package links
+
+import (
+	"testing"
+	"time"
+)
+
+func testMovementSnapshot(generatedAt time.Time, urls ...string) Snapshot {
+	list := make([]Link, 0, len(urls))
+	for i, url := range urls {
+		list = append(list, Link{Rank: i + 1, URL: url})
+	}
+	return Snapshot{
+		GeneratedAt: generatedAt.Format(time.RFC3339),
+		Windows:     []string{"24h"},
+		TopLinks:    TopLinks{"24h": list},
+	}
+}
+
+func TestTrackMovement(t *testing.T) {
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	// The first snapshot has nothing to compare to
+	first := testMovementSnapshot(start, "a", "b", "c")
+	first.TrackMovement(Snapshot{})
+	for _, link := range first.TopLinks["24h"] {
+		if link.Movement.NewEntry || link.Movement.ReEntry || link.Movement.ListedSince != first.GeneratedAt {
+			t.Errorf("unexpected movement for %s: %+v", link.URL, link.Movement)
+		}
+	}
+
+	// 'c' moves up, 'a' moves down, 'b' drops out, and 'd' is new
+	second := testMovementSnapshot(start.Add(time.Hour), "c", "a", "d")
+	second.TrackMovement(first)
+	expected := []Movement{
+		{PreviousRank: 3, RankChange: 2, ListedSince: first.GeneratedAt, ConsecutiveHours: 1},
+		{PreviousRank: 1, RankChange: -1, ListedSince: first.GeneratedAt, ConsecutiveHours: 1},
+		{NewEntry: true, ListedSince: second.GeneratedAt},
+	}
+	for i, link := range second.TopLinks["24h"] {
+		if link.Movement != expected[i] {
+			t.Errorf("unexpected movement for %s: %+v", link.URL, link.Movement)
+		}
+	}
+	if second.Departed["24h"]["b"] != first.GeneratedAt {
+		t.Errorf("expected 'b' to have departed: %v", second.Departed)
+	}
+
+	// 'b' returns, and 'c' has been listed for three hours
+	third := testMovementSnapshot(start.Add(3*time.Hour), "b", "c")
+	third.TrackMovement(second)
+	b, c := third.TopLinks["24h"][0], third.TopLinks["24h"][1]
+	if !b.Movement.ReEntry || b.Movement.NewEntry || b.Movement.PreviousRank != 0 {
+		t.Errorf("expected 'b' to re-enter: %+v", b.Movement)
+	}
+	if c.Movement.ConsecutiveHours != 3 || c.Movement.RankChange != -1 {
+		t.Errorf("unexpected movement for 'c': %+v", c.Movement)
+	}
+	if _, ok := third.Departed["24h"]["b"]; ok {
+		t.Errorf("expected 'b' to be removed from departed links: %v", third.Departed)
+	}
+	if len(third.Departed["24h"]) != 2 {
+		t.Errorf("expected 'a' and 'd' to have departed: %v", third.Departed)
+	}
+}
+
+// Test that departed links are forgotten after the re-entry window
+func TestTrackMovementExpiry(t *testing.T) {
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	previous := testMovementSnapshot(start, "a")
+	previous.Departed = DepartedLinks{"24h": {
+		"b": start.Add(-ReEntryWindow).Format(time.RFC3339),
+		"c": start.Add(-time.Hour).Format(time.RFC3339),
+	}}
+
+	current := testMovementSnapshot(start.Add(time.Hour), "b", "c")
+	current.TrackMovement(previous)
+	b, c := current.TopLinks["24h"][0], current.TopLinks["24h"][1]
+	if !b.Movement.NewEntry {
+		t.Errorf("expected 'b' to be a new entry: %+v", b.Movement)
+	}
+	if !c.Movement.ReEntry {
+		t.Errorf("expected 'c' to re-enter: %+v", c.Movement)
+	}
+}
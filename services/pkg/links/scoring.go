@@ -0,0 +1,163 @@
+package links
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	LinearModel   = "linear"
+	GravityModel  = "gravity"
+	VelocityModel = "velocity"
+)
+
+// Scorer computes the score used to rank links within a time window.
+type Scorer interface {
+	Score(input ScoreInput) float64
+	Model() ScoringModel
+}
+
+// ScoreInput contains everything known about a link when scoring it for a single window.
+type ScoreInput struct {
+	Counts         Counts        // Counts within the window being ranked
+	Window         time.Duration // Length of the window being ranked
+	Age            time.Duration // Time since the link was first seen, as of the end of the window
+	Recent         Counts        // Counts within the shortest window (i.e. the past hour)
+	RecentWindow   time.Duration
+	Baseline       Counts // Counts within a longer window, used as a baseline for the recent window (i.e. the past day)
+	BaselineWindow time.Duration
+}
+
+// ScoringModel describes the scorer used to generate a snapshot, so that rankings can be compared across models.
+type ScoringModel struct {
+	Name   string             `json:"name"`
+	Params map[string]float64 `json:"params"`
+}
+
+// NewScorer creates a scorer for the named model. Params not provided use the model's defaults, and unknown params are rejected.
+func NewScorer(model string, params map[string]float64) (Scorer, error) {
+	var defaults map[string]float64
+	switch model {
+	case LinearModel:
+		defaults = DefaultLinearScorer().Model().Params
+	case GravityModel:
+		defaults = DefaultGravityScorer().Model().Params
+	case VelocityModel:
+		defaults = DefaultVelocityScorer().Model().Params
+	default:
+		return nil, fmt.Errorf("unknown scoring model: %s", model)
+	}
+
+	merged := maps.Clone(defaults)
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		if _, ok := defaults[key]; !ok {
+			return nil, fmt.Errorf("unknown param for %s scoring model: %s", model, key)
+		}
+		merged[key] = params[key]
+	}
+
+	linear := LinearScorer{PostWeight: merged["post_weight"], RepostWeight: merged["repost_weight"], LikeWeight: merged["like_weight"]}
+	switch model {
+	case GravityModel:
+		return GravityScorer{Linear: linear, Gravity: merged["gravity"], Offset: merged["offset"]}, nil
+	case VelocityModel:
+		return VelocityScorer{Linear: linear, Exponent: merged["exponent"], Smoothing: merged["smoothing"]}, nil
+	default:
+		return linear, nil
+	}
+}
+
+// LinearScorer is a weighted sum of posts, reposts, and likes. This is the original ranking model.
+type LinearScorer struct {
+	PostWeight   float64
+	RepostWeight float64
+	LikeWeight   float64
+}
+
+// DefaultLinearScorer scores links with '10*posts + 10*reposts + likes'.
+func DefaultLinearScorer() LinearScorer {
+	return LinearScorer{PostWeight: 10, RepostWeight: 10, LikeWeight: 1}
+}
+
+func (l LinearScorer) Score(input ScoreInput) float64 {
+	return l.sum(input.Counts)
+}
+
+func (l LinearScorer) Model() ScoringModel {
+	return ScoringModel{Name: LinearModel, Params: l.params()}
+}
+
+func (l LinearScorer) sum(counts Counts) float64 {
+	return l.PostWeight*float64(counts.Posts) + l.RepostWeight*float64(counts.Reposts) + l.LikeWeight*float64(counts.Likes)
+}
+
+func (l LinearScorer) params() map[string]float64 {
+	return map[string]float64{"post_weight": l.PostWeight, "repost_weight": l.RepostWeight, "like_weight": l.LikeWeight}
+}
+
+// GravityScorer decays the linear score by the link's age, similar to Hacker News:
+//
+//	score = linear / (ageHours + offset)^gravity
+//
+// Older links need proportionally more engagement to stay near the top.
+type GravityScorer struct {
+	Linear  LinearScorer
+	Gravity float64
+	Offset  float64 // Hours added to the age, so new links are not infinitely boosted
+}
+
+func DefaultGravityScorer() GravityScorer {
+	return GravityScorer{Linear: DefaultLinearScorer(), Gravity: 1.8, Offset: 2}
+}
+
+func (g GravityScorer) Score(input ScoreInput) float64 {
+	age := max(input.Age.Hours(), 0)
+	return g.Linear.sum(input.Counts) / math.Pow(age+g.Offset, g.Gravity)
+}
+
+func (g GravityScorer) Model() ScoringModel {
+	params := g.Linear.params()
+	params["gravity"] = g.Gravity
+	params["offset"] = g.Offset
+	return ScoringModel{Name: GravityModel, Params: params}
+}
+
+// VelocityScorer boosts links that are accelerating, by comparing the rate of engagement in the recent window to the baseline window:
+//
+//	velocity = (recentRate + smoothing) / (baselineRate + smoothing)
+//	score = linear * velocity^exponent
+//
+// Rates are linear scores per hour. Smoothing prevents links with very little engagement from being boosted.
+type VelocityScorer struct {
+	Linear    LinearScorer
+	Exponent  float64
+	Smoothing float64
+}
+
+func DefaultVelocityScorer() VelocityScorer {
+	return VelocityScorer{Linear: DefaultLinearScorer(), Exponent: 1, Smoothing: 10}
+}
+
+func (v VelocityScorer) Score(input ScoreInput) float64 {
+	recent := rate(v.Linear.sum(input.Recent), input.RecentWindow)
+	baseline := rate(v.Linear.sum(input.Baseline), input.BaselineWindow)
+	velocity := (recent + v.Smoothing) / (baseline + v.Smoothing)
+	return v.Linear.sum(input.Counts) * math.Pow(velocity, v.Exponent)
+}
+
+func (v VelocityScorer) Model() ScoringModel {
+	params := v.Linear.params()
+	params["exponent"] = v.Exponent
+	params["smoothing"] = v.Smoothing
+	return ScoringModel{Name: VelocityModel, Params: params}
+}
+
+func rate(score float64, window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return score / window.Hours()
+}
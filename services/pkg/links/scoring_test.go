@@ -0,0 +1,89 @@
+package links
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+)
+
+func TestLinearScorer(t *testing.T) {
+	score := DefaultLinearScorer().Score(ScoreInput{Counts: Counts{Posts: 1, Reposts: 2, Likes: 3}})
+	if score != 33 {
+		t.Errorf("unexpected score: %f", score)
+	}
+}
+
+func TestNewScorer(t *testing.T) {
+	scorer, err := NewScorer(GravityModel, map[string]float64{"gravity": 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := scorer.Model()
+	if model.Name != GravityModel {
+		t.Errorf("unexpected model: %s", model.Name)
+	}
+	if model.Params["gravity"] != 1.5 || model.Params["offset"] != 2 || model.Params["post_weight"] != 10 {
+		t.Errorf("unexpected params: %v", model.Params)
+	}
+
+	_, err = NewScorer(LinearModel, map[string]float64{"gravity": 1.5})
+	if err == nil {
+		t.Error("expected error for unknown param")
+	}
+	_, err = NewScorer("bogus", nil)
+	if err == nil {
+		t.Error("expected error for unknown model")
+	}
+}
+
+// Test that, with equal engagement, the gravity model ranks the newer link first
+func TestGravityScorer(t *testing.T) {
+	scorer := DefaultGravityScorer()
+	counts := Counts{Posts: 10, Likes: 100}
+
+	older := scorer.Score(ScoreInput{Counts: counts, Age: 20 * time.Hour})
+	newer := scorer.Score(ScoreInput{Counts: counts, Age: 2 * time.Hour})
+	if newer <= older {
+		t.Errorf("expected newer link to score higher: %f <= %f", newer, older)
+	}
+}
+
+// Test that, with equal engagement over the day, the velocity model ranks the accelerating link first
+func TestVelocityScorer(t *testing.T) {
+	scorer := DefaultVelocityScorer()
+	day := Counts{Posts: 48, Likes: 480}
+
+	steady := scorer.Score(ScoreInput{Counts: day, Recent: Counts{Posts: 2, Likes: 20}, RecentWindow: time.Hour, Baseline: day, BaselineWindow: 24 * time.Hour})
+	accelerating := scorer.Score(ScoreInput{Counts: day, Recent: Counts{Posts: 20, Likes: 200}, RecentWindow: time.Hour, Baseline: day, BaselineWindow: 24 * time.Hour})
+	if accelerating <= steady {
+		t.Errorf("expected accelerating link to score higher: %f <= %f", accelerating, steady)
+	}
+
+	// A steady link is not penalized relative to the linear model
+	if steady != DefaultLinearScorer().Score(ScoreInput{Counts: day}) {
+		t.Errorf("unexpected score for steady link: %f", steady)
+	}
+}
+
+// Test that the aggregation ranks links with the configured scorer
+func TestAggregationWithGravityScorer(t *testing.T) {
+	now := time.Now().UTC()
+	bounds := NewTimeBounds(now, testWindows())
+	aggregation := NewAggregation(bounds, DefaultGravityScorer(), dedupe.NewExact())
+
+	// Both links have equal engagement over the past day, but the older link was first seen over a day earlier
+	aggregation.CountEvent(0, "https://www.example.com/old", "a", "did0", now.Add(-40*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/old", "a", "did1", now.Add(-10*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/old", "a", "did2", now.Add(-10*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/new", "b", "did1", now.Add(-10*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/new", "b", "did2", now.Add(-10*time.Hour))
+
+	top := aggregation.TopLinks("24h", 2)
+	if len(top) != 2 || top[0] != "https://www.example.com/new" {
+		t.Errorf("unexpected top links: %v", top)
+	}
+	if aggregation.Scoring().Name != GravityModel {
+		t.Errorf("unexpected scoring model: %s", aggregation.Scoring().Name)
+	}
+}
@@ -1,6 +1,10 @@
 package links
 
-import "time"
+import (
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+)
 
 func NewSnapshot() Snapshot {
 	return Snapshot{
@@ -9,10 +13,37 @@ func NewSnapshot() Snapshot {
 }
 
 type Snapshot struct {
-	GeneratedAt string `json:"generated_at"`
-	TopHour     []Link `json:"top_hour"`
-	TopDay      []Link `json:"top_day"`
-	TopWeek     []Link `json:"top_week"`
+	GeneratedAt   string         `json:"generated_at"`
+	Language      string         `json:"language"`       // Language of the posts counted
+	Scoring       ScoringModel   `json:"scoring"`        // Model used to rank links
+	Deduplication dedupe.Stats   `json:"deduplication"`  // Accuracy of duplicate detection, i.e. how many events may have been incorrectly skipped
+	Windows       []string       `json:"windows"`        // Names of each window, from shortest to longest
+	TopLinks      TopLinks       `json:"top_links"`      // Top links within each window
+	TopCategories CategoryLinks  `json:"top_categories"` // Top links in each category (i.e. 'tech'), within each window
+	Diversity     DiversityStats `json:"diversity"`      // Links demoted from each window by the per-host limit
+	Removed       []RemovedLink  `json:"removed"`        // Top links removed from each window by the blocklist
+	Departed      DepartedLinks  `json:"departed"`       // Links that recently dropped out of each window, used to detect re-entries
+
+	// Top links for the past hour, day, and week, kept for existing consumers.
+	// These are copies of the matching windows, and are empty if the window is not configured.
+	TopHour []Link `json:"top_hour"`
+	TopDay  []Link `json:"top_day"`
+	TopWeek []Link `json:"top_week"`
+}
+
+// TopLinks maps the name of each window (i.e. '24h') to its top links.
+type TopLinks map[string][]Link
+
+// CategoryLinks maps the name of each category (i.e. 'tech') to its top links within each window.
+type CategoryLinks map[string]TopLinks
+
+// RemovedLink is a link that would have been listed in a window, but was removed by a blocklist rule.
+type RemovedLink struct {
+	URL    string `json:"url"`
+	Window string `json:"window"`
+	Rank   int    `json:"rank"`   // Rank before any links were removed
+	Rule   string `json:"rule"`   // ID of the rule that matched
+	Reason string `json:"reason"` // Reason given for the rule
 }
 
 func (s *Snapshot) TopDayLink() Link {
@@ -23,14 +54,18 @@ func (s *Snapshot) TopDayLink() Link {
 }
 
 type Link struct {
-	Rank             int    `json:"rank"`
-	URL              string `json:"url"`
-	Title            string `json:"title"`
-	ThumbnailURL     string `json:"thumbnail_url"`
-	PostCount        int    `json:"post_count"`
-	RepostCount      int    `json:"repost_count"`
-	LikeCount        int    `json:"like_count"`
-	RecommendedPosts []Post `json:"recommended_posts"`
+	Rank             int        `json:"rank"`
+	URL              string     `json:"url"`
+	Title            string     `json:"title"`
+	Category         string     `json:"category"`        // Category assigned by the classifier, i.e. 'tech', or 'other'
+	AlsoCoveredBy    []Coverage `json:"also_covered_by"` // Lower ranked links covering the same story, which are not listed separately
+	ThumbnailURL     string     `json:"thumbnail_url"`
+	PostCount        int        `json:"post_count"`
+	RepostCount      int        `json:"repost_count"`
+	LikeCount        int        `json:"like_count"`
+	RecommendedPosts []Post     `json:"recommended_posts"`
+	Trend            Trend      `json:"trend"`
+	Movement         Movement   `json:"movement"`
 }
 
 type Post struct {
@@ -0,0 +1,107 @@
+package links
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	DayTrendHours  = 24  // Length of the short series, in hourly buckets
+	WeekTrendHours = 168 // Length of the long series, and how much history is kept for each link
+
+	// Momentum compares the rate of interactions in the most recent hours to the rest of the past day.
+	MomentumHours = 3
+
+	// Links are 'rising' if their momentum is high, and they have enough recent interactions to not be noise.
+	RisingMomentum     = 2.0
+	RisingInteractions = 30
+)
+
+// HourlyCount is the number of interactions within a single hour, where 'Hour' is the number of hours before the end of the aggregation.
+// i.e. hour zero contains interactions in the hour leading up to the time of the report.
+type HourlyCount struct {
+	Hour         int
+	Interactions int
+}
+
+// Trend describes how interactions with a link have changed over time.
+type Trend struct {
+	Day       []int   `json:"day"`        // Interactions per hour over the past day, oldest first
+	Week      []int   `json:"week"`       // Interactions per hour over the past week, oldest first
+	PeakHour  string  `json:"peak_hour"`  // Start of the hour with the most interactions over the past week
+	FirstSeen string  `json:"first_seen"` // Time of the earliest interaction counted by the aggregation
+	Momentum  float64 `json:"momentum"`   // Recent rate of interactions relative to the past day, where values above one are accelerating
+	Rising    bool    `json:"rising"`
+}
+
+// Add interactions to the hourly bucket that 'ts' falls within.
+// Buckets are sparse, as most links are only shared within a few hours.
+func addHourly(hourly []HourlyCount, end time.Time, ts time.Time, interactions int) []HourlyCount {
+	if !ts.Before(end) {
+		ts = end.Add(-time.Nanosecond)
+	}
+	hour := int(end.Sub(ts) / time.Hour)
+	if hour >= WeekTrendHours {
+		return hourly
+	}
+
+	// Events are mostly counted in order, so search from the most recently added bucket
+	for i := len(hourly) - 1; i >= 0; i-- {
+		if hourly[i].Hour == hour {
+			hourly[i].Interactions += interactions
+			return hourly
+		}
+	}
+	return append(hourly, HourlyCount{Hour: hour, Interactions: interactions})
+}
+
+// Trend returns the item's time series and derived fields, relative to the end of the given time bounds.
+// History is only kept for events counted by the aggregation, so the series is empty before the start of the longest window.
+func (a *AggregationItem) Trend(bnds TimeBounds) Trend {
+	week := make([]int, WeekTrendHours)
+	for _, bucket := range a.Hourly {
+		week[WeekTrendHours-1-bucket.Hour] += bucket.Interactions
+	}
+
+	trend := Trend{
+		Day:  slices.Clone(week[WeekTrendHours-DayTrendHours:]),
+		Week: week,
+	}
+	if !a.FirstSeen.IsZero() {
+		trend.FirstSeen = a.FirstSeen.UTC().Format(time.RFC3339)
+	}
+
+	// The earliest hour wins ties, as that is when the link first peaked
+	peak := 0
+	for i := range week {
+		if week[i] > week[peak] {
+			peak = i
+		}
+	}
+	if week[peak] > 0 {
+		start := bnds.End.Add(-time.Duration(WeekTrendHours-peak) * time.Hour)
+		trend.PeakHour = start.UTC().Format(time.RFC3339)
+	}
+
+	recent := sum(trend.Day[DayTrendHours-MomentumHours:])
+	baseline := sum(trend.Day[:DayTrendHours-MomentumHours])
+	trend.Momentum = momentum(recent, baseline)
+	trend.Rising = trend.Momentum >= RisingMomentum && recent >= RisingInteractions
+
+	return trend
+}
+
+// Compare the hourly rate of recent interactions to the baseline. One is added to each rate, so links with little history are not boosted.
+func momentum(recent, baseline int) float64 {
+	recentRate := float64(recent) / MomentumHours
+	baselineRate := float64(baseline) / (DayTrendHours - MomentumHours)
+	return (recentRate + 1) / (baselineRate + 1)
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
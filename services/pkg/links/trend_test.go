@@ -0,0 +1,95 @@
+package links
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that interactions are bucketed by hour, relative to the end of the aggregation
+func TestTrend(t *testing.T) {
+	end := time.Date(2025, 3, 3, 12, 30, 0, 0, time.UTC)
+	bounds := NewTimeBounds(end, testWindows())
+	item := AggregationItem{}
+
+	// Two interactions in the past hour, three about a day ago, and five the day before
+	item.CountEvent(0, "abc", end.Add(-10*time.Minute), bounds)
+	item.CountEvent(2, "abc", end.Add(-50*time.Minute), bounds)
+	for i := 0; i < 3; i++ {
+		item.CountEvent(2, "abc", end.Add(-23*time.Hour-time.Minute), bounds)
+	}
+	for i := 0; i < 5; i++ {
+		item.CountEvent(2, "abc", end.Add(-47*time.Hour-time.Minute), bounds)
+	}
+
+	trend := item.Trend(bounds)
+	if len(trend.Day) != DayTrendHours || len(trend.Week) != WeekTrendHours {
+		t.Fatalf("unexpected series lengths: %d, %d", len(trend.Day), len(trend.Week))
+	}
+	if trend.Day[23] != 2 || trend.Day[0] != 3 || sum(trend.Day) != 5 {
+		t.Errorf("unexpected day series: %v", trend.Day)
+	}
+	if trend.Week[167] != 2 || trend.Week[144] != 3 || trend.Week[120] != 5 || sum(trend.Week) != 10 {
+		t.Errorf("unexpected week series: %v", trend.Week)
+	}
+	if trend.PeakHour != "2025-03-01T12:30:00Z" {
+		t.Errorf("unexpected peak hour: %s", trend.PeakHour)
+	}
+	if trend.FirstSeen != "2025-03-01T13:29:00Z" {
+		t.Errorf("unexpected first seen: %s", trend.FirstSeen)
+	}
+}
+
+// Test that live buckets are added to the same hourly series
+func TestTrendBuckets(t *testing.T) {
+	end := time.Date(2025, 3, 3, 12, 30, 0, 0, time.UTC)
+	bounds := NewTimeBounds(end, testWindows())
+	item := AggregationItem{}
+
+	item.CountBucket(Counts{Posts: 1, Likes: 4}, nil, end.Add(-2*time.Hour), end.Add(-2*time.Hour), bounds)
+	item.CountBucket(Counts{Reposts: 2}, nil, time.Time{}, end.Add(-90*time.Minute), bounds)
+
+	trend := item.Trend(bounds)
+	if trend.Day[21] != 5 || trend.Day[22] != 2 {
+		t.Errorf("unexpected day series: %v", trend.Day)
+	}
+}
+
+func TestMomentum(t *testing.T) {
+	end := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	bounds := NewTimeBounds(end, testWindows())
+
+	// A link with steady engagement over the past day is not rising
+	steady := AggregationItem{}
+	for hour := 0; hour < 24; hour++ {
+		for i := 0; i < 20; i++ {
+			steady.CountEvent(2, "abc", end.Add(-time.Duration(hour)*time.Hour-time.Minute), bounds)
+		}
+	}
+	if trend := steady.Trend(bounds); trend.Momentum != 1 || trend.Rising {
+		t.Errorf("unexpected steady trend: %f, %t", trend.Momentum, trend.Rising)
+	}
+
+	// A link with a burst of recent engagement is rising
+	burst := AggregationItem{}
+	for i := 0; i < 60; i++ {
+		burst.CountEvent(2, "abc", end.Add(-time.Duration(i)*time.Minute-time.Second), bounds)
+	}
+	if trend := burst.Trend(bounds); trend.Momentum <= RisingMomentum || !trend.Rising {
+		t.Errorf("unexpected burst trend: %f, %t", trend.Momentum, trend.Rising)
+	}
+
+	// A few recent interactions are not enough to be rising
+	quiet := AggregationItem{}
+	for i := 0; i < 5; i++ {
+		quiet.CountEvent(2, "abc", end.Add(-time.Minute), bounds)
+	}
+	if trend := quiet.Trend(bounds); trend.Rising {
+		t.Errorf("expected quiet link to not be rising: %f", trend.Momentum)
+	}
+
+	// A link with no history has neutral momentum, and no peak
+	empty := AggregationItem{}
+	if trend := empty.Trend(bounds); trend.Momentum != 1 || trend.PeakHour != "" || trend.FirstSeen != "" {
+		t.Errorf("unexpected empty trend: %+v", trend)
+	}
+}
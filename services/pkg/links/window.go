@@ -0,0 +1,129 @@
+package links
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultWindows is used when no windows are configured.
+const DefaultWindows = "1h,24h,7d"
+
+// Window is a named time window ending at the time of the report, i.e. the past 24 hours.
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// ParseWindows parses a comma-separated list of durations, i.e. '15m,1h,24h,7d'.
+// Durations use Go's syntax, with the addition of 'd' for days. Each window is named by its duration, as written.
+func ParseWindows(value string) ([]Window, error) {
+	windows := make([]Window, 0)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		duration, err := parseDuration(name)
+		if err != nil {
+			return nil, err
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("window must be positive: %s", name)
+		}
+		if slices.ContainsFunc(windows, func(w Window) bool { return w.Duration == duration }) {
+			return nil, fmt.Errorf("duplicate window: %s", name)
+		}
+		windows = append(windows, Window{Name: name, Duration: duration})
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no windows in '%s'", value)
+	}
+	return windows, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		parsed, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window: %s", value)
+		}
+		return time.Duration(parsed) * 24 * time.Hour, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window: %s", value)
+	}
+	return parsed, nil
+}
+
+// TimeBounds defines the windows counted by an aggregation, all ending at the same time.
+// Windows are sorted from shortest to longest. Events before the start of the longest window are not counted.
+type TimeBounds struct {
+	End     time.Time
+	Windows []Window
+}
+
+func NewTimeBounds(end time.Time, windows []Window) TimeBounds {
+	sorted := slices.Clone(windows)
+	slices.SortFunc(sorted, func(a, b Window) int {
+		return int(a.Duration - b.Duration)
+	})
+	return TimeBounds{End: end, Windows: sorted}
+}
+
+// Start returns the start of the given window.
+func (b TimeBounds) Start(window Window) time.Time {
+	return b.End.Add(-window.Duration)
+}
+
+// Earliest returns the start of the longest window.
+func (b TimeBounds) Earliest() time.Time {
+	return b.Start(b.Longest())
+}
+
+// Longest returns the longest window, or an empty window if there are none.
+func (b TimeBounds) Longest() Window {
+	if len(b.Windows) == 0 {
+		return Window{}
+	}
+	return b.Windows[len(b.Windows)-1]
+}
+
+// Index returns the position of the named window, or -1 if it does not exist.
+func (b TimeBounds) Index(name string) int {
+	return slices.IndexFunc(b.Windows, func(w Window) bool { return w.Name == name })
+}
+
+// Find returns the window with the given duration, if configured.
+func (b TimeBounds) Find(duration time.Duration) (Window, bool) {
+	i := b.indexOf(duration)
+	if i < 0 {
+		return Window{}, false
+	}
+	return b.Windows[i], true
+}
+
+// Windows used by the velocity model: the past hour is compared to the past day.
+// If either is not configured, the shortest and longest windows are used instead.
+func (b TimeBounds) recentIndex() int {
+	if i := b.indexOf(time.Hour); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+func (b TimeBounds) baselineIndex() int {
+	if i := b.indexOf(24 * time.Hour); i >= 0 {
+		return i
+	}
+	return len(b.Windows) - 1
+}
+
+func (b TimeBounds) indexOf(duration time.Duration) int {
+	return slices.IndexFunc(b.Windows, func(w Window) bool { return w.Duration == duration })
+}
@@ -0,0 +1,83 @@
+package links
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+)
+
+func testWindows() []Window {
+	windows, _ := ParseWindows("1h,24h,7d")
+	return windows
+}
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows(DefaultWindows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Duration{time.Hour, 24 * time.Hour, 168 * time.Hour}
+	if len(windows) != len(expected) {
+		t.Fatalf("expected %d windows, got %d", len(expected), len(windows))
+	}
+	for i := range expected {
+		if windows[i].Duration != expected[i] {
+			t.Errorf("unexpected duration for window %s: %s", windows[i].Name, windows[i].Duration)
+		}
+	}
+
+	// Other windows can be configured, and keep the name they were configured with
+	windows, err = ParseWindows("15m,6h,3d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) != 3 || windows[0].Duration != 15*time.Minute || windows[2].Name != "3d" || windows[2].Duration != 72*time.Hour {
+		t.Errorf("unexpected windows: %v", windows)
+	}
+
+	invalid := []string{"", "bogus", "1h,60m", "-1h", "xd"}
+	for _, value := range invalid {
+		if _, err := ParseWindows(value); err == nil {
+			t.Errorf("expected error for windows '%s'", value)
+		}
+	}
+}
+
+// Test that windows are sorted, and each event is counted in every window it falls within
+func TestAggregationWithWindows(t *testing.T) {
+	now := time.Now().UTC()
+	windows, _ := ParseWindows("7d,15m,6h")
+	bounds := NewTimeBounds(now, windows)
+	if bounds.Windows[0].Name != "15m" || bounds.Windows[2].Name != "7d" {
+		t.Fatalf("unexpected window order: %v", bounds.Windows)
+	}
+
+	aggregation := NewAggregation(bounds, DefaultLinearScorer(), dedupe.NewExact())
+	aggregation.CountEvent(0, "https://www.example.com/a", "a", "did1", now.Add(-5*time.Minute))
+	aggregation.CountEvent(0, "https://www.example.com/b", "b", "did1", now.Add(-2*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/b", "b", "did2", now.Add(-2*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/c", "c", "did1", now.Add(-3*24*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/c", "c", "did2", now.Add(-3*24*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/c", "c", "did3", now.Add(-3*24*time.Hour))
+	aggregation.CountEvent(0, "https://www.example.com/d", "d", "did1", now.Add(-8*24*time.Hour)) // Outside all windows
+
+	expected := map[string]string{"15m": "https://www.example.com/a", "6h": "https://www.example.com/b", "7d": "https://www.example.com/c"}
+	for window, url := range expected {
+		top := aggregation.TopLinks(window, 1)
+		if len(top) != 1 || top[0] != url {
+			t.Errorf("unexpected top link for window %s: %v", window, top)
+		}
+	}
+
+	item := aggregation.Get("https://www.example.com/b")
+	if item.Count(0).Posts != 0 || item.Count(1).Posts != 2 || item.Total().Posts != 2 {
+		t.Errorf("unexpected counts: %v", item.Counts)
+	}
+	if len(aggregation.TopLinks("1h", 1)) != 0 {
+		t.Error("expected no links for unknown window")
+	}
+	if aggregation.Total() != 6 {
+		t.Errorf("expected 6 total, got %d", aggregation.Total())
+	}
+}
@@ -0,0 +1,82 @@
+package live
+
+import (
+	"slices"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/links"
+)
+
+const (
+	BucketSize = time.Minute // Events are counted in per-minute buckets
+	RollupSize = time.Hour   // Buckets are also rolled up per hour, so long windows can be read without reading every minute
+)
+
+// Bucket contains the interactions with each URL within a single minute, or a single hour for rolled up buckets.
+// Interactions have already been deduplicated, using the same rules as batch aggregation.
+type Bucket struct {
+	Start time.Time
+	Size  time.Duration
+	Links map[string]*BucketLink
+}
+
+type BucketLink struct {
+	Counts    links.Counts
+	Posts     map[string]int // Number of interactions with each post referencing the URL
+	FirstSeen time.Time      // Time of the earliest event referencing the URL within the bucket
+}
+
+func newBucket(start time.Time, size time.Duration) *Bucket {
+	return &Bucket{Start: start, Size: size, Links: make(map[string]*BucketLink)}
+}
+
+// Return the link for the URL, creating it if needed.
+func (b *Bucket) link(url string) *BucketLink {
+	link, ok := b.Links[url]
+	if !ok {
+		link = &BucketLink{Posts: make(map[string]int)}
+		b.Links[url] = link
+	}
+	return link
+}
+
+// Add the counts of another bucket's link to this bucket.
+func (b *Bucket) merge(url string, other *BucketLink) {
+	link := b.link(url)
+	link.Counts.Posts += other.Counts.Posts
+	link.Counts.Reposts += other.Counts.Reposts
+	link.Counts.Likes += other.Counts.Likes
+	for post, count := range other.Posts {
+		link.Posts[post] += count
+	}
+	if link.FirstSeen.IsZero() || (!other.FirstSeen.IsZero() && other.FirstSeen.Before(link.FirstSeen)) {
+		link.FirstSeen = other.FirstSeen
+	}
+}
+
+// Return the buckets to read for the bounds, from the start of the longest window to the end.
+// Whole hours are read from rolled up buckets. Buckets are counted at their start, so hours containing the start of a window (or the end) are read by the minute.
+// This includes hours starting at the start of a window, as aggregations only count buckets starting after the start of a window.
+func spans(bounds links.TimeBounds) []Bucket {
+	starts := make([]time.Time, 0, len(bounds.Windows))
+	for _, window := range bounds.Windows {
+		starts = append(starts, bounds.Start(window))
+	}
+
+	result := make([]Bucket, 0)
+	current := bounds.Earliest().UTC().Truncate(BucketSize)
+	for !current.After(bounds.End) {
+		end := current.Add(RollupSize)
+		whole := current.Equal(current.Truncate(RollupSize)) && !end.After(bounds.End) && !slices.ContainsFunc(starts, func(ts time.Time) bool {
+			return !ts.Before(current) && ts.Before(end)
+		})
+		if whole {
+			result = append(result, Bucket{Start: current, Size: RollupSize})
+			current = end
+			continue
+		}
+		result = append(result, Bucket{Start: current, Size: BucketSize})
+		current = current.Add(BucketSize)
+	}
+	return result
+}
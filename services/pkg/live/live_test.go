@@ -0,0 +1,205 @@
+package live
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+type liveUnderTest interface {
+	Record(record storage.EventRecord) error
+	Buckets(bounds links.TimeBounds) ([]Bucket, error)
+	Close()
+}
+
+// Test the in-memory live aggregation, using a fake clock
+func TestMemory(t *testing.T) {
+	now := time.Now().UTC()
+	testLive(t, NewMemoryWithClock(time.Hour, func() time.Time { return now }), now)
+}
+
+// Test the Valkey live aggregation. Only runs if 'VALKEY_TEST_ADDRESS' is set.
+func TestValkey(t *testing.T) {
+	address := os.Getenv("VALKEY_TEST_ADDRESS")
+	if address == "" {
+		t.Skip("VALKEY_TEST_ADDRESS not set")
+	}
+
+	lv, err := New(config.Config{ValkeyAddress: address}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLive(t, lv, time.Now().UTC())
+}
+
+func testLive(t *testing.T, lv liveUnderTest, now time.Time) {
+	defer lv.Close()
+
+	// URLs are unique per run, so tests against a shared instance do not interfere
+	url := fmt.Sprintf("https://www.example.com/%d", time.Now().UnixNano())
+	minute := now.Truncate(time.Minute)
+	events := []storage.EventRecord{
+		{Type: 0, URL: url, DID: "did:plc:user1", Timestamp: minute.Add(-2*time.Minute + 10*time.Second), Post: "at://post/1"},
+		{Type: 2, URL: url, DID: "did:plc:user2", Timestamp: minute.Add(-2*time.Minute + 20*time.Second), Post: "at://post/1"},
+		{Type: 2, URL: url, DID: "did:plc:user2", Timestamp: minute.Add(-2*time.Minute + 30*time.Second), Post: "at://post/1"}, // Duplicate
+		{Type: 1, URL: url, DID: "did:plc:user2", Timestamp: minute.Add(-1 * time.Minute), Post: "at://post/2"},
+	}
+	for _, event := range events {
+		if err := lv.Record(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buckets, err := lv.Buckets(testBounds(now, 10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(buckets))
+	}
+
+	first := buckets[0].Links[url]
+	if !buckets[0].Start.Equal(minute.Add(-2*time.Minute)) || buckets[0].Size != BucketSize {
+		t.Errorf("unexpected bucket: %s, %s", buckets[0].Start, buckets[0].Size)
+	}
+	if first == nil || first.Counts.Posts != 1 || first.Counts.Likes != 1 || first.Counts.Reposts != 0 {
+		t.Fatalf("unexpected counts in first bucket: %+v", first)
+	}
+	if first.Posts["at://post/1"] != 2 {
+		t.Errorf("unexpected post counts: %v", first.Posts)
+	}
+	if !first.FirstSeen.Equal(events[0].Timestamp.Truncate(time.Second)) {
+		t.Errorf("unexpected first seen: %s", first.FirstSeen)
+	}
+
+	second := buckets[1].Links[url]
+	if second == nil || second.Counts.Reposts != 1 || second.Posts["at://post/2"] != 1 {
+		t.Errorf("unexpected second bucket: %+v", second)
+	}
+
+	// Buckets outside the range are not returned
+	buckets, _ = lv.Buckets(testBounds(now, now.Sub(minute.Add(-time.Minute))))
+	if len(buckets) != 1 {
+		t.Errorf("expected 1 bucket, got %d", len(buckets))
+	}
+}
+
+// Test that old buckets and fingerprints are removed from memory
+func TestMemorySweep(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	lv := NewMemoryWithClock(time.Hour, func() time.Time { return now })
+
+	event := storage.EventRecord{Type: 0, URL: "https://www.example.com", DID: "did:plc:user1", Timestamp: now, Post: "at://post/1"}
+	lv.Record(event)
+
+	// Once the fingerprint has expired, the same user is counted again
+	now = now.Add(2 * time.Hour)
+	event.Timestamp = now
+	lv.Record(event)
+
+	if len(lv.buckets) != 1 || len(lv.fingerprints) != 1 {
+		t.Errorf("expected 1 bucket and fingerprint after sweep, got %d and %d", len(lv.buckets), len(lv.fingerprints))
+	}
+	buckets, _ := lv.Buckets(testBounds(now, time.Hour))
+	if len(buckets) != 1 || buckets[0].Links["https://www.example.com"].Counts.Posts != 1 {
+		t.Errorf("unexpected buckets: %v", buckets)
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	minute := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	bucket, err := parseBucket(minute, BucketSize, map[string]string{
+		"c0\nhttps://www.example.com/a":           "3",
+		"c2\nhttps://www.example.com/a":           "5",
+		"phttps://www.example.com/a\nat://post/1": "8",
+		"fhttps://www.example.com/a":              "1741003210",
+		"c1\nhttps://www.example.com/b?query=a|b": "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := bucket.Links["https://www.example.com/a"]
+	if a.Counts.Posts != 3 || a.Counts.Likes != 5 || a.Posts["at://post/1"] != 8 || a.FirstSeen.Unix() != 1741003210 {
+		t.Errorf("unexpected link: %+v", a)
+	}
+	if bucket.Links["https://www.example.com/b?query=a|b"].Counts.Reposts != 1 {
+		t.Errorf("unexpected links: %v", bucket.Links)
+	}
+
+	if _, err := parseBucket(minute, BucketSize, map[string]string{"c0": "1"}); err == nil {
+		t.Error("expected error for invalid field")
+	}
+}
+
+func testBounds(end time.Time, windows ...time.Duration) links.TimeBounds {
+	result := make([]links.Window, 0, len(windows))
+	for _, duration := range windows {
+		result = append(result, links.Window{Name: duration.String(), Duration: duration})
+	}
+	return links.NewTimeBounds(end, result)
+}
+
+// Test whole hours are read from rolled up buckets, except hours containing the start of a window, or the end
+func TestSpans(t *testing.T) {
+	end := time.Date(2025, 3, 3, 12, 30, 0, 0, time.UTC)
+	result := spans(testBounds(end, time.Hour, 4*time.Hour))
+
+	hours := make([]time.Time, 0)
+	minutes := 0
+	for _, span := range result {
+		if span.Size == RollupSize {
+			hours = append(hours, span.Start)
+		} else {
+			minutes++
+		}
+	}
+
+	// 08:30 to 09:00 and 11:00 to 12:30 are read by the minute, as they contain the start of a window, or the end
+	expected := []time.Time{end.Add(-3*time.Hour - 30*time.Minute), end.Add(-2*time.Hour - 30*time.Minute)}
+	if !slices.Equal(hours, expected) {
+		t.Errorf("unexpected hours: %v", hours)
+	}
+	if minutes != 30+91 {
+		t.Errorf("expected %d minutes, got %d", 30+91, minutes)
+	}
+
+	// Spans are contiguous
+	for i := 1; i < len(result); i++ {
+		if !result[i].Start.Equal(result[i-1].Start.Add(result[i-1].Size)) {
+			t.Errorf("gap between %s and %s", result[i-1].Start, result[i].Start)
+		}
+	}
+}
+
+// Test minutes within whole hours are rolled up into a single bucket
+func TestMemoryRollup(t *testing.T) {
+	end := time.Date(2025, 3, 3, 12, 30, 0, 0, time.UTC)
+	lv := NewMemoryWithClock(24*time.Hour, func() time.Time { return end })
+
+	url := "https://www.example.com"
+	for i, ts := range []time.Time{end.Add(-150 * time.Minute), end.Add(-130 * time.Minute), end.Add(-10 * time.Minute)} {
+		lv.Record(storage.EventRecord{Type: 2, URL: url, DID: fmt.Sprintf("did:plc:user%d", i), Timestamp: ts, Post: "at://post/1"})
+	}
+
+	buckets, _ := lv.Buckets(testBounds(end, time.Hour, 4*time.Hour))
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(buckets))
+	}
+	hour := buckets[0]
+	if hour.Size != RollupSize || !hour.Start.Equal(time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected rolled up bucket: %s, %s", hour.Start, hour.Size)
+	}
+	if hour.Links[url].Counts.Likes != 2 || hour.Links[url].Posts["at://post/1"] != 2 || !hour.Links[url].FirstSeen.Equal(end.Add(-150*time.Minute)) {
+		t.Errorf("unexpected rolled up link: %+v", hour.Links[url])
+	}
+	if buckets[1].Size != BucketSize || buckets[1].Links[url].Counts.Likes != 1 {
+		t.Errorf("unexpected bucket: %+v", buckets[1])
+	}
+}
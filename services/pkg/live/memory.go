@@ -0,0 +1,111 @@
+package live
+
+import (
+	"sync"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// Memory is an in-process live aggregation. It is only shared by workers in a single process, so is mostly useful for testing & replays.
+// Time is read from an injectable clock, so expiry can be tested deterministically.
+type Memory struct {
+	mu           sync.Mutex
+	now          func() time.Time
+	retention    time.Duration
+	buckets      map[int64]*Bucket    // Keyed by the Unix time of each minute
+	fingerprints map[string]time.Time // Expiry of each fingerprint, used to detect duplicates
+	sweptAt      time.Time
+}
+
+// NewMemory creates an in-memory live aggregation, keeping buckets for the given retention (i.e. the longest window).
+func NewMemory(retention time.Duration) *Memory {
+	return NewMemoryWithClock(retention, time.Now)
+}
+
+func NewMemoryWithClock(retention time.Duration, now func() time.Time) *Memory {
+	return &Memory{
+		now:          now,
+		retention:    retention,
+		buckets:      make(map[int64]*Bucket),
+		fingerprints: make(map[string]time.Time),
+		sweptAt:      now(),
+	}
+}
+
+// Record counts an event in the bucket for its minute, unless the same user has already interacted with the URL in the same way.
+func (m *Memory) Record(record storage.EventRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.sweptAt) >= BucketSize {
+		m.sweep(now)
+	}
+
+	fingerprint := dedupe.Fingerprint(record.URL, record.Type, dedupe.HashDID(record.DID))
+	if expiry, ok := m.fingerprints[fingerprint]; ok && now.Before(expiry) {
+		return nil
+	}
+	m.fingerprints[fingerprint] = now.Add(m.retention)
+
+	ts := record.Timestamp.UTC().Truncate(time.Second) // Matches the precision of Valkey & partials
+	minute := ts.Truncate(BucketSize)
+	bucket, ok := m.buckets[minute.Unix()]
+	if !ok {
+		bucket = newBucket(minute, BucketSize)
+		m.buckets[minute.Unix()] = bucket
+	}
+
+	link := bucket.link(record.URL)
+	link.Counts.Add(record.Type)
+	link.Posts[record.Post]++
+	if link.FirstSeen.IsZero() || ts.Before(link.FirstSeen) {
+		link.FirstSeen = ts
+	}
+	return nil
+}
+
+// Buckets returns every non-empty bucket within the bounds, in order. Whole hours are rolled up from their minutes, as they would be in Valkey.
+func (m *Memory) Buckets(bounds links.TimeBounds) ([]Bucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]Bucket, 0)
+	for _, span := range spans(bounds) {
+		// Copy the buckets, so they can be read while new events are recorded
+		copied := newBucket(span.Start, span.Size)
+		for minute := span.Start; minute.Before(span.Start.Add(span.Size)); minute = minute.Add(BucketSize) {
+			bucket, ok := m.buckets[minute.Unix()]
+			if !ok {
+				continue
+			}
+			for url, link := range bucket.Links {
+				copied.merge(url, link)
+			}
+		}
+		if len(copied.Links) > 0 {
+			result = append(result, *copied)
+		}
+	}
+	return result, nil
+}
+
+func (m *Memory) Close() {}
+
+// Remove buckets and fingerprints that are older than the retention period.
+func (m *Memory) sweep(now time.Time) {
+	for key, bucket := range m.buckets {
+		if !now.Before(bucket.Start.Add(m.retention + BucketSize)) {
+			delete(m.buckets, key)
+		}
+	}
+	for fingerprint, expiry := range m.fingerprints {
+		if !now.Before(expiry) {
+			delete(m.fingerprints, fingerprint)
+		}
+	}
+	m.sweptAt = now
+}
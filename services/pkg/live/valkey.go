@@ -0,0 +1,199 @@
+package live
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+	"github.com/georgemblack/blue-report/pkg/links"
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/util"
+	"github.com/valkey-io/valkey-go"
+)
+
+// Valkey stores the live aggregation in Valkey, so it can be updated by every intake instance and read by a separate process.
+//
+// Each minute is a hash, named 'live:bucket:<unix minute>', and each hour is rolled up into a hash named 'live:hour:<unix hour>'. Both have the following fields:
+//
+//	c<type>\n<url>    Number of interactions of each type with the URL
+//	p<url>\n<post>    Number of interactions with each post referencing the URL
+//	f<url>            Unix time of the first event referencing the URL
+//
+// Fingerprints of counted interactions are stored in a set for each hour, named 'live:fp:<unix hour>'.
+// Buckets and fingerprint sets expire once they are older than the retention period.
+type Valkey struct {
+	client    valkey.Client
+	retention time.Duration
+}
+
+// Checks the fingerprint against every set within the retention period, then records the interaction, atomically.
+// If the script fails, nothing is recorded, so the interaction can be retried.
+//
+//	KEYS: minute bucket, hour bucket, fingerprint set for the event's hour, older fingerprint sets
+//	ARGV: fingerprint, count field, post field, first seen field, first seen, minute expiry, hour expiry
+var recordScript = valkey.NewLuaScript(`
+for i = 3, #KEYS do
+	if redis.call('SISMEMBER', KEYS[i], ARGV[1]) == 1 then
+		return 0
+	end
+end
+for i = 1, 2 do
+	redis.call('HINCRBY', KEYS[i], ARGV[2], 1)
+	redis.call('HINCRBY', KEYS[i], ARGV[3], 1)
+	redis.call('HSETNX', KEYS[i], ARGV[4], ARGV[5])
+end
+redis.call('SADD', KEYS[3], ARGV[1])
+redis.call('EXPIREAT', KEYS[1], ARGV[6])
+redis.call('EXPIREAT', KEYS[2], ARGV[7])
+redis.call('EXPIREAT', KEYS[3], ARGV[7])
+return 1
+`)
+
+const fieldSeparator = "\n" // Neither URLs nor AT URIs contain newlines
+
+// New creates a live aggregation backed by Valkey, keeping buckets for the given retention (i.e. the longest window).
+func New(cfg config.Config, retention time.Duration) (Valkey, error) {
+	var tlsConfig *tls.Config // nil by default
+	if cfg.ValkeyTLSEnabled {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: false, // Validate the server's certificate
+		}
+	}
+
+	client, err := valkey.NewClient(valkey.ClientOption{
+		InitAddress: []string{cfg.ValkeyAddress},
+		TLSConfig:   tlsConfig,
+	})
+	if err != nil {
+		return Valkey{}, util.WrapErr("failed to create valkey client", err)
+	}
+
+	return Valkey{client: client, retention: retention}, nil
+}
+
+// Record counts an event in the buckets for its minute and hour, unless the same user has already interacted with the URL in the same way.
+func (v Valkey) Record(record storage.EventRecord) error {
+	ts := record.Timestamp.UTC()
+	minute := ts.Truncate(BucketSize)
+	hour := ts.Truncate(RollupSize)
+
+	keys := []string{bucketKey(minute), hourKey(hour)}
+	for fp := hour; !fp.Before(hour.Add(-v.retention)); fp = fp.Add(-RollupSize) {
+		keys = append(keys, fingerprintKey(fp))
+	}
+	args := []string{
+		util.Hash(dedupe.Fingerprint(record.URL, record.Type, dedupe.HashDID(record.DID))),
+		fmt.Sprintf("c%d%s%s", record.Type, fieldSeparator, record.URL),
+		fmt.Sprintf("p%s%s%s", record.URL, fieldSeparator, record.Post),
+		"f" + record.URL,
+		strconv.FormatInt(ts.Unix(), 10),
+		strconv.FormatInt(minute.Add(v.retention+BucketSize).Unix(), 10),
+		strconv.FormatInt(hour.Add(v.retention+RollupSize).Unix(), 10),
+	}
+
+	err := recordScript.Exec(context.Background(), v.client, keys, args).Error()
+	if err != nil {
+		return util.WrapErr("failed to record interaction", err)
+	}
+	return nil
+}
+
+// Buckets returns every non-empty bucket within the bounds, in order. Whole hours are read from rolled up buckets.
+func (v Valkey) Buckets(bounds links.TimeBounds) ([]Bucket, error) {
+	keys := spans(bounds)
+	cmds := make(valkey.Commands, 0, len(keys))
+	for _, span := range keys {
+		key := bucketKey(span.Start)
+		if span.Size == RollupSize {
+			key = hourKey(span.Start)
+		}
+		cmds = append(cmds, v.client.B().Hgetall().Key(key).Build())
+	}
+
+	result := make([]Bucket, 0)
+	for i, resp := range v.client.DoMulti(context.Background(), cmds...) {
+		fields, err := resp.AsStrMap()
+		if err != nil {
+			return nil, util.WrapErr("failed to read bucket", err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		bucket, err := parseBucket(keys[i].Start, keys[i].Size, fields)
+		if err != nil {
+			return nil, util.WrapErr("failed to parse bucket", err)
+		}
+		result = append(result, *bucket)
+	}
+	return result, nil
+}
+
+func (v Valkey) Close() {
+	v.client.Close()
+}
+
+func bucketKey(minute time.Time) string {
+	return fmt.Sprintf("live:bucket:%d", minute.Unix())
+}
+
+func hourKey(hour time.Time) string {
+	return fmt.Sprintf("live:hour:%d", hour.Unix())
+}
+
+func fingerprintKey(hour time.Time) string {
+	return fmt.Sprintf("live:fp:%d", hour.Unix())
+}
+
+func parseBucket(start time.Time, size time.Duration, fields map[string]string) (*Bucket, error) {
+	bucket := newBucket(start, size)
+	for field, value := range fields {
+		if field == "" {
+			continue
+		}
+		kind, rest := field[0], field[1:]
+
+		if kind == 'f' {
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, util.WrapErr("failed to parse first seen", err)
+			}
+			bucket.link(rest).FirstSeen = time.Unix(unix, 0).UTC()
+			continue
+		}
+
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, util.WrapErr("failed to parse count", err)
+		}
+		first, second, ok := strings.Cut(rest, fieldSeparator)
+		if !ok {
+			return nil, fmt.Errorf("invalid field: %q", field)
+		}
+
+		switch kind {
+		case 'c':
+			eventType, err := strconv.Atoi(first)
+			if err != nil {
+				return nil, util.WrapErr("failed to parse event type", err)
+			}
+			link := bucket.link(second)
+			switch eventType {
+			case 0:
+				link.Counts.Posts += count
+			case 1:
+				link.Counts.Reposts += count
+			case 2:
+				link.Counts.Likes += count
+			}
+		case 'p':
+			bucket.link(first).Posts[second] += count
+		}
+	}
+	return bucket, nil
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/georgemblack/blue-report/pkg/util"
@@ -14,6 +16,7 @@ import (
 
 const MaxDocumentSize = 5 << 20 // 5 MB
 const Prompt = "Generate a title that summarizes the contents of this document. Your response should only contain the text of the title, and nothing else. Don't wrap it in quotes or any other formatting."
+const ClassifyPrompt = "Classify the following link into one of these categories: %s. Your response should only contain the name of the category, in lowercase, and nothing else.\n\nURL: %s\nTitle: %s"
 
 // GetDocumentTitle generates a title for a PDF document using OpenAI APIs.
 func GetDocumentTitle(apiKey string, reader io.Reader) (string, error) {
@@ -63,8 +66,35 @@ func GetDocumentTitle(apiKey string, reader io.Reader) (string, error) {
 		return "", util.WrapErr("failed to decode response", err)
 	}
 
-	// Build chat completions request
-	client = http.Client{
+	return complete(apiKey, []map[string]interface{}{
+		{
+			"type": "file",
+			"file": map[string]string{
+				"file_id": fileRespBody.ID,
+			},
+		},
+		{
+			"type": "text",
+			"text": Prompt,
+		},
+	})
+}
+
+// ClassifyLink asks the LLM to pick the category that best describes a link, given its URL and title.
+// The response is returned as-is, so callers should validate it against the given categories.
+func ClassifyLink(apiKey string, url string, title string, categories []string) (string, error) {
+	prompt := fmt.Sprintf(ClassifyPrompt, strings.Join(categories, ", "), url, title)
+	return complete(apiKey, []map[string]interface{}{
+		{
+			"type": "text",
+			"text": prompt,
+		},
+	})
+}
+
+// Send a single user message to the chat completions API, returning the content of the first choice.
+func complete(apiKey string, content []map[string]interface{}) (string, error) {
+	client := http.Client{
 		Timeout: 10 * time.Second,
 	}
 
@@ -72,19 +102,8 @@ func GetDocumentTitle(apiKey string, reader io.Reader) (string, error) {
 		"model": "gpt-4o-mini",
 		"messages": []map[string]interface{}{
 			{
-				"role": "user",
-				"content": []map[string]interface{}{
-					{
-						"type": "file",
-						"file": map[string]string{
-							"file_id": fileRespBody.ID,
-						},
-					},
-					{
-						"type": "text",
-						"text": Prompt,
-					},
-				},
+				"role":    "user",
+				"content": content,
 			},
 		},
 	}
@@ -109,7 +128,7 @@ func GetDocumentTitle(apiKey string, reader io.Reader) (string, error) {
 		return "", errors.New("failed to get completions: status code " + complResp.Status)
 	}
 
-	// Parse response and extract title
+	// Parse response and extract content
 	var complRespBody CompletionsResponse
 	if err := json.NewDecoder(complResp.Body).Decode(&complRespBody); err != nil {
 		return "", util.WrapErr("failed to decode response", err)
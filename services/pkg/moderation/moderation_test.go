@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/storage"
+)
+
+// Test that each type of rule matches the expected URLs
+func TestBlocklistMatch(t *testing.T) {
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	rules := []storage.BlockRule{
+		{ID: "host", Type: HostRule, Value: "example.com"},
+		{ID: "suffix", Type: HostSuffixRule, Value: "spam.net"},
+		{ID: "path", Type: PathRule, Value: `^/promo/`},
+		{ID: "url", Type: URLRule, Value: "https://news.org/a"},
+		{ID: "expired", Type: HostRule, Value: "old.com", ExpiresAt: now.Add(-time.Hour)},
+		{ID: "active", Type: HostRule, Value: "new.com", ExpiresAt: now.Add(time.Hour)},
+		{ID: "invalid", Type: PathRule, Value: `(`},
+	}
+	blocklist, errs := NewBlocklist(rules)
+	if len(errs) != 1 {
+		t.Errorf("expected 1 invalid rule, got %v", errs)
+	}
+
+	tests := []struct {
+		url  string
+		rule string
+	}{
+		{"https://example.com/a", "host"},
+		{"https://www.example.com/a", "host"},
+		{"https://sub.example.com/a", ""},
+		{"https://spam.net/a", "suffix"},
+		{"https://a.b.spam.net/a", "suffix"},
+		{"https://notspam.net/a", ""},
+		{"https://shop.com/promo/a", "path"},
+		{"https://shop.com/a/promo/", ""},
+		{"https://news.org/a", "url"},
+		{"https://news.org/b", ""},
+		{"https://old.com/a", ""},
+		{"https://new.com/a", "active"},
+	}
+	for _, test := range tests {
+		rule, ok := blocklist.Match(test.url, now)
+		if ok != (test.rule != "") || rule.ID != test.rule {
+			t.Errorf("unexpected match for %s: %v (%v)", test.url, rule, ok)
+		}
+	}
+}
+
+// Test that rules are validated and normalized when created
+func TestNewRule(t *testing.T) {
+	rule, err := NewRule(HostRule, "WWW.Example.com", "spam", time.Time{})
+	if err != nil || rule.Value != "example.com" || rule.ID != RuleID(HostRule, "example.com") {
+		t.Errorf("unexpected rule: %v (%v)", rule, err)
+	}
+
+	if _, err := NewRule("domain", "example.com", "spam", time.Time{}); err == nil {
+		t.Error("expected error for unknown type")
+	}
+	if _, err := NewRule(PathRule, "(", "spam", time.Time{}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+	if _, err := NewRule(HostRule, "example.com", "", time.Time{}); err == nil {
+		t.Error("expected error for missing reason")
+	}
+}
+
+// memoryRules stores rules in memory, in place of storage
+type memoryRules struct {
+	rules []storage.BlockRule
+}
+
+func (m *memoryRules) GetBlockRules() ([]storage.BlockRule, error) {
+	return m.rules, nil
+}
+
+func (m *memoryRules) SaveBlockRules(rules []storage.BlockRule) error {
+	m.rules = rules
+	return nil
+}
+
+// Test that the default rules apply until the blocklist is saved, and that edits are picked up on refresh
+func TestStore(t *testing.T) {
+	st := &memoryRules{}
+	store, err := NewStore(st, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, ok := store.Match("https://media.tenor.com/a.mp4"); !ok {
+		t.Error("expected default rule to match")
+	}
+
+	rule, _ := NewRule(HostSuffixRule, "example.com", "spam", time.Time{})
+	if err := AddRule(st, rule); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.rules) != len(DefaultRules())+1 {
+		t.Errorf("expected default rules to be saved with the new rule, got %d", len(st.rules))
+	}
+	if _, ok := store.Match("https://a.example.com"); ok {
+		t.Error("expected rule to not apply before refresh")
+	}
+
+	store.Refresh()
+	if matched, ok := store.Match("https://a.example.com"); !ok || matched.ID != rule.ID {
+		t.Errorf("unexpected match: %v (%v)", matched, ok)
+	}
+
+	if err := RemoveRule(st, rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveRule(st, rule.ID); err == nil {
+		t.Error("expected error removing missing rule")
+	}
+	store.Refresh()
+	if _, ok := store.Match("https://a.example.com"); ok {
+		t.Error("expected removed rule to not match")
+	}
+}
@@ -0,0 +1,149 @@
+package moderation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// Types of rules
+const (
+	HostRule       = "host"        // Matches the URL's host exactly, ignoring 'www.'
+	HostSuffixRule = "host_suffix" // Matches the host and all of its subdomains
+	PathRule       = "path"        // Matches the URL's path against a regular expression
+	URLRule        = "url"         // Matches the URL exactly
+)
+
+var ruleTypes = []string{HostRule, HostSuffixRule, PathRule, URLRule}
+
+// DefaultRules are used until a blocklist has been saved to storage.
+// These were previously hard-coded, so they are kept as the starting point for the blocklist.
+func DefaultRules() []storage.BlockRule {
+	rules := []storage.BlockRule{
+		{Type: HostRule, Value: "media.tenor.com", Reason: "image host"},
+		{Type: HostRule, Value: "mesonet.agron.iastate.edu", Reason: "bot"},
+	}
+	for _, host := range []string{"beacons.ai", "yokubo.tv", "linktr.ee", "allmylinks.com", "onlyfans.com"} {
+		rules = append(rules, storage.BlockRule{Type: HostRule, Value: host, Reason: "generally shares explicit content"})
+	}
+	for i := range rules {
+		rules[i].ID = RuleID(rules[i].Type, rules[i].Value)
+	}
+	return rules
+}
+
+// RuleID identifies a rule by its type and value, so the same rule can't be added twice.
+func RuleID(ruleType, value string) string {
+	return util.Hash(ruleType + ":" + value)[:8]
+}
+
+// NewRule creates a rule, validating its type and value. A zero expiry means the rule never expires.
+func NewRule(ruleType, value, reason string, expiresAt time.Time) (storage.BlockRule, error) {
+	value = strings.TrimSpace(value)
+	if ruleType == HostRule || ruleType == HostSuffixRule {
+		value = strings.TrimPrefix(strings.ToLower(value), "www.")
+	}
+
+	rule := storage.BlockRule{
+		ID:        RuleID(ruleType, value),
+		Type:      ruleType,
+		Value:     value,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if _, err := compile(rule); err != nil {
+		return storage.BlockRule{}, err
+	}
+	if reason == "" {
+		return storage.BlockRule{}, fmt.Errorf("rule must have a reason")
+	}
+	return rule, nil
+}
+
+// Expired returns whether the rule has expired as of the given time.
+func Expired(rule storage.BlockRule, now time.Time) bool {
+	return !rule.ExpiresAt.IsZero() && !now.Before(rule.ExpiresAt)
+}
+
+// matcher is a compiled rule.
+type matcher struct {
+	rule    storage.BlockRule
+	pattern *regexp.Regexp // Only set for path rules
+}
+
+func compile(rule storage.BlockRule) (matcher, error) {
+	if !util.ContainsStr(ruleTypes, rule.Type) {
+		return matcher{}, fmt.Errorf("unknown rule type: %s", rule.Type)
+	}
+	if rule.Value == "" {
+		return matcher{}, fmt.Errorf("rule must have a value")
+	}
+
+	m := matcher{rule: rule}
+	if rule.Type == PathRule {
+		pattern, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return matcher{}, util.WrapErr("invalid path pattern", err)
+		}
+		m.pattern = pattern
+	}
+	return m, nil
+}
+
+func (m matcher) matches(raw string, parsed *url.URL) bool {
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	switch m.rule.Type {
+	case HostRule:
+		return host == m.rule.Value
+	case HostSuffixRule:
+		return host == m.rule.Value || strings.HasSuffix(host, "."+m.rule.Value)
+	case PathRule:
+		return m.pattern.MatchString(parsed.Path)
+	case URLRule:
+		return raw == m.rule.Value
+	}
+	return false
+}
+
+// Blocklist matches URLs against a fixed set of rules. Expired rules are skipped.
+type Blocklist struct {
+	matchers []matcher
+}
+
+// NewBlocklist compiles the given rules. Invalid rules are skipped, so a single bad rule can't disable the blocklist.
+func NewBlocklist(rules []storage.BlockRule) (Blocklist, []error) {
+	blocklist := Blocklist{matchers: make([]matcher, 0, len(rules))}
+	errs := make([]error, 0)
+	for _, rule := range rules {
+		m, err := compile(rule)
+		if err != nil {
+			errs = append(errs, util.WrapErr(fmt.Sprintf("invalid rule %s", rule.ID), err))
+			continue
+		}
+		blocklist.matchers = append(blocklist.matchers, m)
+	}
+	return blocklist, errs
+}
+
+// Match returns the first unexpired rule matching the URL, if any.
+func (b Blocklist) Match(raw string, now time.Time) (storage.BlockRule, bool) {
+	if len(b.matchers) == 0 {
+		return storage.BlockRule{}, false
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return storage.BlockRule{}, false
+	}
+	for _, m := range b.matchers {
+		if !Expired(m.rule, now) && m.matches(raw, parsed) {
+			return m.rule, true
+		}
+	}
+	return storage.BlockRule{}, false
+}
@@ -0,0 +1,124 @@
+package moderation
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/storage"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// RefreshInterval is how often the store reloads rules from storage, so edits take effect without a release.
+const RefreshInterval = 5 * time.Minute
+
+// RuleStorage reads and writes the rules in storage.
+type RuleStorage interface {
+	GetBlockRules() ([]storage.BlockRule, error)
+	SaveBlockRules(rules []storage.BlockRule) error
+}
+
+// Store keeps the blocklist loaded from storage, refreshing it in the background. Matching is thread safe.
+type Store struct {
+	mu        sync.RWMutex
+	storage   RuleStorage
+	blocklist Blocklist
+	now       func() time.Time
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewStore loads the rules from storage, and refreshes them every 'interval' until closed.
+// If loading fails at startup, return an error, as running without the blocklist could publish blocked links.
+func NewStore(st RuleStorage, interval time.Duration) (*Store, error) {
+	s := &Store{storage: st, now: time.Now, done: make(chan struct{})}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if err := s.Refresh(); err != nil {
+					slog.Warn(util.WrapErr("failed to refresh blocklist", err).Error())
+				}
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Refresh reloads the rules from storage. If no rules have been saved, the default rules are used.
+func (s *Store) Refresh() error {
+	rules, err := LoadRules(s.storage)
+	if err != nil {
+		return err
+	}
+	blocklist, errs := NewBlocklist(rules)
+	for _, err := range errs {
+		slog.Warn(err.Error())
+	}
+
+	s.mu.Lock()
+	s.blocklist = blocklist
+	s.mu.Unlock()
+	slog.Debug("loaded blocklist", "rules", len(blocklist.matchers))
+	return nil
+}
+
+// Match returns the rule blocking the URL, if any.
+func (s *Store) Match(url string) (storage.BlockRule, bool) {
+	s.mu.RLock()
+	blocklist := s.blocklist
+	s.mu.RUnlock()
+	return blocklist.Match(url, s.now())
+}
+
+func (s *Store) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// LoadRules reads the rules from storage, or the default rules if none have been saved.
+func LoadRules(st RuleStorage) ([]storage.BlockRule, error) {
+	rules, err := st.GetBlockRules()
+	if err != nil {
+		return nil, util.WrapErr("failed to get block rules", err)
+	}
+	if rules == nil {
+		return DefaultRules(), nil
+	}
+	return rules, nil
+}
+
+// AddRule saves a new rule, replacing any existing rule with the same type and value (i.e. to change its expiry).
+func AddRule(st RuleStorage, rule storage.BlockRule) error {
+	rules, err := LoadRules(st)
+	if err != nil {
+		return err
+	}
+	rules = slices.DeleteFunc(rules, func(r storage.BlockRule) bool { return r.ID == rule.ID })
+	rules = append(rules, rule)
+	return st.SaveBlockRules(rules)
+}
+
+// RemoveRule deletes the rule with the given ID. Expired rules are removed at the same time.
+func RemoveRule(st RuleStorage, id string) error {
+	rules, err := LoadRules(st)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(rules, func(r storage.BlockRule) bool { return r.ID == id }) {
+		return fmt.Errorf("rule not found: %s", id)
+	}
+
+	now := time.Now()
+	rules = slices.DeleteFunc(rules, func(r storage.BlockRule) bool { return r.ID == id || Expired(r, now) })
+	return st.SaveBlockRules(rules)
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+const maxMessages = 10 // Matches the maximum number of messages received from SQS at once
+
+// Local is a queue backed by a directory, with one file per message.
+// It allows intake and link redirect to run as separate processes without SQS.
+type Local struct {
+	dir     string
+	counter *atomic.Int64 // Orders messages sent within the same nanosecond
+}
+
+func NewLocal(cfg config.Config) (Local, error) {
+	dir := filepath.Join(cfg.LocalStorageDir, "queue", cfg.NoralizationQueueName)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return Local{}, util.WrapErr("failed to create queue directory", err)
+	}
+	return Local{dir: dir, counter: &atomic.Int64{}}, nil
+}
+
+func (q Local) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return util.WrapErr("failed to marshal message", err)
+	}
+
+	// Write to a hidden file first, so receivers never read partially written messages
+	name := fmt.Sprintf("%020d-%06d-%d.json", time.Now().UnixNano(), q.counter.Add(1)%1000000, os.Getpid())
+	tmp := filepath.Join(q.dir, "."+name)
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return util.WrapErr("failed to write message", err)
+	}
+	err = os.Rename(tmp, filepath.Join(q.dir, name))
+	if err != nil {
+		return util.WrapErr("failed to send message", err)
+	}
+
+	return nil
+}
+
+// Receive returns up to ten of the oldest messages, deleting them from the queue.
+// Unlike SQS, this does not wait for messages to arrive.
+func (q Local) Receive() ([]Message, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return []Message{}, util.WrapErr("failed to list messages", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+
+	result := make([]Message, 0, maxMessages)
+	for _, name := range names {
+		if len(result) >= maxMessages {
+			break
+		}
+
+		path := filepath.Join(q.dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Received by another process
+		}
+		if err != nil {
+			return []Message{}, util.WrapErr("failed to read message", err)
+		}
+		err = os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return []Message{}, util.WrapErr("failed to delete message", err)
+		}
+
+		msg := Message{}
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			return []Message{}, util.WrapErr("failed to unmarshal message", err)
+		}
+		result = append(result, msg)
+	}
+
+	return result, nil
+}
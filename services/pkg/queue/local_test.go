@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+)
+
+// Test that messages are received in order, at most ten at a time, and only once
+func TestLocalQueue(t *testing.T) {
+	q, err := NewLocal(config.Config{LocalStorageDir: t.TempDir(), NoralizationQueueName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 15; i++ {
+		q.Send(Message{URL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+
+	first, err := q.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 10 {
+		t.Fatalf("expected 10 messages, got %d", len(first))
+	}
+	if first[0].URL != "https://example.com/0" || first[9].URL != "https://example.com/9" {
+		t.Errorf("unexpected message order: %v", first)
+	}
+
+	second, _ := q.Receive()
+	if len(second) != 5 {
+		t.Errorf("expected 5 messages, got %d", len(second))
+	}
+
+	third, _ := q.Receive()
+	if len(third) != 0 {
+		t.Errorf("expected empty queue, got %d messages", len(third))
+	}
+}
@@ -1,7 +1,6 @@
 package sites
 
 import (
-	"fmt"
 	"hash/fnv"
 	"log/slog"
 	"net/url"
@@ -10,19 +9,16 @@ import (
 	"sync"
 	"sync/atomic"
 
-	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/georgemblack/blue-report/pkg/dedupe"
 )
 
-const EstimatedTotalEvents = 110000000 // 110 million. Estimate is used to create bloom filter used for duplicate detection.
-const DuplicatePrecision = 0.001       // 0.1% precision for duplicate detection
-const NumShards = 512                  // Number of shards to use for parallel processing
+const NumShards = 512 // Number of shards to use for parallel processing
 
 type Aggregation struct {
-	shards           []Shard
-	fingerprints     *bloom.BloomFilter
-	fingerprintsLock sync.Mutex
-	total            int64 // Number of events processed
-	skipped          int64 // Number of events skipped due to suspected duplicate
+	shards       []Shard
+	fingerprints dedupe.Filter // Detects duplicate url/event/did combinations
+	total        int64         // Number of events processed
+	skipped      int64         // Number of events skipped due to suspected duplicate
 }
 
 type Shard struct {
@@ -30,7 +26,7 @@ type Shard struct {
 	items map[string]*AggregationItem
 }
 
-func NewAggregation() Aggregation {
+func NewAggregation(fingerprints dedupe.Filter) Aggregation {
 	shards := make([]Shard, NumShards)
 	for i := range shards {
 		shards[i] = Shard{
@@ -41,7 +37,7 @@ func NewAggregation() Aggregation {
 
 	return Aggregation{
 		shards:       shards,
-		fingerprints: bloom.NewWithEstimates(EstimatedTotalEvents, DuplicatePrecision),
+		fingerprints: fingerprints,
 		total:        0,
 		skipped:      0,
 	}
@@ -65,6 +61,11 @@ func (a *Aggregation) Skipped() int64 {
 }
 
 func (a *Aggregation) CountEvent(eventType int, linkURL string, did string) {
+	a.CountInteraction(eventType, linkURL, dedupe.HashDID(did))
+}
+
+// CountInteraction counts an event where the user's DID has already been hashed (i.e. when merging partials).
+func (a *Aggregation) CountInteraction(eventType int, linkURL string, user uint64) {
 	// Fetch the domain from the URL
 	url, err := url.Parse(linkURL)
 	if err != nil {
@@ -81,15 +82,11 @@ func (a *Aggregation) CountEvent(eventType int, linkURL string, did string) {
 		return
 	}
 
-	// Use bloom filter to detect duplicates.
-	a.fingerprintsLock.Lock()
-	fingerprint := fmt.Sprintf("%s%d%s", linkURL, eventType, did)
-	if a.fingerprints.TestAndAddString(fingerprint) {
-		a.skipped++
-		a.fingerprintsLock.Unlock()
+	// Detect duplicate url/event/did combinations, so a single user can only count once towards a site per link.
+	if a.fingerprints.TestAndAdd(dedupe.Fingerprint(linkURL, eventType, user)) {
+		atomic.AddInt64(&a.skipped, 1)
 		return
 	}
-	a.fingerprintsLock.Unlock()
 
 	// Find the shard associated with the given host
 	shard := a.getShard(host)
@@ -98,12 +95,17 @@ func (a *Aggregation) CountEvent(eventType int, linkURL string, did string) {
 	if shard.items[host] == nil {
 		shard.items[host] = &AggregationItem{}
 	}
-	shard.items[host].CountEvent(eventType, linkURL, did)
+	shard.items[host].CountEvent(eventType, linkURL)
 	shard.lock.Unlock()
 
 	atomic.AddInt64(&a.total, 1)
 }
 
+// Deduplication describes the accuracy of duplicate detection, including the estimated number of events incorrectly skipped.
+func (a *Aggregation) Deduplication() dedupe.Stats {
+	return a.fingerprints.Stats()
+}
+
 func (a *Aggregation) TopSites(n int) []string {
 	// Convert map to slice
 	type kv struct {
@@ -24,7 +24,7 @@ func (a *AggregationItem) Get(url string) Counts {
 	return a.links[url]
 }
 
-func (a *AggregationItem) CountEvent(eventType int, linkURL string, did string) {
+func (a *AggregationItem) CountEvent(eventType int, linkURL string) {
 	if a.links == nil {
 		a.links = make(map[string]Counts)
 	}
@@ -1,6 +1,10 @@
 package sites
 
-import "time"
+import (
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/dedupe"
+)
 
 func NewSnapshot() Snapshot {
 	return Snapshot{
@@ -9,8 +13,9 @@ func NewSnapshot() Snapshot {
 }
 
 type Snapshot struct {
-	GeneratedAt string `json:"generated_at"`
-	Sites       []Site `json:"sites"`
+	GeneratedAt   string       `json:"generated_at"`
+	Deduplication dedupe.Stats `json:"deduplication"` // Accuracy of duplicate detection, i.e. how many events may have been incorrectly skipped
+	Sites         []Site       `json:"sites"`
 }
 
 type Site struct {
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// Kinds of snapshots stored in the archive
+const (
+	LinkSnapshot = "links"
+	SiteSnapshot = "sites"
+)
+
+const archiveTimestampFormat = "2006/01/02/15-04-05"
+
+// ArchivedSnapshot identifies a snapshot stored in the archive, i.e. 'snapshots/links/2025/03/03/12-00-00.json'.
+// Keys are partitioned by day, so snapshots around a given time can be listed cheaply.
+type ArchivedSnapshot struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"` // Time the snapshot was published
+}
+
+// Key returns the key of the snapshot in storage.
+func (s ArchivedSnapshot) Key() string {
+	return fmt.Sprintf("snapshots/%s/%s.json", s.Kind, s.Time.UTC().Format(archiveTimestampFormat))
+}
+
+// ParseArchivedSnapshot parses an archived snapshot from its key in storage.
+func ParseArchivedSnapshot(key string) (ArchivedSnapshot, error) {
+	parts := strings.SplitN(strings.TrimSuffix(key, ".json"), "/", 3)
+	if len(parts) != 3 || parts[0] != "snapshots" || !strings.HasSuffix(key, ".json") {
+		return ArchivedSnapshot{}, fmt.Errorf("invalid snapshot key: %s", key)
+	}
+
+	ts, err := time.Parse(archiveTimestampFormat, parts[2])
+	if err != nil {
+		return ArchivedSnapshot{}, fmt.Errorf("invalid snapshot timestamp: %s", key)
+	}
+	return ArchivedSnapshot{Kind: parts[1], Time: ts}, nil
+}
+
+// Return the key prefix for each day between 'start' and 'end', inclusive.
+func archivePrefixes(kind string, start, end time.Time) []string {
+	prefixes := make([]string, 0)
+	for current := start.UTC().Truncate(24 * time.Hour); !current.After(end); current = current.AddDate(0, 0, 1) {
+		prefixes = append(prefixes, fmt.Sprintf("snapshots/%s/%s/", kind, current.Format("2006/01/02")))
+	}
+	return prefixes
+}
+
+// Given a list of keys, return the snapshots published between 'start' and 'end' (inclusive), in chronological order.
+func filterSnapshots(keys []string, start, end time.Time) []ArchivedSnapshot {
+	snapshots := make([]ArchivedSnapshot, 0)
+	for _, key := range keys {
+		snapshot, err := ParseArchivedSnapshot(key)
+		if err != nil {
+			continue
+		}
+		if !snapshot.Time.Before(start) && !snapshot.Time.After(end) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	slices.SortFunc(snapshots, func(a, b ArchivedSnapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+	return snapshots
+}
+
+// Archive a published snapshot. Archived snapshots are kept alongside events, rather than in the public bucket.
+func (a AWS) archiveSnapshot(snapshot ArchivedSnapshot, data []byte) error {
+	_, err := a.s3.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:               aws.String(a.cfg.WriteEventsBucketName),
+		Key:                  aws.String(snapshot.Key()),
+		Body:                 bytes.NewReader(data),
+		ServerSideEncryption: "AES256",
+		ContentType:          aws.String("application/json"),
+	})
+	if err != nil {
+		return util.WrapErr("failed to put object", err)
+	}
+	return nil
+}
+
+// ListSnapshots lists the archived snapshots of the given kind published between 'start' and 'end', in chronological order.
+func (a AWS) ListSnapshots(kind string, start, end time.Time) ([]ArchivedSnapshot, error) {
+	keys := make([]string, 0)
+	for _, prefix := range archivePrefixes(kind, start, end) {
+		paginator := s3.NewListObjectsV2Paginator(a.s3, &s3.ListObjectsV2Input{
+			Bucket: aws.String(a.cfg.WriteEventsBucketName),
+			Prefix: aws.String(prefix),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.Background())
+			if err != nil {
+				return nil, util.WrapErr("failed to list objects", err)
+			}
+			for _, obj := range page.Contents {
+				keys = append(keys, *obj.Key)
+			}
+		}
+	}
+
+	return filterSnapshots(keys, start, end), nil
+}
+
+// ReadSnapshot reads an archived snapshot.
+func (a AWS) ReadSnapshot(snapshot ArchivedSnapshot) ([]byte, error) {
+	resp, err := a.s3.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(a.cfg.WriteEventsBucketName),
+		Key:    aws.String(snapshot.Key()),
+	})
+	if err != nil {
+		return nil, util.WrapErr("failed to get object", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, util.WrapErr("failed to read object", err)
+	}
+	return data, nil
+}
+
+func (l Local) archiveSnapshot(snapshot ArchivedSnapshot, data []byte) error {
+	return l.write(snapshot.Key(), data)
+}
+
+func (l Local) ListSnapshots(kind string, start, end time.Time) ([]ArchivedSnapshot, error) {
+	keys := make([]string, 0)
+	for _, prefix := range archivePrefixes(kind, start, end) {
+		entries, err := os.ReadDir(l.path(prefix))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, util.WrapErr("failed to list snapshots", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && !isTemp(entry.Name()) {
+				keys = append(keys, prefix+entry.Name())
+			}
+		}
+	}
+
+	return filterSnapshots(keys, start, end), nil
+}
+
+func (l Local) ReadSnapshot(snapshot ArchivedSnapshot) ([]byte, error) {
+	data, err := os.ReadFile(l.path(filepath.FromSlash(snapshot.Key())))
+	if err != nil {
+		return nil, util.WrapErr("failed to read snapshot", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchivedSnapshotKey(t *testing.T) {
+	snapshot := ArchivedSnapshot{Kind: LinkSnapshot, Time: time.Date(2025, 3, 3, 12, 30, 5, 0, time.UTC)}
+	if snapshot.Key() != "snapshots/links/2025/03/03/12-30-05.json" {
+		t.Errorf("unexpected key: %s", snapshot.Key())
+	}
+
+	parsed, err := ParseArchivedSnapshot(snapshot.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Kind != snapshot.Kind || !parsed.Time.Equal(snapshot.Time) {
+		t.Errorf("unexpected snapshot: %+v", parsed)
+	}
+
+	for _, key := range []string{"data/top-links.json", "snapshots/links/2025/03/03.json", "snapshots/links/2025/03/03/12-30-05.txt"} {
+		if _, err := ParseArchivedSnapshot(key); err == nil {
+			t.Errorf("expected error for key: %s", key)
+		}
+	}
+}
+
+// Test that publishing a snapshot also archives it
+func TestLocalPublishArchivesSnapshot(t *testing.T) {
+	local := newTestLocal(t)
+	start := time.Now().UTC()
+
+	err := local.PublishLinkSnapshot(LinkSnapshot, []byte(`{"top_links":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := local.ListSnapshots(LinkSnapshot, start.Add(-time.Second), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("expected 1 snapshot, got %v", snapshots)
+	}
+	data, err := local.ReadSnapshot(snapshots[0])
+	if err != nil || string(data) != `{"top_links":{}}` {
+		t.Errorf("unexpected snapshot: %s (%v)", data, err)
+	}
+
+	// Site snapshots are archived separately
+	sites, _ := local.ListSnapshots(SiteSnapshot, start.Add(-time.Second), time.Now().Add(time.Second))
+	if len(sites) != 0 {
+		t.Errorf("expected no site snapshots, got %v", sites)
+	}
+}
+
+// Test that a snapshot is still published if it cannot be archived
+func TestLocalPublishIgnoresArchiveError(t *testing.T) {
+	local := newTestLocal(t)
+
+	// A file in place of the archive's directory prevents snapshots from being archived
+	if err := os.WriteFile(filepath.Join(local.dir, "snapshots"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, publish := range []func() error{
+		func() error { return local.PublishLinkSnapshot(LinkSnapshot, []byte(`{"top_links":{}}`)) },
+		func() error { return local.PublishSiteSnapshot([]byte(`{"sites":[]}`)) },
+	} {
+		if err := publish(); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(local.dir, "data", "top-links.json")); err != nil {
+		t.Errorf("expected snapshot to be published: %s", err)
+	}
+}
+
+// Test that snapshots are listed across days, in order, within the requested range
+func TestLocalListSnapshots(t *testing.T) {
+	local := newTestLocal(t)
+	start := time.Date(2025, 3, 3, 22, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		err := local.archiveSnapshot(ArchivedSnapshot{Kind: LinkSnapshot, Time: start.Add(time.Duration(i) * time.Hour)}, []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := local.ListSnapshots(LinkSnapshot, start.Add(time.Hour), start.Add(4*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 4 {
+		t.Fatalf("expected 4 snapshots, got %v", snapshots)
+	}
+	for i, snapshot := range snapshots {
+		if !snapshot.Time.Equal(start.Add(time.Duration(i+1) * time.Hour)) {
+			t.Errorf("unexpected snapshot at %d: %s", i, snapshot.Time)
+		}
+	}
+
+	empty, err := local.ListSnapshots(LinkSnapshot, start.AddDate(0, 1, 0), start.AddDate(0, 1, 1))
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected no snapshots, got %v (%v)", empty, err)
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+const blocklistKey = "moderation/blocklist.json"
+
+// BlockRule excludes matching URLs from the report. See the 'moderation' package for how each type of rule is matched.
+type BlockRule struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`  // Either 'host', 'host_suffix', 'path', or 'url'
+	Value     string    `json:"value"` // Host, host suffix, path regex, or exact URL
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"` // Zero if the rule never expires
+}
+
+// GetBlockRules reads every moderation rule. If no rules have ever been saved, return nil.
+// Rules are stored as a single object alongside events, as there are few of them, and they are always read together.
+func (a AWS) GetBlockRules() ([]BlockRule, error) {
+	resp, err := a.s3.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(a.cfg.WriteEventsBucketName),
+		Key:    aws.String(blocklistKey),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, util.WrapErr("failed to get object", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, util.WrapErr("failed to read object", err)
+	}
+	return decodeBlockRules(data)
+}
+
+// SaveBlockRules replaces every moderation rule.
+func (a AWS) SaveBlockRules(rules []BlockRule) error {
+	data, err := encodeBlockRules(rules)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.s3.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:               aws.String(a.cfg.WriteEventsBucketName),
+		Key:                  aws.String(blocklistKey),
+		Body:                 bytes.NewReader(data),
+		ServerSideEncryption: "AES256",
+		ContentType:          aws.String("application/json"),
+	})
+	if err != nil {
+		return util.WrapErr("failed to put object", err)
+	}
+	return nil
+}
+
+func (l Local) GetBlockRules() ([]BlockRule, error) {
+	var rules []BlockRule
+	found, err := l.readJSON(blocklistKey, &rules)
+	if err != nil {
+		return nil, util.WrapErr("failed to read blocklist", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return rules, nil
+}
+
+func (l Local) SaveBlockRules(rules []BlockRule) error {
+	data, err := encodeBlockRules(rules)
+	if err != nil {
+		return err
+	}
+	return l.write(blocklistKey, data)
+}
+
+// Rules are always encoded as a list, even if empty, so an empty blocklist can be told apart from one never saved.
+func encodeBlockRules(rules []BlockRule) ([]byte, error) {
+	if rules == nil {
+		rules = []BlockRule{}
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return nil, util.WrapErr("failed to marshal blocklist", err)
+	}
+	return data, nil
+}
+
+func decodeBlockRules(data []byte) ([]BlockRule, error) {
+	rules := []BlockRule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, util.WrapErr("failed to unmarshal blocklist", err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const chunkTimestampFormat = "2006-01-02-15-04-05"
+
+var instancePattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ChunkKey identifies a chunk of events in storage.
+// Multiple intake instances (each with multiple workers) may flush chunks in the same second,
+// so the instance, worker, and a per-worker sequence number are included alongside the start time to prevent collisions.
+type ChunkKey struct {
+	Start    time.Time // Time the chunk was started
+	Instance string    // Intake instance that wrote the chunk
+	Worker   int       // Worker (within the instance) that wrote the chunk
+	Sequence int       // Number of chunks previously written by the worker
+}
+
+// String formats the chunk key as used in storage, i.e. '2021-08-01-12-00-00_intake-1_01_000001'.
+// Numbers are zero-padded so that keys sort in order.
+func (c ChunkKey) String() string {
+	start := c.Start.UTC().Format(chunkTimestampFormat)
+	if c.Instance == "" {
+		return start // Legacy key
+	}
+	return fmt.Sprintf("%s_%s_%02d_%06d", start, c.Instance, c.Worker, c.Sequence)
+}
+
+// ParseChunkKey parses a chunk key from its name in storage.
+// Both the current format ('<timestamp>_<instance>_<worker>_<sequence>') and legacy format ('<timestamp>') are accepted.
+func ParseChunkKey(name string) (ChunkKey, error) {
+	parts := strings.Split(name, "_")
+	if len(parts) != 1 && len(parts) != 4 {
+		return ChunkKey{}, fmt.Errorf("invalid chunk key: %s", name)
+	}
+
+	start, err := time.Parse(chunkTimestampFormat, parts[0])
+	if err != nil {
+		return ChunkKey{}, fmt.Errorf("invalid chunk timestamp: %s", name)
+	}
+	if len(parts) == 1 {
+		return ChunkKey{Start: start}, nil
+	}
+
+	worker, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return ChunkKey{}, fmt.Errorf("invalid chunk worker: %s", name)
+	}
+	sequence, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return ChunkKey{}, fmt.Errorf("invalid chunk sequence: %s", name)
+	}
+
+	return ChunkKey{
+		Start:    start,
+		Instance: parts[1],
+		Worker:   worker,
+		Sequence: sequence,
+	}, nil
+}
+
+// InstanceName converts an arbitrary identifier (such as a hostname) into one that is safe to use in a chunk key.
+func InstanceName(id string) string {
+	name := instancePattern.ReplaceAllString(strings.ToLower(id), "-")
+	return strings.Trim(name, "-")
+}
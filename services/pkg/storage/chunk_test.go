@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChunkKeyRoundTrip(t *testing.T) {
+	key := ChunkKey{
+		Start:    time.Date(2025, 3, 3, 12, 0, 5, 0, time.UTC),
+		Instance: "ip-10-0-1-23",
+		Worker:   2,
+		Sequence: 41,
+	}
+
+	name := key.String()
+	if name != "2025-03-03-12-00-05_ip-10-0-1-23_02_000041" {
+		t.Errorf("unexpected chunk name: %s", name)
+	}
+
+	parsed, err := ParseChunkKey(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != key {
+		t.Errorf("expected %v, got %v", key, parsed)
+	}
+}
+
+func TestParseLegacyChunkKey(t *testing.T) {
+	parsed, err := ParseChunkKey("2025-03-03-12-00-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Start.Equal(time.Date(2025, 3, 3, 12, 0, 5, 0, time.UTC)) {
+		t.Errorf("unexpected start: %s", parsed.Start)
+	}
+	if parsed.Instance != "" {
+		t.Errorf("unexpected instance: %s", parsed.Instance)
+	}
+	if parsed.String() != "2025-03-03-12-00-05" {
+		t.Errorf("unexpected chunk name: %s", parsed.String())
+	}
+}
+
+func TestParseInvalidChunkKey(t *testing.T) {
+	invalid := []string{"", "bogus", "2025-03-03-12-00-05_intake", "2025-03-03-12-00-05_intake_x_000001"}
+	for _, name := range invalid {
+		if _, err := ParseChunkKey(name); err == nil {
+			t.Errorf("expected error for chunk name '%s'", name)
+		}
+	}
+}
+
+func TestFilterChunks(t *testing.T) {
+	keys := []string{
+		"events/2025-03-03-12-00-05_intake-b_01_000002.msgpack.zst",
+		"events/2025-03-03-12-00-05_intake-a_01_000002.json",
+		"events/2025-03-03-11-00-00.json",
+		"events/2025-03-03-12-00-05_intake-a_02_000000.msgpack.zst",
+		"events/2025-03-01-00-00-00.json",                           // Before start
+		"events/2025-03-04-00-00-00_intake-a_01_000003.msgpack.zst", // After end
+		"events/bogus.json",
+	}
+	start := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 3, 3, 23, 0, 0, 0, time.UTC)
+
+	filtered := filterChunks(keys, start, end)
+	expected := []string{
+		"2025-03-03-11-00-00.json",
+		"2025-03-03-12-00-05_intake-a_01_000002.json",
+		"2025-03-03-12-00-05_intake-a_02_000000.msgpack.zst",
+		"2025-03-03-12-00-05_intake-b_01_000002.msgpack.zst",
+	}
+	if len(filtered) != len(expected) {
+		t.Fatalf("expected %d chunks, got %d", len(expected), len(filtered))
+	}
+	for i := range expected {
+		if filtered[i] != expected[i] {
+			t.Errorf("expected '%s', got '%s'", expected[i], filtered[i])
+		}
+	}
+}
+
+func TestInstanceName(t *testing.T) {
+	name := InstanceName("ip-10-0-1-23.us-west-2.compute.internal")
+	if name != "ip-10-0-1-23-us-west-2-compute-internal" {
+		t.Errorf("unexpected instance name: %s", name)
+	}
+	name = InstanceName("My_Laptop")
+	if name != "my-laptop" {
+		t.Errorf("unexpected instance name: %s", name)
+	}
+}
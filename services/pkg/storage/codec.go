@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/util"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes and decodes chunks of events for storage.
+// Chunks are always decoded based on their contents (see 'DecodeEvents'), so codecs can be changed without rewriting existing chunks.
+type Codec interface {
+	Name() string
+	Extension() string // File extension of chunks written by the codec
+	ContentType() string
+	Encode(events []EventRecord) ([]byte, error)
+	Decode(data []byte, sizeHint int) ([]EventRecord, error)
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	ColumnarCodec Codec = columnarCodec{}
+)
+
+var codecs = []Codec{ColumnarCodec, JSONCodec}
+
+// NewCodec returns the codec with the given name.
+func NewCodec(name string) (Codec, error) {
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown event codec: %s", name)
+}
+
+// DecodeEvents decodes a chunk of events, detecting the format from the chunk's header.
+func DecodeEvents(data []byte, sizeHint int) ([]EventRecord, error) {
+	if bytes.HasPrefix(data, columnarMagic) {
+		return ColumnarCodec.Decode(data, sizeHint)
+	}
+	return JSONCodec.Decode(data, sizeHint)
+}
+
+// Split a chunk's file name into its name and extension, i.e. 'abc.msgpack.zst' -> ('abc', '.msgpack.zst').
+func splitExtension(name string) (string, string) {
+	for _, codec := range codecs {
+		if strings.HasSuffix(name, codec.Extension()) {
+			return strings.TrimSuffix(name, codec.Extension()), codec.Extension()
+		}
+	}
+	return name, ""
+}
+
+// jsonCodec is the original chunk format, containing one JSON-encoded event per line.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Extension() string {
+	return ".json"
+}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Encode(events []EventRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		err := enc.Encode(event)
+		if err != nil {
+			return nil, util.WrapErr("failed to encode event", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (jsonCodec) Decode(data []byte, sizeHint int) ([]EventRecord, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	events := make([]EventRecord, 0, sizeHint)
+	for {
+		event := EventRecord{}
+		if err := dec.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, util.WrapErr("failed to decode event", err)
+		}
+		if event.Language == "" {
+			event.Language = LegacyLanguage
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// columnarMagic prefixes every chunk written by the columnar codec. It is followed by a single byte, the format version.
+var columnarMagic = []byte{'B', 'R', 'E', 'V'}
+
+// Versions of the columnar format. The version must be incremented whenever the set of columns changes, so chunks are decoded by the columns they were written with.
+const (
+	columnarOriginalVersion = 1 // Events with no language or labels
+	columnarLanguageVersion = 2 // Adds the language of each event
+	columnarLabelVersion    = 3 // Adds the labels of each event, omitted if no event in the chunk has labels
+	columnarVersion         = columnarLabelVersion
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// columnarCodec stores events column by column, with zstd compression.
+// URLs, DIDs, and post URIs repeat heavily within a chunk, so each is stored once in a dictionary and referenced by index.
+type columnarCodec struct{}
+
+type columnarChunk struct {
+	URLs       []string `msgpack:"u"`  // Dictionary of distinct URLs
+	DIDs       []string `msgpack:"d"`  // Dictionary of distinct DIDs
+	Posts      []string `msgpack:"p"`  // Dictionary of distinct post AT URIs
+	Types      []byte   `msgpack:"t"`  // Event type of each event
+	URLRefs    []uint32 `msgpack:"ur"` // Index into 'URLs' for each event
+	DIDRefs    []uint32 `msgpack:"dr"` // Index into 'DIDs' for each event
+	PostRefs   []uint32 `msgpack:"pr"` // Index into 'Posts' for each event
+	Timestamps []int64  `msgpack:"ts"` // Unix microseconds of each event, delta-encoded
+
+	// Languages were added in version 2. Chunks written before then have no language columns.
+	Languages    []string `msgpack:"l"`  // Dictionary of distinct languages
+	LanguageRefs []uint32 `msgpack:"lr"` // Index into 'Languages' for each event
+
+	// Labels were added in version 3. The columns are omitted if no event in the chunk has labels.
+	LabelSets []string `msgpack:"lb,omitempty"`  // Dictionary of distinct sets of labels, each joined by commas
+	LabelRefs []uint32 `msgpack:"lbr,omitempty"` // Index into 'LabelSets' for each event
+}
+
+// dictionary assigns a stable index to each distinct value.
+type dictionary struct {
+	values  []string
+	indexes map[string]uint32
+}
+
+func newDictionary() dictionary {
+	return dictionary{indexes: make(map[string]uint32)}
+}
+
+func (d *dictionary) ref(value string) uint32 {
+	if index, ok := d.indexes[value]; ok {
+		return index
+	}
+	index := uint32(len(d.values))
+	d.values = append(d.values, value)
+	d.indexes[value] = index
+	return index
+}
+
+func (columnarCodec) Name() string {
+	return "columnar"
+}
+
+func (columnarCodec) Extension() string {
+	return ".msgpack.zst"
+}
+
+func (columnarCodec) ContentType() string {
+	return "application/octet-stream"
+}
+
+func (columnarCodec) Encode(events []EventRecord) ([]byte, error) {
+	urls, dids, posts, languages, labelSets := newDictionary(), newDictionary(), newDictionary(), newDictionary(), newDictionary()
+	chunk := columnarChunk{
+		Types:        make([]byte, len(events)),
+		URLRefs:      make([]uint32, len(events)),
+		DIDRefs:      make([]uint32, len(events)),
+		PostRefs:     make([]uint32, len(events)),
+		Timestamps:   make([]int64, len(events)),
+		LanguageRefs: make([]uint32, len(events)),
+		LabelRefs:    make([]uint32, len(events)),
+	}
+	labelSets.ref("") // Unlabelled events always reference the first set
+
+	previous := int64(0)
+	for i, event := range events {
+		chunk.Types[i] = byte(event.Type)
+		chunk.URLRefs[i] = urls.ref(event.URL)
+		chunk.DIDRefs[i] = dids.ref(event.DID)
+		chunk.PostRefs[i] = posts.ref(event.Post)
+		chunk.LanguageRefs[i] = languages.ref(event.Language)
+		chunk.LabelRefs[i] = labelSets.ref(strings.Join(event.Labels, ","))
+
+		ts := event.Timestamp.UnixMicro()
+		chunk.Timestamps[i] = ts - previous
+		previous = ts
+	}
+	chunk.URLs = urls.values
+	chunk.DIDs = dids.values
+	chunk.Posts = posts.values
+	chunk.Languages = languages.values
+	chunk.LabelSets = labelSets.values
+	if len(labelSets.values) == 1 {
+		chunk.LabelSets, chunk.LabelRefs = nil, nil
+	}
+
+	data, err := msgpack.Marshal(chunk)
+	if err != nil {
+		return nil, util.WrapErr("failed to marshal chunk", err)
+	}
+
+	result := make([]byte, 0, len(columnarMagic)+1+len(data)/4)
+	result = append(result, columnarMagic...)
+	result = append(result, columnarVersion)
+	return zstdEncoder.EncodeAll(data, result), nil
+}
+
+func (columnarCodec) Decode(data []byte, sizeHint int) ([]EventRecord, error) {
+	if !bytes.HasPrefix(data, columnarMagic) || len(data) <= len(columnarMagic) {
+		return nil, errors.New("missing columnar chunk header")
+	}
+	version := data[len(columnarMagic)]
+	if version < columnarOriginalVersion || version > columnarVersion {
+		return nil, fmt.Errorf("unsupported columnar chunk version: %d", version)
+	}
+
+	decompressed, err := zstdDecoder.DecodeAll(data[len(columnarMagic)+1:], nil)
+	if err != nil {
+		return nil, util.WrapErr("failed to decompress chunk", err)
+	}
+
+	var chunk columnarChunk
+	err = msgpack.Unmarshal(decompressed, &chunk)
+	if err != nil {
+		return nil, util.WrapErr("failed to unmarshal chunk", err)
+	}
+
+	length := len(chunk.Types)
+	if len(chunk.URLRefs) != length || len(chunk.DIDRefs) != length || len(chunk.PostRefs) != length || len(chunk.Timestamps) != length {
+		return nil, errors.New("columns in chunk have mismatched lengths")
+	}
+	hasLanguages := version >= columnarLanguageVersion
+	if hasLanguages && len(chunk.LanguageRefs) != length {
+		return nil, errors.New("language column in chunk has mismatched length")
+	}
+	hasLabels := version >= columnarLabelVersion && len(chunk.LabelRefs) > 0
+	if hasLabels && len(chunk.LabelRefs) != length {
+		return nil, errors.New("label column in chunk has mismatched length")
+	}
+
+	events := make([]EventRecord, 0, max(sizeHint, length))
+	ts := int64(0)
+	for i := 0; i < length; i++ {
+		if int(chunk.URLRefs[i]) >= len(chunk.URLs) || int(chunk.DIDRefs[i]) >= len(chunk.DIDs) || int(chunk.PostRefs[i]) >= len(chunk.Posts) {
+			return nil, fmt.Errorf("invalid dictionary reference for event %d", i)
+		}
+
+		language := LegacyLanguage
+		if hasLanguages {
+			if int(chunk.LanguageRefs[i]) >= len(chunk.Languages) {
+				return nil, fmt.Errorf("invalid language reference for event %d", i)
+			}
+			language = chunk.Languages[chunk.LanguageRefs[i]]
+		}
+
+		var labels []string
+		if hasLabels {
+			if int(chunk.LabelRefs[i]) >= len(chunk.LabelSets) {
+				return nil, fmt.Errorf("invalid label reference for event %d", i)
+			}
+			if set := chunk.LabelSets[chunk.LabelRefs[i]]; set != "" {
+				labels = strings.Split(set, ",")
+			}
+		}
+
+		ts += chunk.Timestamps[i]
+		events = append(events, EventRecord{
+			Type:      int(chunk.Types[i]),
+			URL:       chunk.URLs[chunk.URLRefs[i]],
+			DID:       chunk.DIDs[chunk.DIDRefs[i]],
+			Timestamp: time.UnixMicro(ts).UTC(),
+			Post:      chunk.Posts[chunk.PostRefs[i]],
+			Language:  language,
+			Labels:    labels,
+		})
+	}
+
+	return events, nil
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func testEvents(n int) []EventRecord {
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	events := make([]EventRecord, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, EventRecord{
+			Type:      i % 3,
+			URL:       fmt.Sprintf("https://www.example.com/article-%d", i%50),
+			DID:       fmt.Sprintf("did:plc:user%d", i%400),
+			Timestamp: start.Add(time.Duration(i) * 1500 * time.Microsecond),
+			Post:      fmt.Sprintf("at://did:plc:user%d/app.bsky.feed.post/%d", i%50, i%50),
+			Language:  []string{"en", "ja"}[i%50%2],
+		})
+	}
+	return events
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	events := testEvents(1000)
+
+	for _, codec := range []Codec{JSONCodec, ColumnarCodec} {
+		data, err := codec.Encode(events)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Chunks should be decoded correctly, regardless of the codec that wrote them
+		decoded, err := DecodeEvents(data, len(events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != len(events) {
+			t.Fatalf("%s: expected %d events, got %d", codec.Name(), len(events), len(decoded))
+		}
+		for i := range events {
+			if decoded[i].Type != events[i].Type || decoded[i].URL != events[i].URL || decoded[i].DID != events[i].DID || decoded[i].Post != events[i].Post || decoded[i].Language != events[i].Language {
+				t.Fatalf("%s: unexpected event at index %d: %+v", codec.Name(), i, decoded[i])
+			}
+			if !decoded[i].Timestamp.Equal(events[i].Timestamp) {
+				t.Fatalf("%s: unexpected timestamp at index %d: %s", codec.Name(), i, decoded[i].Timestamp)
+			}
+		}
+	}
+}
+
+func TestCodecEmptyChunk(t *testing.T) {
+	data, err := ColumnarCodec.Encode([]EventRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeEvents(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 0 {
+		t.Errorf("expected no events, got %d", len(decoded))
+	}
+}
+
+// Test that labels are kept by both codecs, and that the columnar codec omits label columns when no event is labelled
+func TestCodecLabels(t *testing.T) {
+	events := testEvents(10)
+	events[3].Labels = []string{"porn", "spam"}
+	events[7].Labels = []string{"spam"}
+
+	for _, codec := range []Codec{JSONCodec, ColumnarCodec} {
+		data, _ := codec.Encode(events)
+		decoded, err := DecodeEvents(data, len(events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range events {
+			if !slices.Equal(decoded[i].Labels, events[i].Labels) {
+				t.Errorf("%s: unexpected labels at index %d: %v", codec.Name(), i, decoded[i].Labels)
+			}
+		}
+	}
+
+	data, _ := ColumnarCodec.Encode(testEvents(10))
+	decompressed, _ := zstdDecoder.DecodeAll(data[len(columnarMagic)+1:], nil)
+	var chunk columnarChunk
+	msgpack.Unmarshal(decompressed, &chunk)
+	if chunk.LabelSets != nil || chunk.LabelRefs != nil {
+		t.Errorf("expected no label columns, got %v", chunk.LabelSets)
+	}
+}
+
+// Test that events written before languages were recorded are decoded as English
+func TestCodecLegacyLanguage(t *testing.T) {
+	legacyJSON := []byte(`{"type":0,"url":"https://example.com","did":"did:plc:user","timestamp":"2025-03-03T12:00:00Z","post":"at://did:plc:user/app.bsky.feed.post/1"}` + "\n")
+
+	chunk, _ := msgpack.Marshal(columnarChunk{
+		URLs: []string{"https://example.com"}, DIDs: []string{"did:plc:user"}, Posts: []string{"at://did:plc:user/app.bsky.feed.post/1"},
+		Types: []byte{0}, URLRefs: []uint32{0}, DIDRefs: []uint32{0}, PostRefs: []uint32{0}, Timestamps: []int64{1741003200000000},
+	})
+	legacyColumnar := zstdEncoder.EncodeAll(chunk, append(append([]byte{}, columnarMagic...), columnarOriginalVersion))
+
+	for _, data := range [][]byte{legacyJSON, legacyColumnar} {
+		decoded, err := DecodeEvents(data, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != 1 || decoded[0].Language != LegacyLanguage {
+			t.Errorf("unexpected events: %+v", decoded)
+		}
+	}
+}
+
+// Test that chunks are decoded by the columns of the version they were written with
+func TestCodecVersions(t *testing.T) {
+	chunk, _ := msgpack.Marshal(columnarChunk{
+		URLs: []string{"https://example.com"}, DIDs: []string{"did:plc:user"}, Posts: []string{"at://did:plc:user/app.bsky.feed.post/1"},
+		Types: []byte{0}, URLRefs: []uint32{0}, DIDRefs: []uint32{0}, PostRefs: []uint32{0}, Timestamps: []int64{1741003200000000},
+		Languages: []string{"ja"}, LanguageRefs: []uint32{0}, LabelSets: []string{"", "spam"}, LabelRefs: []uint32{1},
+	})
+	encode := func(version byte) []byte {
+		return zstdEncoder.EncodeAll(chunk, append(append([]byte{}, columnarMagic...), version))
+	}
+
+	tests := []struct {
+		version  byte
+		language string
+		labels   []string
+	}{
+		{columnarOriginalVersion, LegacyLanguage, nil},
+		{columnarLanguageVersion, "ja", nil},
+		{columnarLabelVersion, "ja", []string{"spam"}},
+	}
+	for _, test := range tests {
+		decoded, err := DecodeEvents(encode(test.version), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != 1 || decoded[0].Language != test.language || !slices.Equal(decoded[0].Labels, test.labels) {
+			t.Errorf("unexpected events for version %d: %+v", test.version, decoded)
+		}
+	}
+
+	// Versions from the future cannot be decoded
+	if _, err := DecodeEvents(encode(columnarVersion+1), 1); err == nil {
+		t.Error("expected error for unknown version")
+	}
+
+	// Chunks written by the codec always carry the current version
+	data, _ := ColumnarCodec.Encode(testEvents(1))
+	if data[len(columnarMagic)] != columnarVersion {
+		t.Errorf("unexpected version: %d", data[len(columnarMagic)])
+	}
+}
+
+func TestColumnarCodecIsSmaller(t *testing.T) {
+	events := testEvents(10000)
+
+	jsonData, _ := JSONCodec.Encode(events)
+	columnarData, _ := ColumnarCodec.Encode(events)
+	if len(columnarData)*5 > len(jsonData) {
+		t.Errorf("expected columnar chunk to be at least 5x smaller, got %d bytes vs. %d bytes", len(columnarData), len(jsonData))
+	}
+}
+
+func TestColumnarCodecRejectsCorruptChunk(t *testing.T) {
+	data, _ := ColumnarCodec.Encode(testEvents(10))
+	_, err := DecodeEvents(data[:len(data)/2], 0)
+	if err == nil {
+		t.Error("expected error decoding truncated chunk")
+	}
+}
+
+func TestNewCodec(t *testing.T) {
+	codec, err := NewCodec("columnar")
+	if err != nil || codec.Name() != "columnar" {
+		t.Errorf("expected columnar codec, got %v (%v)", codec, err)
+	}
+	_, err = NewCodec("bogus")
+	if err == nil {
+		t.Error("expected error for unknown codec")
+	}
+}
+
+func TestSplitExtension(t *testing.T) {
+	name, ext := splitExtension("2025-03-03-12-00-05_intake_01_000001.msgpack.zst")
+	if name != "2025-03-03-12-00-05_intake_01_000001" || ext != ".msgpack.zst" {
+		t.Errorf("unexpected split: '%s', '%s'", name, ext)
+	}
+	name, ext = splitExtension("2025-03-03-12-00-05.json")
+	if name != "2025-03-03-12-00-05" || ext != ".json" {
+		t.Errorf("unexpected split: '%s', '%s'", name, ext)
+	}
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	data, _ := JSONCodec.Encode(testEvents(10000))
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		DecodeEvents(data, 10000)
+	}
+}
+
+func BenchmarkDecodeColumnar(b *testing.B) {
+	data, _ := ColumnarCodec.Encode(testEvents(10000))
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		DecodeEvents(data, 10000)
+	}
+}
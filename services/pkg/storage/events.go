@@ -3,8 +3,8 @@ package storage
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"slices"
 	"strings"
@@ -15,12 +15,17 @@ import (
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
+// LegacyLanguage is the language of events written before languages were recorded, when only English posts were counted.
+const LegacyLanguage = "en"
+
 type EventRecord struct {
 	Type      int       `json:"type"` // 0 = post, 1 = repost, 2 = like
 	URL       string    `json:"url"`
 	DID       string    `json:"did"`
 	Timestamp time.Time `json:"timestamp"`
-	Post      string    `json:"post"` // AT URI of the post that was created/liked/reposted
+	Post      string    `json:"post"`             // AT URI of the post that was created/liked/reposted
+	Language  string    `json:"language"`         // Language of the post. Likes and reposts inherit the language of the post they reference.
+	Labels    []string  `json:"labels,omitempty"` // Self-labels of the post (i.e. 'porn'). Likes and reposts inherit the labels of the post they reference.
 }
 
 func (s EventRecord) IsPost() bool {
@@ -35,52 +40,39 @@ func (s EventRecord) IsLike() bool {
 	return s.Type == 2
 }
 
+// ReadEvents reads a chunk of events from S3. The chunk may be in any format supported by a codec.
 func (a AWS) ReadEvents(key string, eventBufferSize int) ([]EventRecord, error) {
 	resp, err := a.s3.GetObject(context.Background(), &s3.GetObjectInput{
 		Bucket: aws.String(a.cfg.ReadEventsBucketName),
-		Key:    aws.String(fmt.Sprintf("events/%s.json", key)),
+		Key:    aws.String(fmt.Sprintf("events/%s", key)),
 	})
 	if err != nil {
 		return nil, util.WrapErr("failed to get object", err)
 	}
 	defer resp.Body.Close()
 
-	// Decode JSON lines
-	dec := json.NewDecoder(resp.Body)
-	events := make([]EventRecord, 0, eventBufferSize)
-	for {
-		event := EventRecord{}
-		if err := dec.Decode(&event); err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return nil, util.WrapErr("failed to decode event", err)
-		}
-		events = append(events, event)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, util.WrapErr("failed to read object", err)
 	}
 
-	return events, nil
+	return DecodeEvents(data, eventBufferSize)
 }
 
-func (a AWS) FlushEvents(start time.Time, events []EventRecord) error {
-	// Convert events to JSON lines
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	for _, event := range events {
-		err := enc.Encode(event)
-		if err != nil {
-			return util.WrapErr("failed to encode event", err)
-		}
+// FlushEvents writes a chunk of events to S3, using the configured codec.
+func (a AWS) FlushEvents(key ChunkKey, events []EventRecord) error {
+	data, err := a.codec.Encode(events)
+	if err != nil {
+		return util.WrapErr("failed to encode events", err)
 	}
 
-	// Write to S3, with timestamp in key
-	key := fmt.Sprintf("events/%s.json", start.UTC().Format("2006-01-02-15-04-05"))
-	_, err := a.s3.PutObject(context.Background(), &s3.PutObjectInput{
+	// Write to S3, with timestamp, instance, worker, and sequence number in key
+	_, err = a.s3.PutObject(context.Background(), &s3.PutObjectInput{
 		Bucket:               aws.String(a.cfg.WriteEventsBucketName),
-		Key:                  aws.String(key),
-		Body:                 bytes.NewReader(buf.Bytes()),
+		Key:                  aws.String(fmt.Sprintf("events/%s%s", key, a.codec.Extension())),
+		Body:                 bytes.NewReader(data),
 		ServerSideEncryption: "AES256",
-		ContentType:          aws.String("application/json"),
+		ContentType:          aws.String(a.codec.ContentType()),
 	})
 	if err != nil {
 		return util.WrapErr("failed to put object", err)
@@ -90,14 +82,15 @@ func (a AWS) FlushEvents(start time.Time, events []EventRecord) error {
 }
 
 // ListEventChunks lists all S3 object keys containing events after a certain time.
-// Objects are named 'events/<timestamp>.json'.
+// Objects are named 'events/<timestamp>_<instance>_<worker>_<sequence><extension>', or 'events/<timestamp>.json' for legacy chunks.
+// The extension depends on the codec used to write the chunk.
 func (a AWS) ListEventChunks(start, end time.Time) ([]string, error) {
 	keys := make([]string, 0)
 
 	// List objects using a list of prefixes, one for each day between 'start' and 'end', inclusive.
 	// By using prefixes, we reduce the amount of 'LIST' operations, which can be costly for objects in archival storage classes.
 	prefixes := make([]string, 0)
-	current := start
+	current := start.UTC().Truncate(24 * time.Hour)
 	for !current.After(end) {
 		prefixes = append(prefixes, fmt.Sprintf("events/%s", current.Format("2006-01-02")))
 		current = current.AddDate(0, 0, 1)
@@ -122,23 +115,35 @@ func (a AWS) ListEventChunks(start, end time.Time) ([]string, error) {
 		}
 	}
 
-	// Filter keys to only include those after the 'start' time, and before the 'end' time.
+	return filterChunks(keys, start, end), nil
+}
+
+// Given a list of object keys, return the names of chunks started after the 'start' time, and before the 'end' time.
+// i.e. 'events/2021-08-01-12-00-00_intake-1_01_000001.msgpack.zst' -> '2021-08-01-12-00-00_intake-1_01_000001.msgpack.zst'
+func filterChunks(keys []string, start, end time.Time) []string {
 	filtered := make([]string, 0)
-	startStr := start.UTC().Format("2006-01-02-15-04-05")
-	endStr := end.UTC().Format("2006-01-02-15-04-05")
 	for _, key := range keys {
-		// Parse timestamp from key, i.e. 'events/2021-08-01-12-00-00.json' -> '2021-08-01-12-00-00'
-		key = strings.TrimPrefix(key, "events/")
-		key = strings.TrimSuffix(key, ".json")
+		name := strings.TrimPrefix(key, "events/")
+		base, _ := splitExtension(name)
+
+		chunk, err := ParseChunkKey(base)
+		if err != nil {
+			slog.Warn(util.WrapErr("skipping unrecognized chunk", err).Error(), "key", key)
+			continue
+		}
 
-		// Compare strings with timestamps
-		if key > startStr && key < endStr {
-			filtered = append(filtered, key)
+		if chunk.Start.After(start) && chunk.Start.Before(end) {
+			filtered = append(filtered, name)
 		}
 	}
 
+	// Chunk names begin with a timestamp, so sorting them sorts chunks chronologically
 	slices.Sort(filtered)
 
-	slog.Info("discovered chunks", "count", len(filtered), "first", keys[0], "last", keys[len(keys)-1])
-	return filtered, nil
+	if len(filtered) > 0 {
+		slog.Info("discovered chunks", "count", len(filtered), "first", filtered[0], "last", filtered[len(filtered)-1])
+	} else {
+		slog.Info("discovered chunks", "count", 0)
+	}
+	return filtered
 }
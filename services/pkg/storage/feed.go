@@ -35,6 +35,11 @@ type FeedEntryPost struct {
 	Text     string `json:"text"`
 }
 
+// Expired determines whether a feed entry is older than 90 days, and should be removed.
+func (e FeedEntry) Expired() bool {
+	return time.Since(e.Timestamp) > 90*24*time.Hour
+}
+
 // AddFeedEntry creates a new entry in the DynamoDB 'feed' table.
 // If the entry already exists, we do not want to modify it.
 func (a AWS) AddFeedEntry(entry FeedEntry) error {
@@ -106,13 +111,7 @@ func (a AWS) RecentFeedEntry() bool {
 		return false
 	}
 
-	for _, entry := range entries {
-		if time.Since(entry.Timestamp) < 12*time.Hour {
-			return true
-		}
-	}
-
-	return false
+	return hasRecentEntry(entries)
 }
 
 func (a AWS) PublishFeeds(atom, json string) error {
@@ -149,7 +148,7 @@ func (a AWS) CleanFeed() error {
 	}
 
 	for _, entry := range entries {
-		if time.Since(entry.Timestamp) > 90*24*time.Hour {
+		if entry.Expired() {
 			hashedURL := util.Hash(entry.Content.URL)
 			_, err := a.dynamoDB.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
 				TableName: aws.String(a.cfg.FeedTableName),
@@ -165,3 +164,12 @@ func (a AWS) CleanFeed() error {
 
 	return nil
 }
+
+func hasRecentEntry(entries []FeedEntry) bool {
+	for _, entry := range entries {
+		if time.Since(entry.Timestamp) < 12*time.Hour {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// Return the key of an integrity report, i.e. 'integrity/2025/03/03/12-00-00.json'.
+func integrityReportKey(ts time.Time) string {
+	return fmt.Sprintf("integrity/%s.json", ts.UTC().Format(archiveTimestampFormat))
+}
+
+// SaveIntegrityReport saves a report of the accounts flagged by an integrity check.
+// Reports identify individual accounts, so they are kept alongside events, rather than in the public bucket.
+func (a AWS) SaveIntegrityReport(report []byte) error {
+	_, err := a.s3.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:               aws.String(a.cfg.WriteEventsBucketName),
+		Key:                  aws.String(integrityReportKey(time.Now())),
+		Body:                 bytes.NewReader(report),
+		ServerSideEncryption: "AES256",
+		ContentType:          aws.String("application/json"),
+	})
+	if err != nil {
+		return util.WrapErr("failed to put object", err)
+	}
+	return nil
+}
+
+func (l Local) SaveIntegrityReport(report []byte) error {
+	return l.write(integrityReportKey(time.Now()), report)
+}
@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// Local stores all data as files under a single directory, mirroring the layout of the S3/R2 buckets:
+//
+//	events/<chunk><extension>    Event chunks
+//	partials/v<version>/<chunk>  Partial aggregates of each chunk
+//	data/top-<kind>.json         Published snapshots, i.e. 'top-links.json' or 'top-links.ja.json'
+//	snapshots/<kind>/<date>/...  Archive of every published snapshot
+//	integrity/<date>/...         Reports of accounts flagged by integrity checks
+//	moderation/blocklist.json    Rules excluding URLs from the report
+//	thumbnails/<id>.<extension>  Thumbnail images
+//	metadata/<hash>.json         URL metadata (i.e. titles)
+//	translations/<month>.jsonl   URL translations, appended as they are saved
+//	feed/<hash>.json             Feed entries
+//	feeds/top-day.xml            Published feeds
+//
+// This allows the entire pipeline to run without AWS, i.e. on a laptop or in CI.
+type Local struct {
+	dir   string
+	codec Codec       // Used to encode event chunks
+	mu    *sync.Mutex // Guards appends to translation files
+}
+
+type localTranslation struct {
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	Source      string        `json:"sourceUrl"`
+	Destination string        `json:"destinationUrl"`
+	Outcome     string        `json:"outcome,omitempty"`
+	Canonical   string        `json:"canonicalUrl,omitempty"`
+	Chain       []RedirectHop `json:"redirectChain,omitempty"`
+}
+
+func NewLocal(cfg config.Config) (Local, error) {
+	codec, err := NewCodec(cfg.EventCodec)
+	if err != nil {
+		return Local{}, err
+	}
+
+	err = os.MkdirAll(cfg.LocalStorageDir, 0755)
+	if err != nil {
+		return Local{}, util.WrapErr("failed to create storage directory", err)
+	}
+
+	return Local{dir: cfg.LocalStorageDir, codec: codec, mu: &sync.Mutex{}}, nil
+}
+
+func (l Local) PublishLinkSnapshot(kind string, snapshot []byte) error {
+	err := l.write(fmt.Sprintf("data/top-%s.json", kind), snapshot)
+	if err != nil {
+		return err
+	}
+	err = l.archiveSnapshot(ArchivedSnapshot{Kind: kind, Time: time.Now().UTC()}, snapshot)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to archive snapshot", err).Error(), "kind", kind)
+	}
+	return nil
+}
+
+func (l Local) PublishSiteSnapshot(snapshot []byte) error {
+	err := l.write("data/top-sites.json", snapshot)
+	if err != nil {
+		return err
+	}
+	err = l.archiveSnapshot(ArchivedSnapshot{Kind: SiteSnapshot, Time: time.Now().UTC()}, snapshot)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to archive snapshot", err).Error(), "kind", SiteSnapshot)
+	}
+	return nil
+}
+
+func (l Local) ReadEvents(key string, eventBufferSize int) ([]EventRecord, error) {
+	data, err := os.ReadFile(l.path("events", key))
+	if err != nil {
+		return nil, util.WrapErr("failed to read chunk", err)
+	}
+	return DecodeEvents(data, eventBufferSize)
+}
+
+func (l Local) FlushEvents(key ChunkKey, events []EventRecord) error {
+	data, err := l.codec.Encode(events)
+	if err != nil {
+		return util.WrapErr("failed to encode events", err)
+	}
+	return l.write(fmt.Sprintf("events/%s%s", key, l.codec.Extension()), data)
+}
+
+func (l Local) ListEventChunks(start, end time.Time) ([]string, error) {
+	entries, err := os.ReadDir(l.path("events"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, util.WrapErr("failed to list chunks", err)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !isTemp(entry.Name()) {
+			keys = append(keys, "events/"+entry.Name())
+		}
+	}
+	return filterChunks(keys, start, end), nil
+}
+
+// SaveThumbnail fetches an image at a given URL and stores it on disk.
+// A 'file://' URL pointing to the image is returned.
+func (l Local) SaveThumbnail(id string, imageURL string) (string, error) {
+	image, mimeType, err := fetchImage(imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("thumbnails/%s.%s", id, extension(mimeType))
+	err = l.write(name, image)
+	if err != nil {
+		return "", err
+	}
+	return l.fileURL(name)
+}
+
+func (l Local) GetThumbnailURL(id string) (string, error) {
+	for _, ext := range OpenGraphImageExtensions {
+		name := fmt.Sprintf("thumbnails/%s.%s", id, ext)
+		exists, err := l.exists(name)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return l.fileURL(name)
+		}
+	}
+
+	return "", nil
+}
+
+func (l Local) GetURLMetadata(url string) (URLMetadata, error) {
+	var metadata URLMetadata
+	found, err := l.readJSON(fmt.Sprintf("metadata/%s.json", util.Hash(url)), &metadata)
+	if err != nil {
+		return URLMetadata{}, util.WrapErr("failed to read url metadata", err)
+	}
+	if !found {
+		return URLMetadata{}, nil
+	}
+	return metadata, nil
+}
+
+func (l Local) SaveURLMetadata(metadata URLMetadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return util.WrapErr("failed to marshal url metadata", err)
+	}
+	return l.write(fmt.Sprintf("metadata/%s.json", util.Hash(metadata.URL)), data)
+}
+
+// SaveURLTranslation appends the translation to a file for the current month.
+// Like the DynamoDB table, only translations from the current & previous month are read.
+func (l Local) SaveURLTranslation(translation URLTranslation) error {
+	now := time.Now().UTC()
+	data, err := json.Marshal(localTranslation{
+		UpdatedAt:   now,
+		Source:      translation.Source,
+		Destination: translation.Destination,
+		Outcome:     translation.Outcome,
+		Canonical:   translation.Canonical,
+		Chain:       translation.Chain,
+	})
+	if err != nil {
+		return util.WrapErr("failed to marshal url translation", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	path := l.path("translations", now.Format("2006-01")+".jsonl")
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return util.WrapErr("failed to create directory", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return util.WrapErr("failed to open translations file", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		return util.WrapErr("failed to write url translation", err)
+	}
+	return nil
+}
+
+func (l Local) GetURLTranslations() (map[string]string, error) {
+	now := time.Now().UTC()
+	thisMonth := now.Format("2006-01")
+	lastMonth := now.AddDate(0, -1, 0).Format("2006-01")
+
+	translations := make(map[string]string)
+
+	// Read the previous month first, so newer translations take precedence
+	for _, month := range []string{lastMonth, thisMonth} {
+		data, err := os.ReadFile(l.path("translations", month+".jsonl"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, util.WrapErr("failed to read translations file", err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var translation localTranslation
+			if err := json.Unmarshal(scanner.Bytes(), &translation); err != nil {
+				return nil, util.WrapErr("failed to unmarshal url translation", err)
+			}
+			translations[translation.Source] = translation.Destination
+		}
+	}
+
+	return translations, nil
+}
+
+// AddFeedEntry saves a new feed entry. If the entry already exists, we do not want to modify it.
+func (l Local) AddFeedEntry(entry FeedEntry) error {
+	name := fmt.Sprintf("feed/%s.json", util.Hash(entry.Content.URL))
+	exists, err := l.exists(name)
+	if err != nil || exists {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return util.WrapErr("failed to marshal feed entry", err)
+	}
+	return l.write(name, data)
+}
+
+func (l Local) GetFeedEntries() ([]FeedEntry, error) {
+	files, err := os.ReadDir(l.path("feed"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []FeedEntry{}, nil
+	}
+	if err != nil {
+		return nil, util.WrapErr("failed to list feed entries", err)
+	}
+
+	entries := make([]FeedEntry, 0, len(files))
+	for _, file := range files {
+		if isTemp(file.Name()) {
+			continue
+		}
+		var entry FeedEntry
+		_, err := l.readJSON("feed/"+file.Name(), &entry)
+		if err != nil {
+			return nil, util.WrapErr("failed to read feed entry", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (l Local) RecentFeedEntry() bool {
+	entries, err := l.GetFeedEntries()
+	if err != nil {
+		slog.Error("failed to get feed entries", "error", err)
+		return false
+	}
+	return hasRecentEntry(entries)
+}
+
+func (l Local) PublishFeeds(atom, json string) error {
+	err := l.write("feeds/top-day.xml", []byte(atom))
+	if err != nil {
+		return util.WrapErr("failed to write atom feed", err)
+	}
+	err = l.write("feeds/top-day.json", []byte(json))
+	if err != nil {
+		return util.WrapErr("failed to write json feed", err)
+	}
+	return nil
+}
+
+// CleanFeed removes feed entries that are older than 90 days.
+func (l Local) CleanFeed() error {
+	entries, err := l.GetFeedEntries()
+	if err != nil {
+		return util.WrapErr("failed to get feed entries", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Expired() {
+			err := os.Remove(l.path("feed", util.Hash(entry.Content.URL)+".json"))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return util.WrapErr("failed to delete feed entry", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l Local) path(elem ...string) string {
+	return filepath.Join(append([]string{l.dir}, elem...)...)
+}
+
+// Write a file atomically, by writing to a temporary file and renaming it.
+// This prevents readers (i.e. aggregation) from seeing partially written chunks.
+func (l Local) write(name string, data []byte) error {
+	path := l.path(name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return util.WrapErr("failed to create directory", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return util.WrapErr("failed to create temporary file", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return util.WrapErr("failed to write file", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return util.WrapErr("failed to rename file", err)
+	}
+	return nil
+}
+
+// Files being written are prefixed, so they can be skipped when listing directories
+const tempPrefix = ".tmp-"
+
+func isTemp(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
+
+// Read a JSON file into the given value. Returns false if the file does not exist.
+func (l Local) readJSON(name string, value any) (bool, error) {
+	data, err := os.ReadFile(l.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+func (l Local) exists(name string) (bool, error) {
+	_, err := os.Stat(l.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, util.WrapErr("failed to stat file", err)
+	}
+	return true, nil
+}
+
+func (l Local) fileURL(name string) (string, error) {
+	abs, err := filepath.Abs(l.path(name))
+	if err != nil {
+		return "", util.WrapErr("failed to resolve path", err)
+	}
+	return "file://" + filepath.ToSlash(abs), nil
+}
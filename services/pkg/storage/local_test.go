@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgemblack/blue-report/pkg/config"
+)
+
+func newTestLocal(t *testing.T) Local {
+	local, err := NewLocal(config.Config{EventCodec: "columnar", LocalStorageDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return local
+}
+
+// Test that flushed chunks are listed, and read back in any format
+func TestLocalEvents(t *testing.T) {
+	local := newTestLocal(t)
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	err := local.FlushEvents(ChunkKey{Start: start, Instance: "intake", Worker: 1, Sequence: 0}, testEvents(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = local.FlushEvents(ChunkKey{Start: start.Add(time.Hour), Instance: "intake", Worker: 1, Sequence: 1}, testEvents(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy JSON chunk
+	legacy, _ := JSONCodec.Encode(testEvents(5))
+	os.WriteFile(filepath.Join(local.dir, "events", "2025-03-03-11-00-00.json"), legacy, 0644)
+
+	chunks, err := local.ListEventChunks(start.Add(-2*time.Hour), start.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2025-03-03-11-00-00.json", "2025-03-03-12-00-00_intake_01_000000.msgpack.zst"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %v", len(expected), chunks)
+	}
+	for i := range expected {
+		if chunks[i] != expected[i] {
+			t.Errorf("expected '%s', got '%s'", expected[i], chunks[i])
+		}
+	}
+
+	for i, count := range []int{5, 10} {
+		events, err := local.ReadEvents(chunks[i], 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != count {
+			t.Errorf("expected %d events in %s, got %d", count, chunks[i], len(events))
+		}
+	}
+}
+
+func TestLocalURLMetadata(t *testing.T) {
+	local := newTestLocal(t)
+
+	metadata, err := local.GetURLMetadata("https://example.com")
+	if err != nil || metadata.Title != "" {
+		t.Fatalf("expected empty metadata, got %v (%v)", metadata, err)
+	}
+
+	local.SaveURLMetadata(URLMetadata{URL: "https://example.com", Title: "Example", Category: "tech"})
+	metadata, err = local.GetURLMetadata("https://example.com")
+	if err != nil || metadata.Title != "Example" || metadata.Category != "tech" {
+		t.Errorf("unexpected metadata: %v (%v)", metadata, err)
+	}
+}
+
+func TestLocalURLTranslations(t *testing.T) {
+	local := newTestLocal(t)
+
+	local.SaveURLTranslation(URLTranslation{Source: "https://sho.rt/a", Destination: "https://example.com/a"})
+	local.SaveURLTranslation(URLTranslation{Source: "https://sho.rt/b", Destination: "https://example.com/b"})
+	local.SaveURLTranslation(URLTranslation{Source: "https://sho.rt/a", Destination: "https://example.com/c"})
+
+	translations, err := local.GetURLTranslations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(translations) != 2 {
+		t.Errorf("expected 2 translations, got %d", len(translations))
+	}
+	if translations["https://sho.rt/a"] != "https://example.com/c" {
+		t.Errorf("expected latest translation, got '%s'", translations["https://sho.rt/a"])
+	}
+}
+
+// Test the redirect chain is saved with a translation, for auditing
+func TestLocalURLTranslationChain(t *testing.T) {
+	local := newTestLocal(t)
+
+	chain := []RedirectHop{
+		{URL: "https://sho.rt/a", Status: 301, Location: "https://example.com/a", DurationMS: 12},
+		{URL: "https://example.com/a", Status: 200, DurationMS: 30},
+	}
+	err := local.SaveURLTranslation(URLTranslation{Source: "https://sho.rt/a", Destination: "https://example.com/a", Outcome: "resolved", Chain: chain})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(local.path("translations", time.Now().UTC().Format("2006-01")+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved localTranslation
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Outcome != "resolved" || !reflect.DeepEqual(saved.Chain, chain) {
+		t.Errorf("unexpected translation: %+v", saved)
+	}
+}
+
+// Test that an empty blocklist can be told apart from one that was never saved
+func TestLocalBlockRules(t *testing.T) {
+	local := newTestLocal(t)
+
+	rules, err := local.GetBlockRules()
+	if err != nil || rules != nil {
+		t.Fatalf("expected no rules, got %v (%v)", rules, err)
+	}
+
+	local.SaveBlockRules(nil)
+	rules, err = local.GetBlockRules()
+	if err != nil || rules == nil || len(rules) != 0 {
+		t.Errorf("expected empty rules, got %v (%v)", rules, err)
+	}
+
+	expires := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	local.SaveBlockRules([]BlockRule{{ID: "a", Type: "host", Value: "example.com", Reason: "spam", ExpiresAt: expires}})
+	rules, err = local.GetBlockRules()
+	if err != nil || len(rules) != 1 || rules[0].Value != "example.com" || !rules[0].ExpiresAt.Equal(expires) {
+		t.Errorf("unexpected rules: %v (%v)", rules, err)
+	}
+}
+
+func TestLocalFeed(t *testing.T) {
+	local := newTestLocal(t)
+
+	if local.RecentFeedEntry() {
+		t.Error("expected no recent feed entry")
+	}
+
+	old := FeedEntry{Timestamp: time.Now().Add(-100 * 24 * time.Hour), Content: FeedEntryContent{Title: "Old", URL: "https://example.com/old"}}
+	recent := FeedEntry{Timestamp: time.Now(), Content: FeedEntryContent{Title: "Recent", URL: "https://example.com/recent"}}
+	local.AddFeedEntry(old)
+	local.AddFeedEntry(recent)
+
+	// Existing entries should not be modified
+	local.AddFeedEntry(FeedEntry{Timestamp: time.Now(), Content: FeedEntryContent{Title: "Modified", URL: "https://example.com/recent"}})
+
+	if !local.RecentFeedEntry() {
+		t.Error("expected recent feed entry")
+	}
+
+	err := local.CleanFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := local.GetFeedEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Content.Title != "Recent" {
+		t.Errorf("unexpected feed entries: %v", entries)
+	}
+}
+
+func TestLocalThumbnail(t *testing.T) {
+	local := newTestLocal(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	existing, err := local.GetThumbnailURL("abc")
+	if err != nil || existing != "" {
+		t.Fatalf("expected no thumbnail, got '%s' (%v)", existing, err)
+	}
+
+	saved, err := local.SaveThumbnail("abc", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(saved, "file://") || !strings.HasSuffix(saved, "/thumbnails/abc.png") {
+		t.Errorf("unexpected thumbnail url: %s", saved)
+	}
+
+	existing, err = local.GetThumbnailURL("abc")
+	if err != nil || existing != saved {
+		t.Errorf("expected '%s', got '%s' (%v)", saved, existing, err)
+	}
+}
+
+func TestLocalPublish(t *testing.T) {
+	local := newTestLocal(t)
+
+	local.PublishLinkSnapshot(LinkSnapshot, []byte(`{"links":[]}`))
+	local.PublishLinkSnapshot("links.ja", []byte(`{"language":"ja"}`))
+	local.PublishFeeds("<feed/>", "{}")
+
+	data, err := os.ReadFile(filepath.Join(local.dir, "data", "top-links.json"))
+	if err != nil || string(data) != `{"links":[]}` {
+		t.Errorf("unexpected snapshot: %s (%v)", data, err)
+	}
+	data, err = os.ReadFile(filepath.Join(local.dir, "data", "top-links.ja.json"))
+	if err != nil || string(data) != `{"language":"ja"}` {
+		t.Errorf("unexpected snapshot: %s (%v)", data, err)
+	}
+	data, err = os.ReadFile(filepath.Join(local.dir, "feeds", "top-day.xml"))
+	if err != nil || string(data) != "<feed/>" {
+		t.Errorf("unexpected feed: %s (%v)", data, err)
+	}
+}
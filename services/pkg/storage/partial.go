@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/georgemblack/blue-report/pkg/urltools"
+	"github.com/georgemblack/blue-report/pkg/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// PartialVersion is part of each partial's key, along with a hash of the URL cleaning rules (see 'urltools.RulesHash').
+// It must be incremented whenever the reduce logic changes, so that existing partials are ignored and rebuilt from their chunks.
+// Editing the cleaning rules invalidates partials without a new version.
+const PartialVersion = 6
+
+// partialMagic prefixes every encoded partial. The final byte is the format version.
+var partialMagic = []byte{'B', 'R', 'P', 'A', 1}
+
+// Partial is a chunk of events reduced to the interactions with each URL.
+// Partials are persisted, so that each chunk only has to be read and reduced once, and later aggregations can merge them instead.
+// Each interaction keeps a hash of the user's DID, so duplicates across chunks are still detected when merging.
+type Partial struct {
+	Chunk    string        `msgpack:"c"` // Name of the chunk the partial was reduced from
+	Events   int           `msgpack:"e"` // Number of events in the chunk
+	Links    []PartialLink `msgpack:"l"`
+	Accounts []string      `msgpack:"a"` // Distinct DIDs of every user with an interaction, so hashed users can be identified (i.e. by integrity checks)
+}
+
+// PartialLink contains all interactions with a single URL, in a single language, within a chunk.
+// Interactions are stored column by column, and are in the same order as the chunk's events.
+type PartialLink struct {
+	URL      string   `msgpack:"u"`
+	Language string   `msgpack:"g"`
+	Posts    []string `msgpack:"p"`  // Distinct AT URIs of posts referencing the URL
+	Types    []byte   `msgpack:"t"`  // Event type of each interaction
+	Users    []uint64 `msgpack:"d"`  // Hash of the DID of each interaction
+	Times    []int64  `msgpack:"s"`  // Unix seconds of each interaction
+	PostRefs []uint32 `msgpack:"pr"` // Index into 'Posts' for each interaction
+
+	// Labels of each labelled interaction, by index. Labels are rare, so unlabelled interactions are omitted.
+	Labels map[uint32][]string `msgpack:"lb,omitempty"`
+}
+
+// Valid returns false if the partial does not exist.
+func (p Partial) Valid() bool {
+	return p.Chunk != ""
+}
+
+// Len returns the number of interactions with the URL.
+func (l PartialLink) Len() int {
+	return len(l.Types)
+}
+
+// EncodePartial encodes a partial with msgpack and zstd.
+func EncodePartial(partial Partial) ([]byte, error) {
+	data, err := msgpack.Marshal(partial)
+	if err != nil {
+		return nil, util.WrapErr("failed to marshal partial", err)
+	}
+
+	result := make([]byte, 0, len(partialMagic)+len(data)/4)
+	result = append(result, partialMagic...)
+	return zstdEncoder.EncodeAll(data, result), nil
+}
+
+// DecodePartial decodes a partial, validating that each link's columns are consistent.
+func DecodePartial(data []byte) (Partial, error) {
+	if !bytes.HasPrefix(data, partialMagic) {
+		return Partial{}, errors.New("missing partial header")
+	}
+
+	decompressed, err := zstdDecoder.DecodeAll(data[len(partialMagic):], nil)
+	if err != nil {
+		return Partial{}, util.WrapErr("failed to decompress partial", err)
+	}
+
+	var partial Partial
+	err = msgpack.Unmarshal(decompressed, &partial)
+	if err != nil {
+		return Partial{}, util.WrapErr("failed to unmarshal partial", err)
+	}
+
+	for _, link := range partial.Links {
+		length := link.Len()
+		if len(link.Users) != length || len(link.Times) != length || len(link.PostRefs) != length {
+			return Partial{}, fmt.Errorf("columns in partial have mismatched lengths for url %s", link.URL)
+		}
+		for _, ref := range link.PostRefs {
+			if int(ref) >= len(link.Posts) {
+				return Partial{}, fmt.Errorf("invalid post reference for url %s", link.URL)
+			}
+		}
+	}
+
+	return partial, nil
+}
+
+// Return the key of the partial for a chunk, i.e. 'partials/v6-3f2a9c1b7e4d/2025-01-04-19-40-00_intake-1_01_000000.msgpack.zst'.
+func partialKey(chunk string) string {
+	base, _ := splitExtension(chunk)
+	return fmt.Sprintf("%s/%s.msgpack.zst", partialPrefix(), base)
+}
+
+// Partials are grouped by the version of the reduce logic, and the cleaning rules they were reduced with.
+func partialPrefix() string {
+	return fmt.Sprintf("partials/v%d-%s", PartialVersion, urltools.RulesHash)
+}
+
+// ReadPartial reads the partial for the given chunk. If the partial does not exist, return an empty partial.
+// Partials are derived from events, so they are stored alongside the events written by this environment.
+func (a AWS) ReadPartial(chunk string) (Partial, error) {
+	resp, err := a.s3.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(a.cfg.WriteEventsBucketName),
+		Key:    aws.String(partialKey(chunk)),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return Partial{}, nil
+		}
+		return Partial{}, util.WrapErr("failed to get object", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Partial{}, util.WrapErr("failed to read object", err)
+	}
+
+	return DecodePartial(data)
+}
+
+// SavePartial writes the partial for a chunk to S3.
+func (a AWS) SavePartial(partial Partial) error {
+	data, err := EncodePartial(partial)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.s3.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:               aws.String(a.cfg.WriteEventsBucketName),
+		Key:                  aws.String(partialKey(partial.Chunk)),
+		Body:                 bytes.NewReader(data),
+		ServerSideEncryption: "AES256",
+		ContentType:          aws.String("application/octet-stream"),
+	})
+	if err != nil {
+		return util.WrapErr("failed to put object", err)
+	}
+
+	return nil
+}
+
+func (l Local) ReadPartial(chunk string) (Partial, error) {
+	data, err := os.ReadFile(l.path(partialKey(chunk)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Partial{}, nil
+	}
+	if err != nil {
+		return Partial{}, util.WrapErr("failed to read partial", err)
+	}
+	return DecodePartial(data)
+}
+
+func (l Local) SavePartial(partial Partial) error {
+	data, err := EncodePartial(partial)
+	if err != nil {
+		return err
+	}
+	return l.write(partialKey(partial.Chunk), data)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/georgemblack/blue-report/pkg/urltools"
+)
+
+func testPartial() Partial {
+	return Partial{
+		Chunk:  "2025-03-03-12-00-00_intake_01_000000.msgpack.zst",
+		Events: 4,
+		Links: []PartialLink{
+			{
+				URL:      "https://www.example.com/article",
+				Language: "en",
+				Posts:    []string{"at://did:plc:user1/app.bsky.feed.post/1", "at://did:plc:user2/app.bsky.feed.post/2"},
+				Types:    []byte{0, 2, 1},
+				Users:    []uint64{1, 2, 3},
+				Times:    []int64{1741003200, 1741003201, 1741003260},
+				PostRefs: []uint32{0, 0, 1},
+			},
+			{
+				URL:      "https://www.example.com/other",
+				Language: "ja",
+				Posts:    []string{"at://did:plc:user3/app.bsky.feed.post/3"},
+				Types:    []byte{0},
+				Users:    []uint64{3},
+				Times:    []int64{1741003300},
+				PostRefs: []uint32{0},
+			},
+		},
+	}
+}
+
+func TestPartialRoundTrip(t *testing.T) {
+	partial := testPartial()
+
+	data, err := EncodePartial(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodePartial(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(partial, decoded) {
+		t.Errorf("unexpected partial: %+v", decoded)
+	}
+}
+
+// Test that corrupt partials are rejected, rather than causing a panic when merged
+func TestDecodeInvalidPartial(t *testing.T) {
+	if _, err := DecodePartial([]byte("{}")); err == nil {
+		t.Error("expected error for missing header")
+	}
+
+	mismatched := testPartial()
+	mismatched.Links[0].Users = mismatched.Links[0].Users[:2]
+	data, _ := EncodePartial(mismatched)
+	if _, err := DecodePartial(data); err == nil {
+		t.Error("expected error for mismatched columns")
+	}
+
+	invalidRef := testPartial()
+	invalidRef.Links[1].PostRefs[0] = 5
+	data, _ = EncodePartial(invalidRef)
+	if _, err := DecodePartial(data); err == nil {
+		t.Error("expected error for invalid post reference")
+	}
+}
+
+// Test that partials are stored under a versioned key, and missing partials are empty
+func TestLocalPartials(t *testing.T) {
+	local := newTestLocal(t)
+	partial := testPartial()
+
+	missing, err := local.ReadPartial(partial.Chunk)
+	if err != nil || missing.Valid() {
+		t.Errorf("expected missing partial, got %+v (%v)", missing, err)
+	}
+
+	err = local.SavePartial(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(local.dir, "partials", fmt.Sprintf("v%d-%s", PartialVersion, urltools.RulesHash), "2025-03-03-12-00-00_intake_01_000000.msgpack.zst")); err != nil {
+		t.Errorf("expected partial file: %s", err)
+	}
+
+	read, err := local.ReadPartial(partial.Chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Valid() || !reflect.DeepEqual(partial, read) {
+		t.Errorf("unexpected partial: %+v", read)
+	}
+}
@@ -3,31 +3,39 @@ package storage
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/georgemblack/blue-report/pkg/util"
 )
 
-// PublishLinkSnapshot publishes the snapshot of the site's data to S3.
-// Store a 'latest' version, as well as a timestamped version.
-func (a AWS) PublishLinkSnapshot(snapshot []byte) error {
+// PublishLinkSnapshot publishes the snapshot of the site's data to S3, i.e. 'data/top-links.json' or 'data/top-links.ja.json'.
+// Store a 'latest' version, as well as a timestamped version. Archiving the timestamped version is best-effort, as the 'latest' version is already live.
+// If the 'latest' version can't be published, nothing is archived, so the archive only contains snapshots that were live.
+func (a AWS) PublishLinkSnapshot(kind string, snapshot []byte) error {
 	_, err := a.r2.PutObject(context.Background(), &s3.PutObjectInput{
 		Bucket:       aws.String(a.cfg.PublicBucketName),
-		Key:          aws.String("data/top-links.json"),
+		Key:          aws.String(fmt.Sprintf("data/top-%s.json", kind)),
 		Body:         bytes.NewReader(snapshot),
 		ContentType:  aws.String("application/json"),
 		CacheControl: aws.String("public; max-age=600"), // 10 minutes
 	})
 	if err != nil {
-		slog.Error(util.WrapErr("failed to put object to r2", err).Error())
+		return util.WrapErr("failed to put object to r2", err)
+	}
+
+	err = a.archiveSnapshot(ArchivedSnapshot{Kind: kind, Time: time.Now().UTC()}, snapshot)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to archive snapshot", err).Error(), "kind", kind)
 	}
 
 	return nil
 }
 
-// PublishSiteSnapshot publishes the snapshot of the site's data to S3.
+// PublishSiteSnapshot publishes the snapshot of the site's data to S3, and archives a timestamped version in the same way as link snapshots.
 func (a AWS) PublishSiteSnapshot(snapshot []byte) error {
 	_, err := a.r2.PutObject(context.Background(), &s3.PutObjectInput{
 		Bucket:       aws.String(a.cfg.PublicBucketName),
@@ -37,7 +45,12 @@ func (a AWS) PublishSiteSnapshot(snapshot []byte) error {
 		CacheControl: aws.String("public; max-age=600"), // 10 minutes
 	})
 	if err != nil {
-		slog.Error(util.WrapErr("failed to put object to r2", err).Error())
+		return util.WrapErr("failed to put object to r2", err)
+	}
+
+	err = a.archiveSnapshot(ArchivedSnapshot{Kind: SiteSnapshot, Time: time.Now().UTC()}, snapshot)
+	if err != nil {
+		slog.Warn(util.WrapErr("failed to archive snapshot", err).Error(), "kind", SiteSnapshot)
 	}
 
 	return nil
@@ -17,10 +17,16 @@ type AWS struct {
 	s3       *s3.Client
 	r2       *s3.Client
 	dynamoDB *dynamodb.Client
+	codec    Codec // Used to encode event chunks
 	cfg      config.Config
 }
 
 func New(cfg config.Config) (AWS, error) {
+	codec, err := NewCodec(cfg.EventCodec)
+	if err != nil {
+		return AWS{}, err
+	}
+
 	// Configuration for AWS S3 and DynamoDB client
 	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(), awsConfig.WithRegion("us-west-2"))
 	if err != nil {
@@ -42,6 +48,7 @@ func New(cfg config.Config) (AWS, error) {
 		s3:       s3.NewFromConfig(awsCfg),
 		r2:       r2Client,
 		dynamoDB: dynamodb.NewFromConfig(awsCfg),
+		codec:    codec,
 		cfg:      cfg,
 	}, nil
 }
@@ -23,33 +23,9 @@ const ThumbnailHostPrefix = "https://data.theblue.report/thumbnails"
 // SaveThumbnail fetches an image at a given URL and stores it in S3.
 // The identifier for the image is returned.
 func (a AWS) SaveThumbnail(id string, imageURL string) (string, error) {
-	// Fetch the image
-	resp, err := http.Get(imageURL)
+	image, mimeType, err := fetchImage(imageURL)
 	if err != nil {
-		return "", util.WrapErr("failed to fetch image", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch image, status code: %s", resp.Status)
-	}
-	defer resp.Body.Close()
-
-	image, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", util.WrapErr("failed to read image", err)
-	}
-
-	// Determine the image's mime-type using the 'Content-Type' response header.
-	// OpenGraph images can be PNGs, JPEGs, or GIFs.
-	mimeType := resp.Header.Get("Content-Type")
-	if !util.ContainsStr(OpenGraphImageTypes, mimeType) {
-		// Attempt to detect the mime-type automatically
-		mimeType = http.DetectContentType(image)
-
-		// If it's still not a supported type, default to 'image/jpeg'
-		if !util.ContainsStr(OpenGraphImageTypes, mimeType) {
-			slog.Warn("unable to determine image mime-type", "url", imageURL)
-			mimeType = "image/jpeg"
-		}
+		return "", err
 	}
 
 	key := fmt.Sprintf("thumbnails/%s.%s", id, extension(mimeType))
@@ -89,6 +65,39 @@ func (a AWS) GetThumbnailURL(id string) (string, error) {
 	return "", nil
 }
 
+// Fetch an image at a given URL, returning its contents and mime-type.
+func fetchImage(imageURL string) ([]byte, string, error) {
+	resp, err := http.Get(imageURL)
+	if err != nil {
+		return nil, "", util.WrapErr("failed to fetch image", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch image, status code: %s", resp.Status)
+	}
+	defer resp.Body.Close()
+
+	image, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", util.WrapErr("failed to read image", err)
+	}
+
+	// Determine the image's mime-type using the 'Content-Type' response header.
+	// OpenGraph images can be PNGs, JPEGs, or GIFs.
+	mimeType := resp.Header.Get("Content-Type")
+	if !util.ContainsStr(OpenGraphImageTypes, mimeType) {
+		// Attempt to detect the mime-type automatically
+		mimeType = http.DetectContentType(image)
+
+		// If it's still not a supported type, default to 'image/jpeg'
+		if !util.ContainsStr(OpenGraphImageTypes, mimeType) {
+			slog.Warn("unable to determine image mime-type", "url", imageURL)
+			mimeType = "image/jpeg"
+		}
+	}
+
+	return image, mimeType, nil
+}
+
 func extension(mimeType string) string {
 	switch mimeType {
 	case "image/png":
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -13,6 +14,18 @@ import (
 type URLTranslation struct {
 	Source      string
 	Destination string
+	Outcome     string        // Outcome of resolving the source's redirects, i.e. 'resolved' or 'too_deep'
+	Canonical   string        // Canonical URL declared by the page the redirects led to, if any
+	Chain       []RedirectHop // Every request made while resolving redirects, so merged URLs can be audited
+}
+
+// RedirectHop is a single request made while resolving a URL's redirects.
+type RedirectHop struct {
+	URL        string `json:"url"`
+	Status     int    `json:"status"` // Zero if the request failed
+	Location   string `json:"location,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
 }
 
 func (a AWS) SaveURLTranslation(translation URLTranslation) error {
@@ -20,14 +33,30 @@ func (a AWS) SaveURLTranslation(translation URLTranslation) error {
 	ts := now.Format(time.RFC3339Nano)
 	month := now.Format("2006-01")
 
+	item := map[string]dynamoDBTypes.AttributeValue{
+		"updatedAt":      &dynamoDBTypes.AttributeValueMemberS{Value: ts},
+		"updatedAtMonth": &dynamoDBTypes.AttributeValueMemberS{Value: month},
+		"sourceUrl":      &dynamoDBTypes.AttributeValueMemberS{Value: translation.Source},
+		"destinationUrl": &dynamoDBTypes.AttributeValueMemberS{Value: translation.Destination},
+	}
+	if translation.Outcome != "" {
+		item["outcome"] = &dynamoDBTypes.AttributeValueMemberS{Value: translation.Outcome}
+	}
+	if translation.Canonical != "" {
+		item["canonicalUrl"] = &dynamoDBTypes.AttributeValueMemberS{Value: translation.Canonical}
+	}
+	if len(translation.Chain) > 0 {
+		// The chain is only read when auditing translations, so it is stored as JSON
+		chain, err := json.Marshal(translation.Chain)
+		if err != nil {
+			return util.WrapErr("failed to marshal redirect chain", err)
+		}
+		item["redirectChain"] = &dynamoDBTypes.AttributeValueMemberS{Value: string(chain)}
+	}
+
 	_, err := a.dynamoDB.PutItem(context.Background(), &dynamodb.PutItemInput{
 		TableName: aws.String(a.cfg.URLTranslationsTableName),
-		Item: map[string]dynamoDBTypes.AttributeValue{
-			"updatedAt":      &dynamoDBTypes.AttributeValueMemberS{Value: ts},
-			"updatedAtMonth": &dynamoDBTypes.AttributeValueMemberS{Value: month},
-			"sourceUrl":      &dynamoDBTypes.AttributeValueMemberS{Value: translation.Source},
-			"destinationUrl": &dynamoDBTypes.AttributeValueMemberS{Value: translation.Destination},
-		},
+		Item:      item,
 	})
 	if err != nil {
 		return util.WrapErr("failed to put url metadata", err)
@@ -10,8 +10,9 @@ import (
 )
 
 type URLMetadata struct {
-	URL   string
-	Title string
+	URL      string
+	Title    string
+	Category string // Category assigned by the classifier, i.e. 'tech'. Empty if the link has not been classified.
 }
 
 func (a AWS) GetURLMetadata(url string) (URLMetadata, error) {
@@ -26,21 +27,33 @@ func (a AWS) GetURLMetadata(url string) (URLMetadata, error) {
 		return URLMetadata{}, nil
 	}
 
-	return URLMetadata{
+	metadata := URLMetadata{
 		URL:   url,
 		Title: resp.Item["title"].(*dynamoDBTypes.AttributeValueMemberS).Value,
-	}, nil
+	}
+
+	// Items saved before links were classified have no category
+	if category, ok := resp.Item["category"].(*dynamoDBTypes.AttributeValueMemberS); ok {
+		metadata.Category = category.Value
+	}
+
+	return metadata, nil
 }
 
 func (a AWS) SaveURLMetadata(metadata URLMetadata) error {
-	_, err := a.dynamoDB.PutItem(context.Background(), &dynamodb.PutItemInput{
+	item := &dynamodb.PutItemInput{
 		TableName: aws.String(a.cfg.URLMetadataTableName),
 		Item: map[string]dynamoDBTypes.AttributeValue{
 			"urlHash": &dynamoDBTypes.AttributeValueMemberS{Value: util.Hash(metadata.URL)},
 			"url":     &dynamoDBTypes.AttributeValueMemberS{Value: metadata.URL},
 			"title":   &dynamoDBTypes.AttributeValueMemberS{Value: metadata.Title},
 		},
-	})
+	}
+	if metadata.Category != "" {
+		item.Item["category"] = &dynamoDBTypes.AttributeValueMemberS{Value: metadata.Category}
+	}
+
+	_, err := a.dynamoDB.PutItem(context.Background(), item)
 	if err != nil {
 		return util.WrapErr("failed to put url metadata", err)
 	}
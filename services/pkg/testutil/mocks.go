@@ -15,6 +15,8 @@ import (
 
 	bluesky "github.com/georgemblack/blue-report/pkg/bluesky"
 	cache "github.com/georgemblack/blue-report/pkg/cache"
+	links "github.com/georgemblack/blue-report/pkg/links"
+	live "github.com/georgemblack/blue-report/pkg/live"
 	queue "github.com/georgemblack/blue-report/pkg/queue"
 	storage "github.com/georgemblack/blue-report/pkg/storage"
 	gomock "go.uber.org/mock/gomock"
@@ -56,6 +58,21 @@ func (mr *MockCacheMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockCache)(nil).Close))
 }
 
+// ReadCursor mocks base method.
+func (m *MockCache) ReadCursor() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadCursor")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadCursor indicates an expected call of ReadCursor.
+func (mr *MockCacheMockRecorder) ReadCursor() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadCursor", reflect.TypeOf((*MockCache)(nil).ReadCursor))
+}
+
 // ReadPost mocks base method.
 func (m *MockCache) ReadPost(hash string) (cache.PostRecord, error) {
 	m.ctrl.T.Helper()
@@ -114,6 +131,20 @@ func (mr *MockCacheMockRecorder) RefreshURL(hash any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshURL", reflect.TypeOf((*MockCache)(nil).RefreshURL), hash)
 }
 
+// SaveCursor mocks base method.
+func (m *MockCache) SaveCursor(cursor int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveCursor", cursor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveCursor indicates an expected call of SaveCursor.
+func (mr *MockCacheMockRecorder) SaveCursor(cursor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveCursor", reflect.TypeOf((*MockCache)(nil).SaveCursor), cursor)
+}
+
 // SavePost mocks base method.
 func (m *MockCache) SavePost(hash string, post cache.PostRecord) error {
 	m.ctrl.T.Helper()
@@ -195,17 +226,32 @@ func (mr *MockStorageMockRecorder) CleanFeed() *gomock.Call {
 }
 
 // FlushEvents mocks base method.
-func (m *MockStorage) FlushEvents(start time.Time, events []storage.EventRecord) error {
+func (m *MockStorage) FlushEvents(key storage.ChunkKey, events []storage.EventRecord) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FlushEvents", start, events)
+	ret := m.ctrl.Call(m, "FlushEvents", key, events)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // FlushEvents indicates an expected call of FlushEvents.
-func (mr *MockStorageMockRecorder) FlushEvents(start, events any) *gomock.Call {
+func (mr *MockStorageMockRecorder) FlushEvents(key, events any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushEvents", reflect.TypeOf((*MockStorage)(nil).FlushEvents), key, events)
+}
+
+// GetBlockRules mocks base method.
+func (m *MockStorage) GetBlockRules() ([]storage.BlockRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockRules")
+	ret0, _ := ret[0].([]storage.BlockRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockRules indicates an expected call of GetBlockRules.
+func (mr *MockStorageMockRecorder) GetBlockRules() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushEvents", reflect.TypeOf((*MockStorage)(nil).FlushEvents), start, events)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockRules", reflect.TypeOf((*MockStorage)(nil).GetBlockRules))
 }
 
 // GetFeedEntries mocks base method.
@@ -283,6 +329,21 @@ func (mr *MockStorageMockRecorder) ListEventChunks(start, end any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEventChunks", reflect.TypeOf((*MockStorage)(nil).ListEventChunks), start, end)
 }
 
+// ListSnapshots mocks base method.
+func (m *MockStorage) ListSnapshots(kind string, start, end time.Time) ([]storage.ArchivedSnapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSnapshots", kind, start, end)
+	ret0, _ := ret[0].([]storage.ArchivedSnapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSnapshots indicates an expected call of ListSnapshots.
+func (mr *MockStorageMockRecorder) ListSnapshots(kind, start, end any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSnapshots", reflect.TypeOf((*MockStorage)(nil).ListSnapshots), kind, start, end)
+}
+
 // PublishFeeds mocks base method.
 func (m *MockStorage) PublishFeeds(atom, json string) error {
 	m.ctrl.T.Helper()
@@ -298,17 +359,17 @@ func (mr *MockStorageMockRecorder) PublishFeeds(atom, json any) *gomock.Call {
 }
 
 // PublishLinkSnapshot mocks base method.
-func (m *MockStorage) PublishLinkSnapshot(snapshot []byte) error {
+func (m *MockStorage) PublishLinkSnapshot(kind string, snapshot []byte) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PublishLinkSnapshot", snapshot)
+	ret := m.ctrl.Call(m, "PublishLinkSnapshot", kind, snapshot)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PublishLinkSnapshot indicates an expected call of PublishLinkSnapshot.
-func (mr *MockStorageMockRecorder) PublishLinkSnapshot(snapshot any) *gomock.Call {
+func (mr *MockStorageMockRecorder) PublishLinkSnapshot(kind, snapshot any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishLinkSnapshot", reflect.TypeOf((*MockStorage)(nil).PublishLinkSnapshot), snapshot)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishLinkSnapshot", reflect.TypeOf((*MockStorage)(nil).PublishLinkSnapshot), kind, snapshot)
 }
 
 // PublishSiteSnapshot mocks base method.
@@ -340,6 +401,36 @@ func (mr *MockStorageMockRecorder) ReadEvents(key, eventBufferSize any) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadEvents", reflect.TypeOf((*MockStorage)(nil).ReadEvents), key, eventBufferSize)
 }
 
+// ReadPartial mocks base method.
+func (m *MockStorage) ReadPartial(chunk string) (storage.Partial, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadPartial", chunk)
+	ret0, _ := ret[0].(storage.Partial)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadPartial indicates an expected call of ReadPartial.
+func (mr *MockStorageMockRecorder) ReadPartial(chunk any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadPartial", reflect.TypeOf((*MockStorage)(nil).ReadPartial), chunk)
+}
+
+// ReadSnapshot mocks base method.
+func (m *MockStorage) ReadSnapshot(snapshot storage.ArchivedSnapshot) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadSnapshot", snapshot)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadSnapshot indicates an expected call of ReadSnapshot.
+func (mr *MockStorageMockRecorder) ReadSnapshot(snapshot any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadSnapshot", reflect.TypeOf((*MockStorage)(nil).ReadSnapshot), snapshot)
+}
+
 // RecentFeedEntry mocks base method.
 func (m *MockStorage) RecentFeedEntry() bool {
 	m.ctrl.T.Helper()
@@ -354,6 +445,48 @@ func (mr *MockStorageMockRecorder) RecentFeedEntry() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecentFeedEntry", reflect.TypeOf((*MockStorage)(nil).RecentFeedEntry))
 }
 
+// SaveBlockRules mocks base method.
+func (m *MockStorage) SaveBlockRules(rules []storage.BlockRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBlockRules", rules)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveBlockRules indicates an expected call of SaveBlockRules.
+func (mr *MockStorageMockRecorder) SaveBlockRules(rules any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBlockRules", reflect.TypeOf((*MockStorage)(nil).SaveBlockRules), rules)
+}
+
+// SaveIntegrityReport mocks base method.
+func (m *MockStorage) SaveIntegrityReport(report []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIntegrityReport", report)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIntegrityReport indicates an expected call of SaveIntegrityReport.
+func (mr *MockStorageMockRecorder) SaveIntegrityReport(report any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIntegrityReport", reflect.TypeOf((*MockStorage)(nil).SaveIntegrityReport), report)
+}
+
+// SavePartial mocks base method.
+func (m *MockStorage) SavePartial(partial storage.Partial) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePartial", partial)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePartial indicates an expected call of SavePartial.
+func (mr *MockStorageMockRecorder) SavePartial(partial any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePartial", reflect.TypeOf((*MockStorage)(nil).SavePartial), partial)
+}
+
 // SaveThumbnail mocks base method.
 func (m *MockStorage) SaveThumbnail(id, url string) (string, error) {
 	m.ctrl.T.Helper()
@@ -397,6 +530,161 @@ func (mr *MockStorageMockRecorder) SaveURLTranslation(translation any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveURLTranslation", reflect.TypeOf((*MockStorage)(nil).SaveURLTranslation), translation)
 }
 
+// MockBlocklist is a mock of Blocklist interface.
+type MockBlocklist struct {
+	ctrl     *gomock.Controller
+	recorder *MockBlocklistMockRecorder
+	isgomock struct{}
+}
+
+// MockBlocklistMockRecorder is the mock recorder for MockBlocklist.
+type MockBlocklistMockRecorder struct {
+	mock *MockBlocklist
+}
+
+// NewMockBlocklist creates a new mock instance.
+func NewMockBlocklist(ctrl *gomock.Controller) *MockBlocklist {
+	mock := &MockBlocklist{ctrl: ctrl}
+	mock.recorder = &MockBlocklistMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBlocklist) EXPECT() *MockBlocklistMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockBlocklist) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockBlocklistMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockBlocklist)(nil).Close))
+}
+
+// Match mocks base method.
+func (m *MockBlocklist) Match(url string) (storage.BlockRule, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Match", url)
+	ret0, _ := ret[0].(storage.BlockRule)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Match indicates an expected call of Match.
+func (mr *MockBlocklistMockRecorder) Match(url any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Match", reflect.TypeOf((*MockBlocklist)(nil).Match), url)
+}
+
+// MockLive is a mock of Live interface.
+type MockLive struct {
+	ctrl     *gomock.Controller
+	recorder *MockLiveMockRecorder
+	isgomock struct{}
+}
+
+// MockLiveMockRecorder is the mock recorder for MockLive.
+type MockLiveMockRecorder struct {
+	mock *MockLive
+}
+
+// NewMockLive creates a new mock instance.
+func NewMockLive(ctrl *gomock.Controller) *MockLive {
+	mock := &MockLive{ctrl: ctrl}
+	mock.recorder = &MockLiveMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLive) EXPECT() *MockLiveMockRecorder {
+	return m.recorder
+}
+
+// Buckets mocks base method.
+func (m *MockLive) Buckets(bounds links.TimeBounds) ([]live.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Buckets", bounds)
+	ret0, _ := ret[0].([]live.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Buckets indicates an expected call of Buckets.
+func (mr *MockLiveMockRecorder) Buckets(bounds any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Buckets", reflect.TypeOf((*MockLive)(nil).Buckets), bounds)
+}
+
+// Close mocks base method.
+func (m *MockLive) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockLiveMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockLive)(nil).Close))
+}
+
+// Record mocks base method.
+func (m *MockLive) Record(record storage.EventRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Record", record)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockLiveMockRecorder) Record(record any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockLive)(nil).Record), record)
+}
+
+// MockHistory is a mock of History interface.
+type MockHistory struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryMockRecorder
+	isgomock struct{}
+}
+
+// MockHistoryMockRecorder is the mock recorder for MockHistory.
+type MockHistoryMockRecorder struct {
+	mock *MockHistory
+}
+
+// NewMockHistory creates a new mock instance.
+func NewMockHistory(ctrl *gomock.Controller) *MockHistory {
+	mock := &MockHistory{ctrl: ctrl}
+	mock.recorder = &MockHistoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistory) EXPECT() *MockHistoryMockRecorder {
+	return m.recorder
+}
+
+// PreviousLinkSnapshot mocks base method.
+func (m *MockHistory) PreviousLinkSnapshot(kind string, before time.Time) (links.Snapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviousLinkSnapshot", kind, before)
+	ret0, _ := ret[0].(links.Snapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviousLinkSnapshot indicates an expected call of PreviousLinkSnapshot.
+func (mr *MockHistoryMockRecorder) PreviousLinkSnapshot(kind, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviousLinkSnapshot", reflect.TypeOf((*MockHistory)(nil).PreviousLinkSnapshot), kind, before)
+}
+
 // MockQueue is a mock of Queue interface.
 type MockQueue struct {
 	ctrl     *gomock.Controller
@@ -474,6 +762,21 @@ func (m *MockBluesky) EXPECT() *MockBlueskyMockRecorder {
 	return m.recorder
 }
 
+// GetLabels mocks base method.
+func (m *MockBluesky) GetLabels(atURIs []string) (map[string][]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLabels", atURIs)
+	ret0, _ := ret[0].(map[string][]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLabels indicates an expected call of GetLabels.
+func (mr *MockBlueskyMockRecorder) GetLabels(atURIs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLabels", reflect.TypeOf((*MockBluesky)(nil).GetLabels), atURIs)
+}
+
 // GetPost mocks base method.
 func (m *MockBluesky) GetPost(atURI string) (bluesky.Post, error) {
 	m.ctrl.T.Helper()
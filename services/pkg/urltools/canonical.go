@@ -0,0 +1,353 @@
+package urltools
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/georgemblack/blue-report/pkg/util"
+)
+
+// Query policy modes
+const (
+	AllowQuery = "allow" // Keep only the listed params, in the listed order
+	DenyQuery  = "deny"  // Keep every param except those listed
+)
+
+// Trailing slash policies
+const (
+	KeepTrailingSlash  = "keep"
+	StripTrailingSlash = "strip"
+	AddTrailingSlash   = "add"
+)
+
+// The rules used by 'Clean'. Adding a site-specific rewrite (i.e. a new URL shortener) should only require editing this file.
+// Partials store cleaned URLs, so they are keyed by 'RulesHash', and editing the rules invalidates them.
+//
+//go:embed canonical.json
+var defaultRules []byte
+
+var defaultCanonicalizer = mustParseRules(defaultRules)
+
+// RulesHash identifies the rules used by 'Clean', so data derived from cleaned URLs can be rebuilt when the rules change.
+var RulesHash = rulesHash(defaultRules)
+
+func rulesHash(rules []byte) string {
+	sum := sha256.Sum256(rules)
+	return hex.EncodeToString(sum[:6])
+}
+
+// RuleSet is the declarative format of the canonicalization rules. See 'canonical.json'.
+type RuleSet struct {
+	Defaults Defaults `json:"defaults"`
+	Rules    []Rule   `json:"rules"`
+}
+
+// Defaults apply to every URL, unless overridden by a matching rule.
+type Defaults struct {
+	Query         QueryPolicy `json:"query"`
+	LowercasePath bool        `json:"lowercase_path"`
+	TrailingSlash string      `json:"trailing_slash"`
+}
+
+// Rule is applied to URLs whose host is in 'Hosts'. Rules are applied in order, each seeing the result of the last.
+// A host alias is applied first, then the rewrite. Query and path policies are taken from the first rule matching the final host that sets them.
+type Rule struct {
+	Name          string       `json:"name"`
+	Hosts         []string     `json:"hosts"`          // Hosts the rule applies to. '*.example.com' matches subdomains of 'example.com'. If empty, applies to every host.
+	Host          string       `json:"host"`           // Replaces the host, i.e. 'old.reddit.com' -> 'www.reddit.com'
+	Rewrite       *Rewrite     `json:"rewrite"`        // Rewrites the host and path
+	Query         *QueryPolicy `json:"query"`          // Overrides the default query policy
+	LowercasePath *bool        `json:"lowercase_path"` // Overrides the default lowercase policy
+	TrailingSlash string       `json:"trailing_slash"` // Overrides the default trailing slash policy
+}
+
+// Rewrite matches a regular expression against the URL's host and path (i.e. 'youtu.be/abc123'), without the scheme or query.
+// If it matches, the host and path are replaced by 'To', which may reference groups (i.e. '$1').
+// Query params may also be set from groups, i.e. '{"v": "$1"}'. These are subject to the query policy, like any other param.
+type Rewrite struct {
+	Pattern string            `json:"pattern"`
+	To      string            `json:"to"`
+	Query   map[string]string `json:"query"`
+}
+
+type QueryPolicy struct {
+	Mode   string   `json:"mode"` // Either 'allow' or 'deny'
+	Params []string `json:"params"`
+}
+
+// Canonicalizer applies a compiled set of rules.
+type Canonicalizer struct {
+	defaults Defaults
+	rules    []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	pattern *regexp.Regexp
+}
+
+// ParseRules parses and validates a set of rules in JSON.
+func ParseRules(data []byte) (*Canonicalizer, error) {
+	var set RuleSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, util.WrapErr("failed to unmarshal rules", err)
+	}
+
+	if set.Defaults.TrailingSlash == "" {
+		set.Defaults.TrailingSlash = KeepTrailingSlash
+	}
+	if err := validatePolicy(set.Defaults.Query, set.Defaults.TrailingSlash); err != nil {
+		return nil, util.WrapErr("invalid defaults", err)
+	}
+
+	c := &Canonicalizer{defaults: set.Defaults}
+	for i, rule := range set.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+
+		compiled := compiledRule{Rule: rule}
+		if rule.Rewrite != nil {
+			pattern, err := regexp.Compile(rule.Rewrite.Pattern)
+			if err != nil {
+				return nil, util.WrapErr(fmt.Sprintf("invalid pattern in rule %s", name), err)
+			}
+			if rule.Rewrite.To == "" {
+				return nil, fmt.Errorf("rewrite in rule %s has no destination", name)
+			}
+			compiled.pattern = pattern
+		}
+		query := set.Defaults.Query
+		if rule.Query != nil {
+			query = *rule.Query
+		}
+		if err := validatePolicy(query, rule.TrailingSlash); err != nil {
+			return nil, util.WrapErr(fmt.Sprintf("invalid rule %s", name), err)
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return c, nil
+}
+
+func mustParseRules(data []byte) *Canonicalizer {
+	c, err := ParseRules(data)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func validatePolicy(query QueryPolicy, trailingSlash string) error {
+	if query.Mode != AllowQuery && query.Mode != DenyQuery {
+		return fmt.Errorf("unknown query mode: %s", query.Mode)
+	}
+	if !slices.Contains([]string{"", KeepTrailingSlash, StripTrailingSlash, AddTrailingSlash}, trailingSlash) {
+		return fmt.Errorf("unknown trailing slash policy: %s", trailingSlash)
+	}
+	return nil
+}
+
+// Canonicalize applies every matching rule to a URL, then the query, case, and trailing slash policies.
+// Fragments are always removed. If the URL cannot be parsed, or has no host, only the fragment is removed.
+func (c *Canonicalizer) Canonicalize(input string) string {
+	result := strings.Split(input, "#")[0]
+	parsed, err := url.Parse(result)
+	if err != nil || parsed.Host == "" {
+		return result
+	}
+
+	host := strings.ToLower(parsed.Host)
+	path := parsed.EscapedPath()
+	params := parseQuery(parsed.RawQuery)
+
+	for _, rule := range c.rules {
+		if !rule.matches(host) {
+			continue
+		}
+		if rule.Host != "" {
+			host = rule.Host
+		}
+		if rule.pattern != nil {
+			host, path, params = rule.rewrite(host, path, params)
+		}
+	}
+
+	// Each policy is taken from the first rule matching the final host that sets it
+	var query *QueryPolicy
+	var lowercase *bool
+	trailingSlash := ""
+	for _, rule := range c.rules {
+		if !rule.matches(host) {
+			continue
+		}
+		if query == nil {
+			query = rule.Query
+		}
+		if lowercase == nil {
+			lowercase = rule.LowercasePath
+		}
+		if trailingSlash == "" {
+			trailingSlash = rule.TrailingSlash
+		}
+	}
+	if query == nil {
+		query = &c.defaults.Query
+	}
+	if lowercase == nil {
+		lowercase = &c.defaults.LowercasePath
+	}
+	if trailingSlash == "" {
+		trailingSlash = c.defaults.TrailingSlash
+	}
+
+	if *lowercase {
+		path = strings.ToLower(path)
+	}
+	switch trailingSlash {
+	case StripTrailingSlash:
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+	case AddTrailingSlash:
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+	}
+
+	result = parsed.Scheme + "://" + host + path
+	if encoded := query.apply(params); encoded != "" {
+		result += "?" + encoded
+	}
+	return result
+}
+
+// Aliases determines whether the rules map one host onto the other's site, either by replacing the host,
+// or by a rewrite to a fixed host (i.e. 'youtu.be' -> 'www.youtube.com'). Rewrites taking the host from the URL are not aliases.
+func (c *Canonicalizer) Aliases(host, other string) bool {
+	host, other = strings.ToLower(host), strings.ToLower(other)
+	for _, rule := range c.rules {
+		target := rule.target()
+		if target == "" {
+			continue
+		}
+		if (rule.matches(host) && sameSite(target, other)) || (rule.matches(other) && sameSite(target, host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The host the rule maps matching hosts to, or an empty string if it isn't fixed.
+func (r compiledRule) target() string {
+	if r.Host != "" {
+		return r.Host
+	}
+	if r.Rewrite == nil {
+		return ""
+	}
+	host, _, _ := strings.Cut(r.Rewrite.To, "/")
+	if strings.Contains(host, "$") {
+		return ""
+	}
+	return host
+}
+
+func (r compiledRule) matches(host string) bool {
+	if len(r.Hosts) == 0 {
+		return true
+	}
+	for _, pattern := range r.Hosts {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (r compiledRule) rewrite(host, path string, params []param) (string, string, []param) {
+	subject := host + path
+	match := r.pattern.FindStringSubmatchIndex(subject)
+	if match == nil {
+		return host, path, params
+	}
+
+	rewritten := string(r.pattern.ExpandString(nil, r.Rewrite.To, subject, match))
+	host, path, found := strings.Cut(rewritten, "/")
+	if found {
+		path = "/" + path
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(r.Rewrite.Query)) {
+		value := string(r.pattern.ExpandString(nil, r.Rewrite.Query[key], subject, match))
+		params = slices.DeleteFunc(params, func(p param) bool { return p.key == key })
+		params = append(params, param{key: key, value: value})
+	}
+	return host, path, params
+}
+
+type param struct {
+	key   string
+	value string
+}
+
+// Parse a query into its params, preserving their order. Only the first value of each param is kept.
+func parseQuery(raw string) []param {
+	params := make([]param, 0)
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		if slices.ContainsFunc(params, func(p param) bool { return p.key == key }) {
+			continue
+		}
+		params = append(params, param{key: key, value: value})
+	}
+	return params
+}
+
+// Encode the params kept by the policy.
+func (q QueryPolicy) apply(params []param) string {
+	kept := make([]string, 0)
+	encode := func(p param) string {
+		return url.QueryEscape(p.key) + "=" + url.QueryEscape(p.value)
+	}
+
+	if q.Mode == AllowQuery {
+		for _, key := range q.Params {
+			if i := slices.IndexFunc(params, func(p param) bool { return p.key == key }); i >= 0 {
+				kept = append(kept, encode(params[i]))
+			}
+		}
+	} else {
+		for _, p := range params {
+			if !slices.Contains(q.Params, p.key) {
+				kept = append(kept, encode(p))
+			}
+		}
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,100 @@
+package urltools
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Test the default rules against the golden file. Each input is listed with the URL it is cleaned to.
+func TestCanonicalGolden(t *testing.T) {
+	input, err := os.ReadFile("testdata/canonical.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual bytes.Buffer
+	for _, line := range strings.Split(string(input), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fmt.Fprintf(&actual, "%s\n\t%s\n", line, Clean(line))
+	}
+
+	if *update {
+		if err := os.WriteFile("testdata/canonical.golden", actual.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile("testdata/canonical.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLines, actualLines := strings.Split(string(expected), "\n"), strings.Split(actual.String(), "\n")
+	if len(expectedLines) != len(actualLines) {
+		t.Fatalf("expected %d lines, got %d (run with -update to regenerate)", len(expectedLines), len(actualLines))
+	}
+	for i := range expectedLines {
+		if expectedLines[i] != actualLines[i] {
+			t.Errorf("line %d: expected '%s', got '%s'", i+1, strings.TrimSpace(expectedLines[i]), strings.TrimSpace(actualLines[i]))
+		}
+	}
+}
+
+// Test each policy with a custom set of rules
+func TestCanonicalizePolicies(t *testing.T) {
+	c, err := ParseRules([]byte(`{
+		"defaults": {"query": {"mode": "deny", "params": ["utm_source"]}, "trailing_slash": "strip"},
+		"rules": [
+			{"name": "alias", "hosts": ["*.example.org"], "host": "example.org"},
+			{"hosts": ["example.org"], "lowercase_path": true, "trailing_slash": "add", "query": {"mode": "allow", "params": []}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []CleanTest{
+		{Input: "https://example.com/a/?b=1&utm_source=x&c=2", Expected: "https://example.com/a?b=1&c=2"},
+		{Input: "https://example.com/", Expected: "https://example.com/"},
+		{Input: "https://www.example.org/A/B?b=1", Expected: "https://example.org/a/b/"},
+	}
+	for _, test := range tests {
+		if result := c.Canonicalize(test.Input); result != test.Expected {
+			t.Errorf("expected '%s', got '%s'", test.Expected, result)
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []string{
+		`{"defaults": {"query": {"mode": "strip"}}}`,
+		`{"defaults": {"query": {"mode": "allow"}, "trailing_slash": "sometimes"}}`,
+		`{"defaults": {"query": {"mode": "allow"}}, "rules": [{"rewrite": {"pattern": "(", "to": "$1"}}]}`,
+		`{"defaults": {"query": {"mode": "allow"}}, "rules": [{"rewrite": {"pattern": "^a"}}]}`,
+	}
+	for _, test := range tests {
+		if _, err := ParseRules([]byte(test)); err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+}
+
+// Test that any edit to the rules changes their hash, so partials reduced with the old rules are not reused
+func TestRulesHash(t *testing.T) {
+	if len(RulesHash) != 12 || RulesHash != rulesHash(defaultRules) {
+		t.Errorf("unexpected hash: %s", RulesHash)
+	}
+	edited := bytes.Replace(defaultRules, []byte(`"amp-path"`), []byte(`"amp-paths"`), 1)
+	if rulesHash(edited) == RulesHash {
+		t.Error("expected edited rules to have a different hash")
+	}
+}
@@ -0,0 +1,144 @@
+package urltools
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	MaxPageSize      = 1 << 20 // Only the start of a page is read, as the canonical URL is declared in the head
+	DiscoveryTimeout = 5 * time.Second
+	UserAgent        = "Mozilla/5.0 (compatible; BlueReport/1.0; +https://theblue.report)"
+)
+
+// FindCanonical fetches a page and returns the canonical URL it declares, via '<link rel="canonical">' or '<meta property="og:url">'.
+// The canonical URL must be on the same registrable domain as the page, or an alias of the page's host in the canonicalization rules
+// (i.e. 'youtu.be' and 'www.youtube.com'), as pages can declare anything.
+// Redirects are not followed, as the page should already be the destination of any redirects (see 'Resolver').
+// If no valid canonical URL is found, an empty string is returned.
+func FindCanonical(input string) string {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: DiscoveryTimeout,
+	}
+	return findCanonical(client, defaultCanonicalizer, input)
+}
+
+func findCanonical(client *http.Client, rules *Canonicalizer, input string) string {
+	req, err := http.NewRequest(http.MethodGet, input, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// A valid website could be blocking us. Assume no canonical URL.
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return ""
+	}
+
+	// Relative URLs are resolved against the page
+	page := resp.Request.URL
+	declared := parseCanonical(io.LimitReader(resp.Body, MaxPageSize))
+	if declared == "" {
+		return ""
+	}
+	canonical, err := page.Parse(declared)
+	if err != nil || (canonical.Scheme != "https" && canonical.Scheme != "http") || canonical.Host == "" {
+		return ""
+	}
+
+	if !sameSite(page.Hostname(), canonical.Hostname()) && !rules.Aliases(page.Hostname(), canonical.Hostname()) {
+		return ""
+	}
+
+	// A canonical URL pointing at the site's root is a common misconfiguration, which would merge every page on the site into the homepage
+	if canonical.Path == "" || canonical.Path == "/" {
+		return ""
+	}
+	return canonical.String()
+}
+
+// Read the page's head, returning the URL declared by '<link rel="canonical">', or '<meta property="og:url">' if there is none.
+func parseCanonical(body io.Reader) string {
+	tokenizer := html.NewTokenizer(body)
+	link, og := "", ""
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return pick(link, og)
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return pick(link, og)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) == "body" {
+				return pick(link, og)
+			}
+			if !hasAttr || (string(name) != "link" && string(name) != "meta") {
+				continue
+			}
+
+			attrs := make(map[string]string)
+			for {
+				key, value, more := tokenizer.TagAttr()
+				attrs[strings.ToLower(string(key))] = strings.TrimSpace(string(value))
+				if !more {
+					break
+				}
+			}
+			if string(name) == "link" && link == "" && slices.Contains(strings.Fields(strings.ToLower(attrs["rel"])), "canonical") {
+				link = attrs["href"]
+			}
+			if string(name) == "meta" && og == "" && attrs["property"] == "og:url" {
+				og = attrs["content"]
+			}
+		}
+	}
+}
+
+func pick(link, og string) string {
+	if link != "" {
+		return link
+	}
+	return og
+}
+
+// Determine whether two hosts share a registrable domain (i.e. 'www.example.com' and 'amp.example.com').
+func sameSite(host, other string) bool {
+	if strings.EqualFold(host, other) {
+		return true
+	}
+	domain, err := registrableDomain(host)
+	if err != nil {
+		return false
+	}
+	otherDomain, err := registrableDomain(other)
+	if err != nil {
+		return false
+	}
+	return domain == otherDomain
+}
+
+func registrableDomain(host string) (string, error) {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return "", fmt.Errorf("no registrable domain for %s: %w", host, err)
+	}
+	return domain, nil
+}
@@ -0,0 +1,250 @@
+package urltools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPageServer(head string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<!DOCTYPE html><html><head><title>Page</title>%s</head><body><p>Hello</p></body></html>", head)
+	}))
+}
+
+// Test canonical link with an absolute URL
+func TestFindCanonicalLink(t *testing.T) {
+	ms := newPageServer(`<link rel="canonical" href="http://127.0.0.1/article">`)
+	defer ms.Close()
+
+	result := FindCanonical(ms.URL + "/article?utm_source=bsky")
+	expected := "http://127.0.0.1/article"
+	if result != expected {
+		t.Errorf("expected '%s', got '%s'", expected, result)
+	}
+}
+
+// Test canonical link with a relative URL, which is resolved against the page
+func TestFindCanonicalRelativeLink(t *testing.T) {
+	ms := newPageServer(`<link rel="canonical" href="/article">`)
+	defer ms.Close()
+
+	result := FindCanonical(ms.URL + "/amp/article")
+	expected := ms.URL + "/article"
+	if result != expected {
+		t.Errorf("expected '%s', got '%s'", expected, result)
+	}
+}
+
+// Test 'og:url' is used when there is no canonical link, and the canonical link is preferred when both are present
+func TestFindCanonicalOpenGraph(t *testing.T) {
+	ms := newPageServer(`<meta property="og:url" content="/og">`)
+	defer ms.Close()
+
+	result := FindCanonical(ms.URL + "/page")
+	expected := ms.URL + "/og"
+	if result != expected {
+		t.Errorf("expected '%s', got '%s'", expected, result)
+	}
+
+	both := newPageServer(`<meta property="og:url" content="/og"><link rel="Canonical" href="/canonical" />`)
+	defer both.Close()
+
+	result = FindCanonical(both.URL + "/page")
+	expected = both.URL + "/canonical"
+	if result != expected {
+		t.Errorf("expected '%s', got '%s'", expected, result)
+	}
+}
+
+// Test links in the body, or with another relation, are ignored
+func TestFindCanonicalIgnoresBody(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="alternate" href="/alternate"></head><body><link rel="canonical" href="/body"></body></html>`)
+	}))
+	defer ms.Close()
+
+	result := FindCanonical(ms.URL + "/page")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+}
+
+// Test canonical URLs on another domain are rejected
+func TestFindCanonicalOtherDomain(t *testing.T) {
+	ms := newPageServer(`<link rel="canonical" href="https://example.com/article">`)
+	defer ms.Close()
+
+	result := FindCanonical(ms.URL + "/article")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+
+	// Non-HTTP URLs are also rejected
+	script := newPageServer(`<link rel="canonical" href="javascript:alert(1)">`)
+	defer script.Close()
+
+	result = FindCanonical(script.URL + "/article")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+}
+
+// Test canonical URLs on an alias of the page's host are accepted, and those on other hosts are rejected
+func TestFindCanonicalAlias(t *testing.T) {
+	rules, err := ParseRules([]byte(`{
+		"defaults": {"query": {"mode": "allow", "params": []}},
+		"rules": [{"name": "local", "hosts": ["127.0.0.1"], "host": "www.example.com"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Timeout: DiscoveryTimeout}
+
+	allowed := newPageServer(`<link rel="canonical" href="https://www.example.com/article">`)
+	defer allowed.Close()
+	if result := findCanonical(client, rules, allowed.URL+"/amp/article"); result != "https://www.example.com/article" {
+		t.Errorf("unexpected canonical url: '%s'", result)
+	}
+
+	disallowed := newPageServer(`<link rel="canonical" href="https://www.example.org/article">`)
+	defer disallowed.Close()
+	if result := findCanonical(client, rules, disallowed.URL+"/amp/article"); result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+}
+
+// Test canonical URLs pointing at the site's root are rejected
+func TestFindCanonicalRoot(t *testing.T) {
+	for _, href := range []string{"/", "http://127.0.0.1"} {
+		ms := newPageServer(fmt.Sprintf(`<link rel="canonical" href="%s">`, href))
+		result := FindCanonical(ms.URL + "/article")
+		ms.Close()
+		if result != "" {
+			t.Errorf("expected no canonical url for '%s', got '%s'", href, result)
+		}
+	}
+}
+
+// Test redirects are not followed
+func TestFindCanonicalRedirect(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/short" {
+			w.Header().Set("Location", "/page")
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="/article"></head></html>`)
+	}))
+	defer ms.Close()
+
+	if result := FindCanonical(ms.URL + "/short"); result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+	if result := FindCanonical(ms.URL + "/page"); result != ms.URL+"/article" {
+		t.Errorf("unexpected canonical url: '%s'", result)
+	}
+}
+
+// Test the canonical URL must be in the head, within the size limit
+func TestFindCanonicalSizeLimit(t *testing.T) {
+	ms := newPageServer(strings.Repeat(`<meta name="filler" content="filler">`, MaxPageSize/30) + `<link rel="canonical" href="/article">`)
+	defer ms.Close()
+
+	result := FindCanonical(ms.URL + "/page")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+}
+
+// Test slow pages and non-HTML responses are ignored
+func TestFindCanonicalErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="/article"></head></html>`)
+	}))
+	defer slow.Close()
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	result := findCanonical(client, defaultCanonicalizer, slow.URL+"/page")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+
+	json := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `<link rel="canonical" href="/article">`)
+	}))
+	defer json.Close()
+
+	result = FindCanonical(json.URL + "/page")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<html><head><link rel="canonical" href="/article"></head></html>`)
+	}))
+	defer missing.Close()
+
+	result = FindCanonical(missing.URL + "/page")
+	if result != "" {
+		t.Errorf("expected no canonical url, got '%s'", result)
+	}
+}
+
+// Test hosts are compared by registrable domain
+func TestSameSite(t *testing.T) {
+	tests := []struct {
+		host     string
+		other    string
+		expected bool
+	}{
+		{"www.nytimes.com", "www.nytimes.com", true},
+		{"amp.theguardian.com", "www.theguardian.com", true},
+		{"www.bbc.co.uk", "bbc.co.uk", true},
+		{"nyti.ms", "www.nytimes.com", false},
+		{"example.co.uk", "other.co.uk", false},
+		{"user.github.io", "other.github.io", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1", "example.com", false},
+	}
+
+	for _, test := range tests {
+		result := sameSite(test.host, test.other)
+		if result != test.expected {
+			t.Errorf("sameSite(%s, %s): expected %t, got %t", test.host, test.other, test.expected, result)
+		}
+	}
+}
+
+// Test hosts are aliases when the default rules map one onto the other's site
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		host     string
+		other    string
+		expected bool
+	}{
+		{"youtu.be", "www.youtube.com", true},
+		{"www.youtube.com", "youtu.be", true},
+		{"old.reddit.com", "www.reddit.com", true},
+		{"www.google.com", "www.nytimes.com", false},
+		{"youtu.be", "www.example.com", false},
+	}
+
+	for _, test := range tests {
+		result := defaultCanonicalizer.Aliases(test.host, test.other)
+		if result != test.expected {
+			t.Errorf("Aliases(%s, %s): expected %t, got %t", test.host, test.other, test.expected, result)
+		}
+	}
+}
@@ -3,19 +3,10 @@ package urltools
 import (
 	"net/url"
 	"strings"
-
-	"github.com/georgemblack/blue-report/pkg/util"
 )
 
-var ExplicitContentHosts = []string{
-	"beacons.ai",
-	"yokubo.tv",
-	"linktr.ee",
-	"allmylinks.com",
-	"onlyfans.com",
-}
-
 // Determine whether to ignore a given URL, i.e. exclude it from our data.
+// Only URLs that can never be part of the report are ignored here. Sites excluded for moderation reasons are matched against the blocklist instead (see 'moderation').
 func Ignore(input string) bool {
 	if input == "" {
 		return true
@@ -32,21 +23,6 @@ func Ignore(input string) bool {
 		return true
 	}
 
-	// Ignore known image hosts
-	if parsed.Hostname() == "media.tenor.com" {
-		return true
-	}
-
-	// Ignore known bots
-	if parsed.Hostname() == "mesonet.agron.iastate.edu" {
-		return true
-	}
-
-	// Ignore known sites that *generally* share explicit content
-	if util.ContainsStr(ExplicitContentHosts, parsed.Hostname()) {
-		return true
-	}
-
 	// Ignore links to the app itself. The purpose of this project is to track external links.
 	if parsed.Hostname() == "bsky.app" || parsed.Hostname() == "go.bsky.app" || strings.HasSuffix(parsed.Hostname(), ".bsky.social") {
 		return true
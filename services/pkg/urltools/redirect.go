@@ -1,7 +1,9 @@
 package urltools
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,60 +18,144 @@ var redirectStatusCodes = []int{http.StatusMovedPermanently, http.StatusFound, h
 // i.e. Links to standard nature.com articles redirect to a redirect service, which then redirect back to the article. This is stupid.
 var noRedirectHosts = []string{"www.nature.com", "link.springer.com", "www.azcentral.com"}
 
+// Outcomes of resolving a redirect chain
+const (
+	ResolvedOutcome = "resolved" // The chain ended at a URL that does not redirect, or the host's redirects are not followed
+	BlockedOutcome  = "blocked"  // A request failed, or was refused (i.e. '403 Forbidden'), before the chain ended
+	HoneypotOutcome = "honeypot" // The chain led to a honeypot service (like TollBit)
+	LoopOutcome     = "loop"     // The chain led back to a URL already visited
+	TimeoutOutcome  = "timeout"  // A request timed out before the chain ended
+	TooDeepOutcome  = "too_deep" // The last hop allowed redirected again, so the destination was not requested
+)
+
+const (
+	DefaultMaxHops    = 2
+	DefaultHopTimeout = 1 * time.Second
+)
+
+// Status codes indicating a site is refusing our requests
+var blockedStatusCodes = []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusUnavailableForLegalReasons}
+
+// Hop is a single request made while resolving a redirect chain.
+type Hop struct {
+	URL      string        // URL requested
+	Status   int           // Status code of the response, or zero if the request failed
+	Location string        // Resolved 'Location' header of a redirect
+	Duration time.Duration // Time taken by the request
+	Error    string        // Error that caused the request to fail
+}
+
+// Chain is the result of resolving a URL's redirects.
+type Chain struct {
+	Source      string
+	Destination string // Last URL reached before the chain ended. This is the source if no redirect was followed.
+	Outcome     string
+	Hops        []Hop
+}
+
+// Redirected returns the destination of the chain, if it can be trusted and differs from the source. Otherwise, an empty string is returned.
+// Chains that were blocked or timed out part way through return the last URL reached, as a valid website could be blocking us.
+func (c Chain) Redirected() string {
+	if c.Outcome == HoneypotOutcome || c.Outcome == LoopOutcome || c.Destination == c.Source {
+		return ""
+	}
+	return c.Destination
+}
+
+// Resolver follows redirects one hop at a time, recording each request.
+type Resolver struct {
+	maxHops int
+	client  *http.Client
+}
+
+// NewResolver creates a resolver that follows up to 'maxHops' redirects, with a timeout for each request.
+func NewResolver(maxHops int, timeout time.Duration) Resolver {
+	return Resolver{
+		maxHops: maxHops,
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Timeout: timeout,
+		},
+	}
+}
+
 // FindRedirect attempts to find the destination URL from a given source URL. If no redirect is found, an empty string is returned.
 // Up to two redirects are followed. (Anything more than that is likely a scummy link.)
 func FindRedirect(input string) string {
-	var result string
+	return NewResolver(DefaultMaxHops, DefaultHopTimeout).Resolve(input).Redirected()
+}
+
+// Resolve follows the redirects of a URL until the chain ends, or the maximum number of hops is reached.
+// If the last hop allowed redirects, its location becomes the destination without being requested, and the chain is too deep
+// (even if it would have ended there), so no more than 'maxHops' requests are made.
+func (r Resolver) Resolve(input string) Chain {
+	chain := Chain{Source: input, Destination: input, Hops: make([]Hop, 0)}
 
 	// Check if host should be ignored
 	parsed, err := url.Parse(input)
 	if err == nil {
 		if util.ContainsStr(noRedirectHosts, parsed.Hostname()) {
-			return ""
+			chain.Outcome = ResolvedOutcome
+			return chain
 		}
 	}
 
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Timeout: 1 * time.Second,
-	}
+	visited := map[string]bool{input: true}
+	for {
+		hop, timedOut := r.request(chain.Destination)
+		chain.Hops = append(chain.Hops, hop)
+
+		switch {
+		case timedOut:
+			chain.Outcome = TimeoutOutcome
+			return chain
+		case hop.Error != "" || util.ContainsInt(blockedStatusCodes, hop.Status):
+			chain.Outcome = BlockedOutcome
+			return chain
+		case hop.Location == "":
+			chain.Outcome = ResolvedOutcome
+			return chain
+		}
 
-	// Check for a first redirect
-	resp, err := client.Get(input)
-	if err != nil {
-		// A valid website could be blocking us. Assume no redirect.
-		return ""
-	}
-	if !util.ContainsInt(redirectStatusCodes, resp.StatusCode) || resp.Header.Get("Location") == "" {
-		return ""
-	}
-	result = resolveLocation(input, resp.Header.Get("Location"))
+		// If the destination URL is a honeypot service (like TollBit), the chain is not followed.
+		if honeypot(hop.Location) {
+			chain.Outcome = HoneypotOutcome
+			return chain
+		}
+		if visited[hop.Location] {
+			chain.Outcome = LoopOutcome
+			return chain
+		}
+		visited[hop.Location] = true
+		chain.Destination = hop.Location
 
-	// If the destination URL is a honeypot service (like TollBit), return no redirect.
-	if honeypot(result) {
-		return ""
+		if len(chain.Hops) >= r.maxHops {
+			chain.Outcome = TooDeepOutcome
+			return chain
+		}
 	}
+}
 
-	// Check for a second redirect
-	resp, err = client.Get(result)
+// Request a URL without following redirects, reporting whether the request timed out.
+func (r Resolver) request(input string) (Hop, bool) {
+	hop := Hop{URL: input}
+	start := time.Now()
+	resp, err := r.client.Get(input)
+	hop.Duration = time.Since(start)
 	if err != nil {
-		// A valid website could be blocking us. Return the inital result.
-		return result
-	}
-	if !util.ContainsInt(redirectStatusCodes, resp.StatusCode) || resp.Header.Get("Location") == "" {
-		return result
+		hop.Error = err.Error()
+		var netErr net.Error
+		return hop, errors.As(err, &netErr) && netErr.Timeout()
 	}
-	result = resolveLocation(result, resp.Header.Get("Location"))
+	resp.Body.Close()
 
-	// If the destination URL is a honeypot service (like TollBit), return no redirect.
-	// A honeypot service is most likely detected by the first redirect – but we check again just in case.
-	if honeypot(result) {
-		return ""
+	hop.Status = resp.StatusCode
+	if util.ContainsInt(redirectStatusCodes, resp.StatusCode) && resp.Header.Get("Location") != "" {
+		hop.Location = resolveLocation(input, resp.Header.Get("Location"))
 	}
-
-	return result
+	return hop, false
 }
 
 // Given a URL, and the 'Location' header of its redirect, return the final destination URL. Examples:
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // Test non-redirect status code
@@ -210,3 +211,125 @@ func TestFindRedirectWithExcludedHost(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", expected, result)
 	}
 }
+
+// Test every hop of a resolved chain is recorded
+func TestResolveChain(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Location", "/a")
+			w.WriteHeader(http.StatusMovedPermanently)
+		case "/a":
+			w.Header().Set("Location", "/b")
+			w.WriteHeader(http.StatusFound)
+		case "/b":
+			w.Header().Set("Location", "/c")
+			w.WriteHeader(http.StatusTemporaryRedirect)
+		}
+	}))
+	defer ms.Close()
+
+	chain := NewResolver(5, time.Second).Resolve(ms.URL)
+	if chain.Outcome != ResolvedOutcome || chain.Destination != ms.URL+"/c" {
+		t.Errorf("unexpected chain: %s, %s", chain.Outcome, chain.Destination)
+	}
+
+	expected := []struct {
+		url      string
+		status   int
+		location string
+	}{
+		{ms.URL, http.StatusMovedPermanently, ms.URL + "/a"},
+		{ms.URL + "/a", http.StatusFound, ms.URL + "/b"},
+		{ms.URL + "/b", http.StatusTemporaryRedirect, ms.URL + "/c"},
+		{ms.URL + "/c", http.StatusOK, ""},
+	}
+	if len(chain.Hops) != len(expected) {
+		t.Fatalf("expected %d hops, got %d", len(expected), len(chain.Hops))
+	}
+	for i, hop := range chain.Hops {
+		if hop.URL != expected[i].url || hop.Status != expected[i].status || hop.Location != expected[i].location || hop.Error != "" {
+			t.Errorf("unexpected hop %d: %+v", i, hop)
+		}
+	}
+
+	// With fewer hops allowed, the chain is too deep. The location of the last hop is the destination, and is not requested.
+	chain = NewResolver(2, time.Second).Resolve(ms.URL)
+	if chain.Outcome != TooDeepOutcome || chain.Destination != ms.URL+"/b" || len(chain.Hops) != 2 {
+		t.Errorf("unexpected chain: %s, %s, %d hops", chain.Outcome, chain.Destination, len(chain.Hops))
+	}
+	if chain.Redirected() != ms.URL+"/b" {
+		t.Errorf("unexpected redirect: %s", chain.Redirected())
+	}
+
+	// A chain ending on the last hop allowed is also too deep, as its destination is not requested
+	chain = NewResolver(3, time.Second).Resolve(ms.URL)
+	if chain.Outcome != TooDeepOutcome || chain.Destination != ms.URL+"/c" || len(chain.Hops) != 3 {
+		t.Errorf("unexpected chain: %s, %s, %d hops", chain.Outcome, chain.Destination, len(chain.Hops))
+	}
+}
+
+// Test chains that lead back to a visited URL
+func TestResolveLoop(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a" {
+			w.Header().Set("Location", "/b")
+		} else {
+			w.Header().Set("Location", "/a")
+		}
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer ms.Close()
+
+	chain := NewResolver(10, time.Second).Resolve(ms.URL + "/a")
+	if chain.Outcome != LoopOutcome || len(chain.Hops) != 2 {
+		t.Errorf("unexpected chain: %s, %d hops", chain.Outcome, len(chain.Hops))
+	}
+	if chain.Redirected() != "" {
+		t.Errorf("expected no redirect, got '%s'", chain.Redirected())
+	}
+}
+
+// Test chains that are refused, time out, or lead to a honeypot
+func TestResolveOutcomes(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/blocked":
+			w.Header().Set("Location", "/forbidden")
+			w.WriteHeader(http.StatusFound)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/slow":
+			w.Header().Set("Location", "/sleep")
+			w.WriteHeader(http.StatusFound)
+		case "/sleep":
+			time.Sleep(200 * time.Millisecond)
+		case "/honeypot":
+			w.Header().Set("Location", "https://tollbit.theblue.report")
+			w.WriteHeader(http.StatusFound)
+		}
+	}))
+	defer ms.Close()
+
+	resolver := NewResolver(5, 50*time.Millisecond)
+	tests := []struct {
+		path       string
+		outcome    string
+		redirected string
+	}{
+		{"/blocked", BlockedOutcome, ms.URL + "/forbidden"},
+		{"/forbidden", BlockedOutcome, ""},
+		{"/slow", TimeoutOutcome, ms.URL + "/sleep"},
+		{"/honeypot", HoneypotOutcome, ""},
+	}
+
+	for _, test := range tests {
+		chain := resolver.Resolve(ms.URL + test.path)
+		if chain.Outcome != test.outcome {
+			t.Errorf("%s: expected outcome '%s', got '%s'", test.path, test.outcome, chain.Outcome)
+		}
+		if chain.Redirected() != test.redirected {
+			t.Errorf("%s: expected redirect '%s', got '%s'", test.path, test.redirected, chain.Redirected())
+		}
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"os"
+	"strconv"
 )
 
 func GetEnvStr(key, defaultValue string) string {
@@ -19,3 +20,15 @@ func GetEnvBool(key string, defaultValue bool) bool {
 	}
 	return value == "true"
 }
+
+func GetEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
@@ -2,6 +2,16 @@ const API_URL = "https://data.theblue.report/data/top-links.json";
 
 export interface TopLinks {
   generated_at: string;
+  language: string;
+  windows: string[];
+  top_links: Record<string, Link[]>;
+  top_categories: Record<string, Record<string, Link[]>>;
+  diversity: {
+    max_per_host: number;
+    demoted: Record<string, number>;
+    category_demoted: Record<string, Record<string, number>>;
+  };
+  removed: RemovedLink[];
   top_hour: Link[];
   top_day: Link[];
   top_week: Link[];
@@ -11,12 +21,47 @@ export interface Link {
   rank: number;
   url: string;
   title: string;
+  category: string;
   thumbnail_url: string;
   post_count: number;
   repost_count: number;
   like_count: number;
   click_count: number;
   recommended_posts: Post[];
+  also_covered_by: Coverage[];
+  trend: Trend;
+  movement: Movement;
+}
+
+export interface RemovedLink {
+  url: string;
+  window: string;
+  rank: number;
+  rule: string;
+  reason: string;
+}
+
+export interface Coverage {
+  url: string;
+  title: string;
+}
+
+export interface Movement {
+  previous_rank: number;
+  rank_change: number;
+  new_entry: boolean;
+  re_entry: boolean;
+  listed_since: string;
+  consecutive_hours: number;
+}
+
+export interface Trend {
+  day: number[];
+  week: number[];
+  peak_hour: string;
+  first_seen: string;
+  momentum: number;
+  rising: boolean;
 }
 
 export interface Post {